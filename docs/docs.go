@@ -45,6 +45,12 @@ const docTemplate = `{
                         "name": "sourceName",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ID of a user or negative ID of a community whose albums are used (vk), the token owner by default",
+                        "name": "owner_id",
+                        "in": "query"
                     }
                 ],
                 "responses": {
@@ -75,6 +81,12 @@ const docTemplate = `{
                             "type": "string"
                         }
                     },
+                    "429": {
+                        "description": "error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
                     "500": {
                         "description": "error",
                         "schema": {
@@ -107,6 +119,12 @@ const docTemplate = `{
                         "in": "path",
                         "required": true
                     },
+                    {
+                        "type": "string",
+                        "description": "ID of a user or negative ID of a community whose albums are used (vk), the token owner by default",
+                        "name": "owner_id",
+                        "in": "query"
+                    },
                     {
                         "type": "string",
                         "description": "album ID",
@@ -120,6 +138,42 @@ const docTemplate = `{
                         "name": "dir",
                         "in": "query",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "where metadata is written: embed (default), sidecar, both, none",
+                        "name": "metadata",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "store the original object of the source as \u003cfile\u003e.json",
+                        "name": "json",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "comma separated metadata fields which may overwrite existing ones: description, created, gps or all",
+                        "name": "override",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "time zone of capture time: utc (default) or gps to infer it from the photo location",
+                        "name": "tz",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "download only image or video, all by default",
+                        "name": "kind",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "add captions and comments of photos (vk) to the description and to comments.json of the album",
+                        "name": "comments",
+                        "in": "query"
                     }
                 ],
                 "responses": {
@@ -150,6 +204,12 @@ const docTemplate = `{
                             "type": "string"
                         }
                     },
+                    "429": {
+                        "description": "error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
                     "500": {
                         "description": "error",
                         "schema": {
@@ -182,12 +242,54 @@ const docTemplate = `{
                         "in": "path",
                         "required": true
                     },
+                    {
+                        "type": "string",
+                        "description": "ID of a user or negative ID of a community whose albums are used (vk), the token owner by default",
+                        "name": "owner_id",
+                        "in": "query"
+                    },
                     {
                         "type": "string",
                         "description": "directory where photos will be stored",
                         "name": "dir",
                         "in": "query",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "where metadata is written: embed (default), sidecar, both, none",
+                        "name": "metadata",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "store the original object of the source as \u003cfile\u003e.json",
+                        "name": "json",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "comma separated metadata fields which may overwrite existing ones: description, created, gps or all",
+                        "name": "override",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "time zone of capture time: utc (default) or gps to infer it from the photo location",
+                        "name": "tz",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "download only image or video, all by default",
+                        "name": "kind",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "add captions and comments of photos (vk) to the description and to comments.json of the album",
+                        "name": "comments",
+                        "in": "query"
                     }
                 ],
                 "responses": {
@@ -218,6 +320,12 @@ const docTemplate = `{
                             "type": "string"
                         }
                     },
+                    "429": {
+                        "description": "error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
                     "500": {
                         "description": "error",
                         "schema": {
@@ -227,6 +335,52 @@ const docTemplate = `{
                 }
             }
         },
+        "/jobs/{jobID}/": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "returns files processed by a download job and metadata fields written into them, only to the api_key the job has been started with",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "job report",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "job ID",
+                        "name": "jobID",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object"
+                        }
+                    },
+                    "401": {
+                        "description": "error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
         "/sources/": {
             "get": {
                 "description": "returns sources",
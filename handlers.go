@@ -2,7 +2,9 @@ package main
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
 
 	"github.com/Gasoid/photoDumper/sources"
 	"github.com/gin-gonic/gin"
@@ -25,10 +27,12 @@ func sourcesHandler(c *gin.Context) {
 // @Produce      json
 // @Accept       json
 // @Param        sourceName  path     string  true  "source name"
+// @Param        owner_id    query    string  false  "ID of a user or negative ID of a community whose albums are used (vk), the token owner by default"
 // @Success      200         {array}  string
 // @Failure      400         {string}  string    "error"
 // @Failure      401         {string}  string    "error"
 // @Failure      403         {string}  string    "error"
+// @Failure      429         {string}  string    "error"
 // @Failure      500         {string}  string    "error"
 // @Security     ApiKeyAuth
 // @Router       /albums/{sourceName}/ [get]
@@ -38,14 +42,13 @@ func albumsHandler(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if err := source.SetOwner(c.Query("owner_id")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	albums, err := source.Albums()
 	if err != nil {
-		var e *sources.AccessError
-		if errors.As(err, &e) {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		}
+		sourceErrorResponse(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"albums": albums})
@@ -57,12 +60,20 @@ func albumsHandler(c *gin.Context) {
 // @Produce      json
 // @Accept       json
 // @Param        sourceName  path     string  true  "source name"
+// @Param        owner_id    query    string  false  "ID of a user or negative ID of a community whose albums are used (vk), the token owner by default"
 // @Param        albumID     path     string  true  "album ID"
 // @Param        dir         query    string  true  "directory where photos will be stored"
+// @Param        metadata    query    string  false  "where metadata is written: embed (default), sidecar, both, none"
+// @Param        json        query    bool    false  "store the original object of the source as <file>.json"
+// @Param        override    query    string  false  "comma separated metadata fields which may overwrite existing ones: description, created, gps or all"
+// @Param        tz          query    string  false  "time zone of capture time: utc (default) or gps to infer it from the photo location"
+// @Param        kind        query    string  false  "download only image or video, all by default"
+// @Param        comments    query    bool    false  "add captions and comments of photos (vk) to the description and to comments.json of the album"
 // @Success      200         {array}  string
 // @Failure      400         {string}  string    "error"
 // @Failure      401         {string}  string    "error"
 // @Failure      403         {string}  string    "error"
+// @Failure      429         {string}  string    "error"
 // @Failure      500         {string}  string    "error"
 // @Router       /download-album/{albumID}/{sourceName}/ [get]
 // @Security     ApiKeyAuth
@@ -72,17 +83,22 @@ func downloadAlbumHandler(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if err := source.SetOwner(c.Query("owner_id")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	options, err := jobOptions(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	source.SetOptions(options)
 	dir, err := source.DownloadAlbum(c.Param("albumID"), c.Query("dir"))
 	if err != nil {
-		var e *sources.AccessError
-		if errors.As(err, &e) {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		}
+		sourceErrorResponse(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"dir": dir, "error": ""})
+	c.JSON(http.StatusOK, gin.H{"dir": dir, "job": source.Report().ID(), "error": ""})
 }
 
 // downloadAllAlbumsHandler godoc
@@ -91,11 +107,19 @@ func downloadAlbumHandler(c *gin.Context) {
 // @Produce      json
 // @Accept       json
 // @Param        sourceName  path     string  true  "source name"
+// @Param        owner_id    query    string  false  "ID of a user or negative ID of a community whose albums are used (vk), the token owner by default"
 // @Param        dir         query    string  true  "directory where photos will be stored"
+// @Param        metadata    query    string  false  "where metadata is written: embed (default), sidecar, both, none"
+// @Param        json        query    bool    false  "store the original object of the source as <file>.json"
+// @Param        override    query    string  false  "comma separated metadata fields which may overwrite existing ones: description, created, gps or all"
+// @Param        tz          query    string  false  "time zone of capture time: utc (default) or gps to infer it from the photo location"
+// @Param        kind        query    string  false  "download only image or video, all by default"
+// @Param        comments    query    bool    false  "add captions and comments of photos (vk) to the description and to comments.json of the album"
 // @Success      200         {array}  string
 // @Failure      400         {string}  string    "error"
 // @Failure      401         {string}  string    "error"
 // @Failure      403         {string}  string    "error"
+// @Failure      429         {string}  string    "error"
 // @Failure      500         {string}  string    "error"
 // @Router       /download-all-albums/{sourceName}/ [get]
 // @Security     ApiKeyAuth
@@ -105,15 +129,92 @@ func downloadAllAlbumsHandler(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if err := source.SetOwner(c.Query("owner_id")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	options, err := jobOptions(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	source.SetOptions(options)
 	dir, err := source.DownloadAllAlbums(c.Query("dir"))
 	if err != nil {
-		var e *sources.AccessError
-		if errors.As(err, &e) {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		}
+		sourceErrorResponse(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"dir": dir, "job": source.Report().ID(), "error": ""})
+}
+
+// jobHandler godoc
+// @Summary      job report
+// @Description  returns files processed by a download job and metadata fields written into them, only to the api_key the job has been started with
+// @Produce      json
+// @Accept       json
+// @Param        jobID  path      string  true  "job ID"
+// @Success      200    {object}  object
+// @Failure      401    {string}  string  "error"
+// @Failure      404    {string}  string  "error"
+// @Router       /jobs/{jobID}/ [get]
+// @Security     ApiKeyAuth
+func jobHandler(c *gin.Context) {
+	report, ok := sources.FindReport(c.Param("jobID"))
+	if !ok || !report.OwnedBy(c.Query("api_key")) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "job not found"})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"dir": dir, "error": ""})
+	c.JSON(http.StatusOK, gin.H{"job": report.ID(), "files": report.Files()})
+}
+
+// sourceErrorResponse responds 401 to access errors, 429 to rate limits and 500 to anything else
+func sourceErrorResponse(c *gin.Context, err error) {
+	var accessErr *sources.AccessError
+	var rateErr *sources.RateLimitError
+	switch {
+	case errors.As(err, &accessErr):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error(), "expired": accessErr.Expired()})
+	case errors.As(err, &rateErr):
+		if rateErr.RetryAfter > 0 {
+			c.Header("Retry-After", strconv.Itoa(int(rateErr.RetryAfter.Seconds())))
+		}
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// jobOptions reads options of a download job from the query
+func jobOptions(c *gin.Context) (sources.JobOptions, error) {
+	options := sources.JobOptions{}
+	metadata, err := sources.ParseMetadataMode(c.Query("metadata"))
+	if err != nil {
+		return options, err
+	}
+	options.Metadata = metadata
+	if value := c.Query("json"); value != "" {
+		options.JSONSidecar, err = strconv.ParseBool(value)
+		if err != nil {
+			return options, fmt.Errorf("json must be a boolean: %w", err)
+		}
+	}
+	switch tz := c.Query("tz"); tz {
+	case "", "utc":
+	case "gps":
+		options.InferTimeZone = true
+	default:
+		return options, fmt.Errorf("unknown tz %q, use utc or gps", tz)
+	}
+	if value := c.Query("comments"); value != "" {
+		options.Comments, err = strconv.ParseBool(value)
+		if err != nil {
+			return options, fmt.Errorf("comments must be a boolean: %w", err)
+		}
+	}
+	options.Kind, err = sources.ParseMediaKind(c.Query("kind"))
+	if err != nil {
+		return options, err
+	}
+	options.Merge, err = sources.ParseMergePolicy(c.Query("override"))
+	return options, err
 }
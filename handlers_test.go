@@ -1,10 +1,13 @@
 package main
 
 import (
+	"encoding/json"
 	"errors"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/Gasoid/photoDumper/sources"
 	"github.com/stretchr/testify/assert"
@@ -18,13 +21,19 @@ type StorageTest struct {
 	downloadPhoto     string
 	downloadPhotoErr  error
 	setExifErr        error
+	changed           []string
+	sidecarErr        error
 }
 
 func (s *StorageTest) Prepare(dir string) (string, error) {
 	return s.dir, s.err
 }
 
-func (s *StorageTest) DownloadPhoto(photoUrl, dir string) (string, error) {
+func (s *StorageTest) DownloadPhoto(photoUrl, dir, name string, info sources.FileInfo) (string, error) {
+	return s.downloadPhoto, s.downloadPhotoErr
+}
+
+func (s *StorageTest) SavePhoto(r io.Reader, dir, name string, info sources.FileInfo) (string, error) {
 	return s.downloadPhoto, s.downloadPhotoErr
 }
 
@@ -32,8 +41,12 @@ func (s *StorageTest) CreateAlbumDir(rootDir, dir string) (string, error) {
 	return s.albumdir, s.createalbumdirErr
 }
 
-func (s *StorageTest) SetExif(filepath string, data sources.ExifInfo) error {
-	return s.setExifErr
+func (s *StorageTest) SetExif(filepath string, data sources.ExifInfo, policy sources.MergePolicy) ([]string, error) {
+	return s.changed, s.setExifErr
+}
+
+func (s *StorageTest) WriteSidecar(filepath string, data []byte) error {
+	return s.sidecarErr
 }
 
 type SourceTest struct {
@@ -132,6 +145,12 @@ func Test_albums(t *testing.T) {
 	router.ServeHTTP(w2, req2)
 
 	assert.Equal(t, http.StatusOK, w2.Code)
+
+	w3 := httptest.NewRecorder()
+	req3, _ := http.NewRequest(http.MethodGet, "/api/download-all-albums/test/?api_key=sdfsdf&json=maybe", nil)
+	router.ServeHTTP(w3, req3)
+
+	assert.Equal(t, http.StatusBadRequest, w3.Code)
 }
 
 func Test_albumsError(t *testing.T) {
@@ -162,6 +181,46 @@ func Test_albumsAccessError(t *testing.T) {
 	assert.Equal(t, http.StatusUnauthorized, w.Code)
 }
 
+func Test_albumsOwnerUnsupported(t *testing.T) {
+	sources.AddSource(&service{})
+	sources.AddStorage(&storage{})
+	router := setupRouter()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/api/albums/test/?api_key=sdfsdf&owner_id=-1", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func Test_albumsRateLimitError(t *testing.T) {
+	sources.AddSource(&service{sourceError: &sources.RateLimitError{RetryAfter: time.Hour}})
+	sources.AddStorage(&storage{})
+	router := setupRouter()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/api/albums/test/?api_key=sdfsdf", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "3600", w.Header().Get("Retry-After"))
+}
+
+func Test_albumsExpiredToken(t *testing.T) {
+	sources.AddSource(&service{sourceError: &sources.AccessError{Err: sources.ErrTokenExpired}})
+	sources.AddStorage(&storage{})
+	router := setupRouter()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/api/albums/test/?api_key=sdfsdf", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	body := map[string]interface{}{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, true, body["expired"])
+}
+
 func Test_downloadAlbumStorageError(t *testing.T) {
 	sources.AddSource(&service{sourceError: &sources.AccessError{}})
 	sources.AddStorage(&storage{err: errors.New("bad")})
@@ -269,3 +328,74 @@ func Test_downloadAllAlbumsApiKeyError(t *testing.T) {
 
 	assert.Equal(t, http.StatusUnauthorized, w.Code)
 }
+
+func Test_downloadAlbumMetadataError(t *testing.T) {
+	sources.AddSource(&service{})
+	sources.AddStorage(&storage{})
+	router := setupRouter()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/api/download-album/albumid/test/?api_key=sdfsdf&metadata=exif", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w2 := httptest.NewRecorder()
+	req2, _ := http.NewRequest(http.MethodGet, "/api/download-all-albums/test/?api_key=sdfsdf&metadata=sidecar", nil)
+	router.ServeHTTP(w2, req2)
+
+	assert.Equal(t, http.StatusOK, w2.Code)
+
+	w3 := httptest.NewRecorder()
+	req3, _ := http.NewRequest(http.MethodGet, "/api/download-all-albums/test/?api_key=sdfsdf&json=maybe", nil)
+	router.ServeHTTP(w3, req3)
+
+	assert.Equal(t, http.StatusBadRequest, w3.Code)
+}
+
+func Test_job(t *testing.T) {
+	sources.AddSource(&service{})
+	sources.AddStorage(&storage{})
+	router := setupRouter()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/api/download-album/albumid/test/?api_key=sdfsdf&override=gps,created", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var resp struct {
+		Job string `json:"job"`
+	}
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.NotEmpty(t, resp.Job)
+
+	w2 := httptest.NewRecorder()
+	req2, _ := http.NewRequest(http.MethodGet, "/api/jobs/"+resp.Job+"/?api_key=sdfsdf", nil)
+	router.ServeHTTP(w2, req2)
+	assert.Equal(t, http.StatusOK, w2.Code)
+
+	w3 := httptest.NewRecorder()
+	req3, _ := http.NewRequest(http.MethodGet, "/api/jobs/unknown/?api_key=sdfsdf", nil)
+	router.ServeHTTP(w3, req3)
+	assert.Equal(t, http.StatusNotFound, w3.Code)
+
+	w6 := httptest.NewRecorder()
+	req6, _ := http.NewRequest(http.MethodGet, "/api/jobs/"+resp.Job+"/", nil)
+	router.ServeHTTP(w6, req6)
+	assert.Equal(t, http.StatusUnauthorized, w6.Code)
+
+	w7 := httptest.NewRecorder()
+	req7, _ := http.NewRequest(http.MethodGet, "/api/jobs/"+resp.Job+"/?api_key=other", nil)
+	router.ServeHTTP(w7, req7)
+	assert.Equal(t, http.StatusNotFound, w7.Code)
+
+	w4 := httptest.NewRecorder()
+	req4, _ := http.NewRequest(http.MethodGet, "/api/download-album/albumid/test/?api_key=sdfsdf&override=make", nil)
+	router.ServeHTTP(w4, req4)
+	assert.Equal(t, http.StatusBadRequest, w4.Code)
+
+	w5 := httptest.NewRecorder()
+	req5, _ := http.NewRequest(http.MethodGet, "/api/download-album/albumid/test/?api_key=sdfsdf&tz=local", nil)
+	router.ServeHTTP(w5, req5)
+	assert.Equal(t, http.StatusBadRequest, w5.Code)
+}
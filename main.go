@@ -2,12 +2,27 @@ package main
 
 import (
 	"embed"
+	"log"
+	"os"
+	"path/filepath"
+	// zones inferred from gps have to be available on systems without zoneinfo
+	_ "time/tzdata"
 
 	_ "github.com/Gasoid/photoDumper/docs"
 	"github.com/Gasoid/photoDumper/sources"
+	"github.com/Gasoid/photoDumper/sources/facebook"
+	"github.com/Gasoid/photoDumper/sources/flickr"
+	"github.com/Gasoid/photoDumper/sources/googlephotos"
 	"github.com/Gasoid/photoDumper/sources/instagram"
+	"github.com/Gasoid/photoDumper/sources/localdir"
+	"github.com/Gasoid/photoDumper/sources/mastodon"
+	"github.com/Gasoid/photoDumper/sources/ok"
+	"github.com/Gasoid/photoDumper/sources/takeout"
+	"github.com/Gasoid/photoDumper/sources/telegram"
 	"github.com/Gasoid/photoDumper/sources/vk"
+	"github.com/Gasoid/photoDumper/sources/web"
 
+	"github.com/Gasoid/photoDumper/storage/immich"
 	local "github.com/Gasoid/photoDumper/storage/localfs"
 )
 
@@ -31,10 +46,44 @@ var staticAssets embed.FS
 // @name api_key
 func main() {
 	sources.AddSource(vk.NewService())
-	sources.AddSource(instagram.NewService())
-	sources.AddStorage(local.NewService())
+	sources.AddSource(vk.NewMessagesService())
+	sources.AddSource(instagramService())
+	sources.AddSource(localdir.NewService())
+	sources.AddSource(takeout.NewService())
+	sources.AddSource(flickr.NewService())
+	sources.AddSource(facebook.NewService())
+	sources.AddSource(telegram.NewService())
+	sources.AddSource(telegram.NewExportService())
+	sources.AddSource(mastodon.NewService())
+	sources.AddSource(web.NewService())
+	sources.AddSource(ok.NewService(os.Getenv("OK_APPLICATION_KEY"), os.Getenv("OK_APPLICATION_SECRET")))
+	sources.AddSource(googlephotos.NewServiceWithClient(os.Getenv("GOOGLE_CLIENT_ID"), os.Getenv("GOOGLE_CLIENT_SECRET")))
+	sources.AddStorage(storageService())
 	router := setupRouter()
 	if router != nil {
 		router.Run(":8080")
 	}
 }
+
+// instagramService keeps refreshed instagram tokens in INSTAGRAM_TOKEN_FILE (photoDumper/instagram_tokens.json of the user config dir),
+// INSTAGRAM_CLIENT_SECRET enables exchange of short-lived tokens
+func instagramService() sources.ServiceSource {
+	path := os.Getenv("INSTAGRAM_TOKEN_FILE")
+	if path == "" {
+		dir, err := os.UserConfigDir()
+		if err != nil {
+			log.Println("instagram tokens won't be stored:", err)
+			return instagram.NewService()
+		}
+		path = filepath.Join(dir, "photoDumper", "instagram_tokens.json")
+	}
+	return instagram.NewServiceWithTokens(instagram.NewFileTokenStore(path), os.Getenv("INSTAGRAM_CLIENT_SECRET"))
+}
+
+// storageService uploads photos into immich if IMMICH_URL is set, otherwise they are stored on disk
+func storageService() sources.ServiceStorage {
+	if serverUrl := os.Getenv("IMMICH_URL"); serverUrl != "" {
+		return immich.NewService(serverUrl, os.Getenv("IMMICH_API_KEY"))
+	}
+	return local.NewService()
+}
@@ -34,6 +34,7 @@ func setupRouter() *gin.Engine {
 			auth.GET("/albums/:sourceName/", albumsHandler)
 			auth.GET("/download-all-albums/:sourceName/", downloadAllAlbumsHandler)
 			auth.GET("/download-album/:albumID/:sourceName/", downloadAlbumHandler)
+			auth.GET("/jobs/:jobID/", jobHandler)
 		}
 
 	}
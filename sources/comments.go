@@ -0,0 +1,104 @@
+package sources
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// commentsFile is stored in every album directory if comments are requested
+const commentsFile = "comments.json"
+
+// Comment is a comment of a photo
+type Comment struct {
+	Author string    `json:"author"`
+	Text   string    `json:"text"`
+	Date   time.Time `json:"date"`
+}
+
+// CommentedPhoto is an optional interface of Photo, comments are requested only if a job asks for them
+type CommentedPhoto interface {
+	Caption() string
+	Comments() ([]Comment, error)
+}
+
+// PhotoComments is an entry of comments.json
+type PhotoComments struct {
+	Caption  string    `json:"caption,omitempty"`
+	Comments []Comment `json:"comments,omitempty"`
+}
+
+// describedExif overrides description of an ExifInfo
+type describedExif struct {
+	ExifInfo
+	description string
+}
+
+func (d *describedExif) Description() string {
+	return d.description
+}
+
+// withComments appends caption and comments to the description
+func withComments(info ExifInfo, comments PhotoComments) ExifInfo {
+	if comments.Caption == "" && len(comments.Comments) == 0 {
+		return info
+	}
+	parts := []string{}
+	if description := info.Description(); description != "" {
+		parts = append(parts, description)
+	}
+	if comments.Caption != "" {
+		parts = append(parts, comments.Caption)
+	}
+	if len(comments.Comments) > 0 {
+		lines := []string{"Comments:"}
+		for _, c := range comments.Comments {
+			lines = append(lines, fmt.Sprintf("%s (%s): %s", c.Author, c.Date.UTC().Format("2006-01-02 15:04"), c.Text))
+		}
+		parts = append(parts, strings.Join(lines, "\n"))
+	}
+	return &describedExif{ExifInfo: info, description: strings.Join(parts, "\n\n")}
+}
+
+// albumComments collects comments of photos per album directory,
+// comments.json of the album is rewritten when a photo is added, writes are serialized
+// so that an older snapshot never overwrites a newer one
+type albumComments struct {
+	mu     sync.Mutex
+	albums map[string]map[string]PhotoComments
+}
+
+func newAlbumComments() *albumComments {
+	return &albumComments{albums: map[string]map[string]PhotoComments{}}
+}
+
+// add stores comments of the file and writes comments.json of its album while holding the lock
+func (a *albumComments) add(file string, comments PhotoComments, write func(path string, data []byte) error) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	dir := filepath.Dir(file)
+	album, ok := a.albums[dir]
+	if !ok {
+		album = map[string]PhotoComments{}
+		a.albums[dir] = album
+	}
+	album[filepath.Base(file)] = comments
+	data, err := json.MarshalIndent(album, "", "  ")
+	if err != nil {
+		return err
+	}
+	return write(filepath.Join(dir, commentsFile), data)
+}
+
+// photoComments requests caption and comments of the photo if it provides them
+func photoComments(photo Photo) (PhotoComments, error) {
+	cp, ok := photo.(CommentedPhoto)
+	if !ok {
+		return PhotoComments{}, nil
+	}
+	comments, err := cp.Comments()
+	return PhotoComments{Caption: cp.Caption(), Comments: comments}, err
+}
@@ -0,0 +1,97 @@
+package facebook
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/Gasoid/photoDumper/sources/internal/graph"
+)
+
+// graphUrl is a variable to point tests to a fake server
+var graphUrl = "https://graph.facebook.com/v14.0/"
+
+// pageLimit is the page size of lists
+const pageLimit = "100"
+
+var (
+	albumFields = []string{"id", "name", "description", "count", "created_time", "type", "link", "cover_photo{images}"}
+	photoFields = []string{"id", "name", "images", "place", "created_time", "backdated_time", "link", "width", "height"}
+)
+
+type Image struct {
+	Height int    `json:"height"`
+	Width  int    `json:"width"`
+	Source string `json:"source"`
+}
+
+type Location struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	City      string  `json:"city,omitempty"`
+	Country   string  `json:"country,omitempty"`
+}
+
+type Place struct {
+	ID       string    `json:"id"`
+	Name     string    `json:"name"`
+	Location *Location `json:"location,omitempty"`
+}
+
+type Photo struct {
+	ID            string   `json:"id"`
+	Name          string   `json:"name,omitempty"`
+	Images        []*Image `json:"images"`
+	Place         *Place   `json:"place,omitempty"`
+	CreatedTime   string   `json:"created_time"`
+	BackdatedTime string   `json:"backdated_time,omitempty"`
+	Link          string   `json:"link,omitempty"`
+}
+
+// Largest returns the biggest size of the photo, images are usually sorted but it's not documented
+func (p *Photo) Largest() *Image {
+	var largest *Image
+	for _, image := range p.Images {
+		if largest == nil || image.Width*image.Height > largest.Width*largest.Height {
+			largest = image
+		}
+	}
+	return largest
+}
+
+type Album struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Count       int    `json:"count"`
+	CreatedTime string `json:"created_time"`
+	Type        string `json:"type,omitempty"`
+	Link        string `json:"link,omitempty"`
+	CoverPhoto  *Photo `json:"cover_photo,omitempty"`
+}
+
+type API struct {
+	client *graph.Client
+}
+
+func NewAPI(token string) *API {
+	return &API{client: &graph.Client{Service: "facebook", Token: token}}
+}
+
+func fields(list []string) url.Values {
+	return url.Values{"fields": {strings.Join(list, ",")}, "limit": {pageLimit}}
+}
+
+// Albums returns albums of the user, system albums (profile pictures, timeline photos) are included
+func (api *API) Albums() (*graph.List[*Album], error) {
+	return graph.GetList[*Album](api.client, graphUrl+"me/albums", fields(albumFields))
+}
+
+func (api *API) Album(albumID string) (*Album, error) {
+	album := &Album{}
+	err := api.client.Get(graphUrl+url.PathEscape(albumID), url.Values{"fields": {strings.Join(albumFields, ",")}}, album)
+	return album, err
+}
+
+func (api *API) AlbumPhotos(albumID string) (*graph.List[*Photo], error) {
+	return graph.GetList[*Photo](api.client, graphUrl+url.PathEscape(albumID)+"/photos", fields(photoFields))
+}
@@ -0,0 +1,178 @@
+package facebook
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/Gasoid/photoDumper/sources"
+	"github.com/Gasoid/photoDumper/sources/internal/graph"
+)
+
+// timeLayout is the format of times of the Graph API
+const timeLayout = "2006-01-02T15:04:05-0700"
+
+type PhotoItem struct {
+	albumName string
+	photo     *Photo
+}
+
+func (f *PhotoItem) Url() string {
+	if image := f.photo.Largest(); image != nil {
+		return image.Source
+	}
+	return ""
+}
+
+func (f *PhotoItem) AlbumName() string {
+	return f.albumName
+}
+
+func (f *PhotoItem) Kind() sources.MediaKind {
+	return sources.MediaImage
+}
+
+// Filename is ID of the photo, cdn urls have meaningless basenames
+func (f *PhotoItem) Filename() string {
+	return f.photo.ID
+}
+
+// Metadata returns the photo object as it is received from the Graph API
+func (f *PhotoItem) Metadata() interface{} {
+	return f.photo
+}
+
+// ExifInfo uses backdated time if the user has set when the photo was taken, otherwise the upload time,
+// the location is taken from the tagged place
+func (f *PhotoItem) ExifInfo() (sources.ExifInfo, error) {
+	exif := &exifInfo{description: strings.TrimSpace(f.photo.Name)}
+	date := f.photo.BackdatedTime
+	if date == "" {
+		date = f.photo.CreatedTime
+	}
+	if date != "" {
+		created, err := time.Parse(timeLayout, date)
+		if err != nil {
+			log.Println("facebook: photo", f.photo.ID, "wrong time", date)
+		}
+		exif.created = created
+	}
+	if place := f.photo.Place; place != nil && place.Location != nil {
+		gps, err := sources.NewGPS(place.Location.Latitude, place.Location.Longitude)
+		if err != nil {
+			log.Println("facebook: photo", f.photo.ID, err)
+		}
+		exif.gps = gps
+	}
+	return exif, nil
+}
+
+type exifInfo struct {
+	description string
+	created     time.Time
+	gps         *sources.GPS
+}
+
+func (e *exifInfo) Description() string {
+	return e.description
+}
+
+func (e *exifInfo) Created() time.Time {
+	return e.created
+}
+
+func (e *exifInfo) GPS() *sources.GPS {
+	return e.gps
+}
+
+type Facebook struct {
+	api *API
+}
+
+func New(creds string) sources.Source {
+	return &Facebook{api: NewAPI(creds)}
+}
+
+// AllAlbums returns albums of the user
+func (fb *Facebook) AllAlbums() ([]map[string]string, error) {
+	list, err := fb.api.Albums()
+	if err != nil {
+		return nil, graph.APIError(err, "can't get albums")
+	}
+	albums := []map[string]string{}
+	for list.Next() {
+		album := list.Item()
+		var created, thumb string
+		if t, err := time.Parse(timeLayout, album.CreatedTime); err == nil {
+			created = t.UTC().Format(time.RFC3339)
+		}
+		if album.CoverPhoto != nil {
+			if image := album.CoverPhoto.Largest(); image != nil {
+				thumb = image.Source
+			}
+		}
+		albums = append(albums, map[string]string{
+			"thumb":   thumb,
+			"title":   album.Name,
+			"id":      album.ID,
+			"created": created,
+			"size":    fmt.Sprint(album.Count),
+		})
+	}
+	if err := list.Err(); err != nil {
+		return nil, graph.APIError(err, "can't get albums")
+	}
+	return albums, nil
+}
+
+// AlbumPhotos returns photos of the album, pages are requested while photos are downloaded
+func (fb *Facebook) AlbumPhotos(albumID string) (sources.ItemFetcher, error) {
+	album, err := fb.api.Album(albumID)
+	if err != nil {
+		return nil, graph.APIError(err, "can't get album")
+	}
+	photos, err := fb.api.AlbumPhotos(album.ID)
+	if err != nil {
+		return nil, graph.APIError(err, "can't get photos")
+	}
+	// names of albums are folders, the ID replaces names which aren't safe
+	return &fetcher{photos: photos, albumName: sources.FolderName(album.Name, album.ID)}, nil
+}
+
+type fetcher struct {
+	photos    *graph.List[*Photo]
+	albumName string
+}
+
+func (f *fetcher) Next() bool {
+	if f.photos.Next() {
+		return true
+	}
+	if err := f.photos.Err(); err != nil {
+		log.Println("facebook: photos", err)
+	}
+	return false
+}
+
+func (f *fetcher) Item() sources.Photo {
+	return &PhotoItem{albumName: f.albumName, photo: f.photos.Item()}
+}
+
+type service struct{}
+
+func (s *service) Kind() sources.Kind {
+	return sources.KindSource
+}
+
+func (s *service) Key() string {
+	return "facebook"
+}
+
+func (s *service) Constructor() func(creds string) sources.Source {
+	return New
+}
+
+func NewService() sources.ServiceSource {
+	return &service{}
+}
@@ -0,0 +1,132 @@
+package facebook
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Gasoid/photoDumper/sources"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeGraph serves responses by path of the request, the second page of a list is requested with "after"
+func fakeGraph(t *testing.T, responses map[string]interface{}) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("access_token") != "token" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":{"message":"Error validating access token: Session has expired","type":"OAuthException","code":190,"error_subcode":463}}`))
+			return
+		}
+		key := strings.TrimPrefix(r.URL.Path, "/")
+		if after := r.URL.Query().Get("after"); after != "" {
+			key += "?after=" + after
+		}
+		resp, ok := responses[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		data, _ := json.Marshal(resp)
+		w.Write([]byte(strings.ReplaceAll(string(data), "SERVER", server.URL)))
+	}))
+	old := graphUrl
+	graphUrl = server.URL + "/"
+	t.Cleanup(func() {
+		graphUrl = old
+		server.Close()
+	})
+}
+
+func TestFacebook_AllAlbums(t *testing.T) {
+	fakeGraph(t, map[string]interface{}{
+		"me/albums": map[string]interface{}{
+			"data": []map[string]interface{}{
+				{"id": "10", "name": "Profile pictures", "count": 3, "created_time": "2021-07-03T15:04:05+0000", "type": "profile",
+					"cover_photo": map[string]interface{}{"id": "1", "images": []map[string]interface{}{{"width": 130, "height": 130, "source": "https://scontent/small.jpg"}, {"width": 960, "height": 960, "source": "https://scontent/cover.jpg"}}}},
+			},
+			"paging": map[string]interface{}{"next": "SERVER/me/albums?access_token=token&after=abc"},
+		},
+		"me/albums?after=abc": map[string]interface{}{
+			"data": []map[string]interface{}{{"id": "11", "name": "Summer", "count": 12, "created_time": "2022-06-01T00:00:00+0300"}},
+		},
+	})
+
+	albums, err := New("token").AllAlbums()
+	assert.NoError(t, err)
+	assert.Equal(t, []map[string]string{
+		{"id": "10", "title": "Profile pictures", "size": "3", "created": "2021-07-03T15:04:05Z", "thumb": "https://scontent/cover.jpg"},
+		{"id": "11", "title": "Summer", "size": "12", "created": "2022-05-31T21:00:00Z", "thumb": ""},
+	}, albums)
+
+	_, err = New("expired").AllAlbums()
+	assert.IsType(t, &sources.AccessError{}, err)
+	assert.True(t, errors.Is(err, sources.ErrTokenExpired))
+}
+
+func TestFacebook_AlbumPhotos(t *testing.T) {
+	fakeGraph(t, map[string]interface{}{
+		"11": map[string]interface{}{"id": "11", "name": "Summer"},
+		"11/photos": map[string]interface{}{
+			"data": []map[string]interface{}{
+				{
+					"id": "1", "name": "Red square", "created_time": "2022-06-01T10:00:00+0000", "backdated_time": "2021-07-03T15:04:05+0000",
+					"images": []map[string]interface{}{{"width": 720, "height": 480, "source": "https://scontent/1_720.jpg"}, {"width": 2048, "height": 1365, "source": "https://scontent/1_2048.jpg"}},
+					"place":  map[string]interface{}{"id": "p", "name": "Red Square", "location": map[string]interface{}{"latitude": 55.7539, "longitude": 37.6208}},
+				},
+			},
+			"paging": map[string]interface{}{"next": "SERVER/11/photos?access_token=token&after=abc"},
+		},
+		"11/photos?after=abc": map[string]interface{}{
+			"data": []map[string]interface{}{{"id": "2", "created_time": "2022-06-02T10:00:00+0000", "images": []map[string]interface{}{{"width": 720, "height": 480, "source": "https://scontent/2.jpg"}}}},
+		},
+	})
+	fb := New("token")
+
+	fetcher, err := fb.AlbumPhotos("11")
+	assert.NoError(t, err)
+	photos := []*PhotoItem{}
+	for fetcher.Next() {
+		photos = append(photos, fetcher.Item().(*PhotoItem))
+	}
+	if !assert.Len(t, photos, 2) {
+		return
+	}
+	assert.Equal(t, "Summer", photos[0].AlbumName())
+	assert.Equal(t, "https://scontent/1_2048.jpg", photos[0].Url())
+	assert.Equal(t, "1", photos[0].Filename())
+	info, err := photos[0].ExifInfo()
+	assert.NoError(t, err)
+	assert.Equal(t, "Red square", info.Description())
+	assert.True(t, time.Date(2021, 7, 3, 15, 4, 5, 0, time.UTC).Equal(info.Created()))
+	if assert.NotNil(t, info.GPS()) {
+		assert.Equal(t, 55.7539, info.GPS().Latitude)
+	}
+
+	info, err = photos[1].ExifInfo()
+	assert.NoError(t, err)
+	assert.True(t, time.Date(2022, 6, 2, 10, 0, 0, 0, time.UTC).Equal(info.Created()))
+	assert.Nil(t, info.GPS())
+
+	_, err = fb.AlbumPhotos("missing")
+	assert.Error(t, err)
+}
+
+func TestFacebook_AlbumPhotosName(t *testing.T) {
+	fakeGraph(t, map[string]interface{}{
+		"12":        map[string]interface{}{"id": "12", "name": "../../.ssh"},
+		"12/photos": map[string]interface{}{"data": []map[string]interface{}{{"id": "1", "images": []map[string]interface{}{{"width": 720, "height": 480, "source": "https://scontent/1.jpg"}}}}},
+		"13":        map[string]interface{}{"id": "13", "name": ".."},
+		"13/photos": map[string]interface{}{"data": []map[string]interface{}{{"id": "2", "images": []map[string]interface{}{{"width": 720, "height": 480, "source": "https://scontent/2.jpg"}}}}},
+	})
+	for albumID, want := range map[string]string{"12": ".._.._.ssh", "13": "13"} {
+		fetcher, err := New("token").AlbumPhotos(albumID)
+		assert.NoError(t, err)
+		assert.True(t, fetcher.Next())
+		assert.Equal(t, want, fetcher.Item().AlbumName())
+	}
+}
@@ -0,0 +1,271 @@
+package flickr
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Gasoid/photoDumper/sources"
+)
+
+// restUrl is a variable to point tests to a fake server
+var restUrl = "https://api.flickr.com/services/rest/"
+
+// perPage is the maximum page size of flickr api
+const perPage = 500
+
+// extras are additional fields of photos: original url, large url as a fallback, taken date and location
+var extras = []string{"url_o", "url_l", "date_taken", "geo", "description", "media", "original_format", "owner_name"}
+
+// codes of flickr api errors
+const (
+	codeNotFound        = 1
+	codeInvalidAuth     = 98
+	codeNoPermission    = 99
+	codeInvalidKey      = 100
+	codeKeySuspended    = 105
+	codeTooManyRequests = 429
+)
+
+// Error is the error response of flickr api, it's returned with status 200,
+// responses which can't be decoded are errors with the status of the response
+type Error struct {
+	Code       int    `json:"code"`
+	Message    string `json:"message"`
+	StatusCode int    `json:"-"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("flickr: %s (status %d, code %d)", e.Message, e.StatusCode, e.Code)
+}
+
+// Access reports whether the key or the token is invalid or has no permissions
+func (e *Error) Access() bool {
+	switch e.Code {
+	case codeInvalidAuth, codeNoPermission, codeInvalidKey, codeKeySuspended:
+		return true
+	}
+	return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden
+}
+
+func (e *Error) RateLimited() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.Code == codeTooManyRequests
+}
+
+// apiError converts flickr errors into sources.AccessError and sources.RateLimitError
+func apiError(err error, text string) error {
+	var e *Error
+	if !errors.As(err, &e) {
+		return fmt.Errorf("%s: %w", text, err)
+	}
+	switch {
+	case e.RateLimited():
+		return &sources.RateLimitError{Err: err, Text: "flickr api limit is reached, try again later", RetryAfter: time.Hour}
+	case e.Access():
+		return &sources.AccessError{Err: err, Text: "api key is invalid?"}
+	}
+	return fmt.Errorf("%s: %w", text, err)
+}
+
+// number is an integer which flickr sends either as a number or as a string
+type number int
+
+func (n *number) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(string(data), `"`)
+	if s == "" || s == "null" {
+		*n = 0
+		return nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return err
+	}
+	*n = number(v)
+	return nil
+}
+
+// float is a coordinate which flickr sends either as a number or as a string
+type float float64
+
+func (f *float) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(string(data), `"`)
+	if s == "" || s == "null" {
+		*f = 0
+		return nil
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return err
+	}
+	*f = float(v)
+	return nil
+}
+
+type content struct {
+	Content string `json:"_content"`
+}
+
+type Photoset struct {
+	ID          string  `json:"id"`
+	Title       content `json:"title"`
+	Description content `json:"description"`
+	Photos      number  `json:"photos"`
+	Videos      number  `json:"videos"`
+	DateCreate  number  `json:"date_create"`
+	Primary     struct {
+		UrlM string `json:"url_m"`
+	} `json:"primary_photo_extras"`
+}
+
+type Photo struct {
+	ID                   string  `json:"id"`
+	Owner                string  `json:"owner,omitempty"`
+	OwnerName            string  `json:"ownername,omitempty"`
+	Title                string  `json:"title"`
+	Description          content `json:"description"`
+	Media                string  `json:"media"`
+	UrlO                 string  `json:"url_o,omitempty"`
+	UrlL                 string  `json:"url_l,omitempty"`
+	OriginalSecret       string  `json:"originalsecret,omitempty"`
+	OriginalFormat       string  `json:"originalformat,omitempty"`
+	DateTaken            string  `json:"datetaken"`
+	DateTakenUnknown     number  `json:"datetakenunknown"`
+	DateTakenGranularity number  `json:"datetakengranularity"`
+	Latitude             float   `json:"latitude"`
+	Longitude            float   `json:"longitude"`
+	Accuracy             number  `json:"accuracy"`
+}
+
+// page is a list of photos, photosets and photostreams have the same fields
+type page struct {
+	Page  number   `json:"page"`
+	Pages number   `json:"pages"`
+	Total number   `json:"total"`
+	Owner string   `json:"owner"`
+	Title string   `json:"title"`
+	Photo []*Photo `json:"photo"`
+}
+
+type API struct {
+	key string
+}
+
+func NewAPI(key string) *API {
+	return &API{key: key}
+}
+
+// call requests the method and decodes the response into r, errors with status 200 are decoded too
+func (api *API) call(method string, params url.Values, r interface{}) error {
+	if params == nil {
+		params = url.Values{}
+	}
+	params.Set("method", method)
+	params.Set("api_key", api.key)
+	params.Set("format", "json")
+	params.Set("nojsoncallback", "1")
+	resp, err := http.Get(restUrl + "?" + params.Encode())
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	status := &struct {
+		Stat    string `json:"stat"`
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}{}
+	if err := json.Unmarshal(body, status); err != nil || status.Stat != "ok" || resp.StatusCode != http.StatusOK {
+		if status.Message == "" {
+			status.Message = http.StatusText(resp.StatusCode)
+		}
+		return &Error{Code: status.Code, Message: status.Message, StatusCode: resp.StatusCode}
+	}
+	if err := json.Unmarshal(body, r); err != nil {
+		return fmt.Errorf("flickr: error decoding body; %w", err)
+	}
+	return nil
+}
+
+// FindUser returns NSID of the user by the username
+func (api *API) FindUser(username string) (string, error) {
+	r := &struct {
+		User struct {
+			NSID string `json:"nsid"`
+		} `json:"user"`
+	}{}
+	err := api.call("flickr.people.findByUsername", url.Values{"username": {username}}, r)
+	return r.User.NSID, err
+}
+
+// Photosets returns all photosets of the user
+func (api *API) Photosets(userID string) ([]*Photoset, error) {
+	photosets := []*Photoset{}
+	for p := 1; ; p++ {
+		r := &struct {
+			Photosets struct {
+				Page     number      `json:"page"`
+				Pages    number      `json:"pages"`
+				Photoset []*Photoset `json:"photoset"`
+			} `json:"photosets"`
+		}{}
+		params := url.Values{"user_id": {userID}, "page": {fmt.Sprint(p)}, "per_page": {fmt.Sprint(perPage)}, "primary_photo_extras": {"url_m"}}
+		if err := api.call("flickr.photosets.getList", params, r); err != nil {
+			return nil, err
+		}
+		photosets = append(photosets, r.Photosets.Photoset...)
+		if int(r.Photosets.Pages) <= p || len(r.Photosets.Photoset) == 0 {
+			return photosets, nil
+		}
+	}
+}
+
+// PhotosetPhotos returns a page of photos of the photoset, pages start at 1
+func (api *API) PhotosetPhotos(userID, photosetID string, n int) (*page, error) {
+	r := &struct {
+		Photoset *page `json:"photoset"`
+	}{}
+	params := url.Values{
+		"user_id":     {userID},
+		"photoset_id": {photosetID},
+		"extras":      {strings.Join(extras, ",")},
+		"media":       {"all"},
+		"page":        {fmt.Sprint(n)},
+		"per_page":    {fmt.Sprint(perPage)},
+	}
+	if err := api.call("flickr.photosets.getPhotos", params, r); err != nil {
+		return nil, err
+	}
+	if r.Photoset == nil {
+		return nil, &Error{Code: codeNotFound, Message: "photoset not found", StatusCode: http.StatusOK}
+	}
+	return r.Photoset, nil
+}
+
+// PublicPhotos returns a page of the photostream of the user, pages start at 1
+func (api *API) PublicPhotos(userID string, n int) (*page, error) {
+	r := &struct {
+		Photos *page `json:"photos"`
+	}{}
+	params := url.Values{
+		"user_id":  {userID},
+		"extras":   {strings.Join(extras, ",")},
+		"page":     {fmt.Sprint(n)},
+		"per_page": {fmt.Sprint(perPage)},
+	}
+	if err := api.call("flickr.people.getPublicPhotos", params, r); err != nil {
+		return nil, err
+	}
+	if r.Photos == nil {
+		return &page{}, nil
+	}
+	return r.Photos, nil
+}
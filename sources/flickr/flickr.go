@@ -0,0 +1,318 @@
+package flickr
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/Gasoid/photoDumper/sources"
+)
+
+const (
+	// photostreamAlbumID is all public photos of the user, they are skipped when all albums are downloaded
+	photostreamAlbumID = "photostream"
+	// notInSetAlbumID is public photos which aren't in photosets, it completes photosets when all albums are downloaded
+	notInSetAlbumID = "notinset"
+	notInSetTitle   = "Not in a set"
+	dateTakenLayout = "2006-01-02 15:04:05"
+)
+
+// PhotoItem is a photo or a video of flickr
+type PhotoItem struct {
+	albumName string
+	photo     *Photo
+}
+
+// Url is the original file, photos of users who disabled downloads of originals have the large size only
+func (f *PhotoItem) Url() string {
+	if f.Kind() == sources.MediaVideo {
+		return fmt.Sprintf("https://www.flickr.com/photos/%s/%s/play/orig/%s/", f.photo.Owner, f.photo.ID, f.photo.OriginalSecret)
+	}
+	if f.photo.UrlO != "" {
+		return f.photo.UrlO
+	}
+	return f.photo.UrlL
+}
+
+func (f *PhotoItem) AlbumName() string {
+	return f.albumName
+}
+
+func (f *PhotoItem) Kind() sources.MediaKind {
+	if f.photo.Media == "video" {
+		return sources.MediaVideo
+	}
+	return sources.MediaImage
+}
+
+// Filename is ID of the photo, extension is taken from the content type
+func (f *PhotoItem) Filename() string {
+	return f.photo.ID
+}
+
+// ThumbnailUrl returns a still image of a video
+func (f *PhotoItem) ThumbnailUrl() string {
+	if f.Kind() != sources.MediaVideo {
+		return ""
+	}
+	if f.photo.UrlO != "" {
+		return f.photo.UrlO
+	}
+	return f.photo.UrlL
+}
+
+// Metadata returns the photo object as it is received from flickr api
+func (f *PhotoItem) Metadata() interface{} {
+	return f.photo
+}
+
+// ExifInfo uses the taken date, flickr keeps it as a wall clock, so it's parsed in the zone of the location
+func (f *PhotoItem) ExifInfo() (sources.ExifInfo, error) {
+	exif := &exifInfo{description: strings.TrimSpace(f.photo.Description.Content)}
+	if f.photo.Latitude != 0 || f.photo.Longitude != 0 {
+		gps, err := sources.NewGPS(float64(f.photo.Latitude), float64(f.photo.Longitude))
+		if err != nil {
+			log.Println("flickr: photo", f.photo.ID, err)
+		}
+		exif.gps = gps
+	}
+	if f.photo.DateTakenUnknown == 0 && f.photo.DateTaken != "" {
+		location := sources.Zone(exif.gps)
+		if location == nil {
+			location = time.UTC
+		}
+		created, err := time.ParseInLocation(dateTakenLayout, f.photo.DateTaken, location)
+		if err != nil {
+			log.Println("flickr: photo", f.photo.ID, "wrong datetaken", f.photo.DateTaken)
+		}
+		exif.created = created
+	}
+	return exif, nil
+}
+
+type exifInfo struct {
+	description string
+	created     time.Time
+	gps         *sources.GPS
+}
+
+func (e *exifInfo) Description() string {
+	return e.description
+}
+
+func (e *exifInfo) Created() time.Time {
+	return e.created
+}
+
+func (e *exifInfo) GPS() *sources.GPS {
+	return e.gps
+}
+
+// Flickr dumps public photos of the user set by owner_id, creds is an api key of flickr app
+type Flickr struct {
+	api    *API
+	owner  string
+	userID string
+}
+
+func New(creds string) sources.Source {
+	return &Flickr{api: NewAPI(creds)}
+}
+
+// SetOwner takes NSID (e.g. 12345678@N01) or username of the user
+func (f *Flickr) SetOwner(ownerID string) error {
+	f.owner = strings.TrimSpace(ownerID)
+	f.userID = ""
+	return nil
+}
+
+// user returns NSID of the owner, usernames are looked up once
+func (f *Flickr) user() (string, error) {
+	if f.userID != "" {
+		return f.userID, nil
+	}
+	if f.owner == "" {
+		return "", &sources.AccessError{Text: "owner_id (flickr user) is required", Err: errors.New("no owner")}
+	}
+	if strings.Contains(f.owner, "@N") {
+		f.userID = f.owner
+		return f.userID, nil
+	}
+	userID, err := f.api.FindUser(f.owner)
+	if err != nil {
+		return "", apiError(err, "can't find user")
+	}
+	f.userID = userID
+	return userID, nil
+}
+
+// AllAlbums returns photosets of the user, photos which aren't in photosets and the photostream
+func (f *Flickr) AllAlbums() ([]map[string]string, error) {
+	userID, err := f.user()
+	if err != nil {
+		return nil, err
+	}
+	photosets, err := f.api.Photosets(userID)
+	if err != nil {
+		return nil, apiError(err, "can't get photosets")
+	}
+	albums := make([]map[string]string, 0, len(photosets)+1)
+	for _, set := range photosets {
+		albums = append(albums, map[string]string{
+			"thumb":   set.Primary.UrlM,
+			"title":   set.Title.Content,
+			"id":      set.ID,
+			"created": time.Unix(int64(set.DateCreate), 0).UTC().Format(time.RFC3339),
+			"size":    fmt.Sprint(set.Photos + set.Videos),
+		})
+	}
+	return append(albums, map[string]string{
+		"title": notInSetTitle,
+		"id":    notInSetAlbumID,
+	}, map[string]string{
+		"title":   "Photostream",
+		"id":      photostreamAlbumID,
+		"derived": "true",
+	}), nil
+}
+
+// setPhotos returns ids of photos of every photoset of the user
+func (f *Flickr) setPhotos(userID string) (map[string]bool, error) {
+	photosets, err := f.api.Photosets(userID)
+	if err != nil {
+		return nil, err
+	}
+	ids := map[string]bool{}
+	for _, set := range photosets {
+		for n := 1; ; n++ {
+			p, err := f.api.PhotosetPhotos(userID, set.ID, n)
+			if err != nil {
+				return nil, err
+			}
+			for _, photo := range p.Photo {
+				ids[photo.ID] = true
+			}
+			if len(p.Photo) == 0 || p.Page >= p.Pages {
+				break
+			}
+		}
+	}
+	return ids, nil
+}
+
+// AlbumPhotos returns photos of the photoset or of the photostream, they are stored in a folder named by the owner
+func (f *Flickr) AlbumPhotos(albumID string) (sources.ItemFetcher, error) {
+	userID, err := f.user()
+	if err != nil {
+		return nil, err
+	}
+	load := func(n int) (*page, error) {
+		p, err := f.api.PhotosetPhotos(userID, albumID, n)
+		if p != nil {
+			for _, photo := range p.Photo {
+				photo.Owner = p.Owner
+			}
+		}
+		return p, err
+	}
+	var skip map[string]bool
+	switch albumID {
+	case notInSetAlbumID:
+		skip, err = f.setPhotos(userID)
+		if err != nil {
+			return nil, apiError(err, "can't get photosets")
+		}
+		load = f.publicPhotos(userID, notInSetTitle)
+	case photostreamAlbumID:
+		load = f.publicPhotos(userID, "Photostream")
+	}
+	first, err := load(1)
+	if err != nil {
+		return nil, apiError(err, "can't get photos")
+	}
+	return &fetcher{load: load, current: first, albumName: albumName(first), skip: skip}, nil
+}
+
+// publicPhotos loads pages of the photostream titled as the album
+func (f *Flickr) publicPhotos(userID, title string) func(n int) (*page, error) {
+	return func(n int) (*page, error) {
+		p, err := f.api.PublicPhotos(userID, n)
+		if p != nil {
+			p.Title = title
+		}
+		return p, err
+	}
+}
+
+// albumName is the title of the album in the folder of the owner, both are safe folder names
+func albumName(p *page) string {
+	owner := ""
+	if len(p.Photo) > 0 {
+		owner = p.Photo[0].OwnerName
+	}
+	return sources.AlbumPath(owner, p.Title)
+}
+
+// fetcher loads pages of photos, photos of skip are passed over
+type fetcher struct {
+	load      func(n int) (*page, error)
+	current   *page
+	albumName string
+	cur       *Photo
+	skip      map[string]bool
+}
+
+func (f *fetcher) Next() bool {
+	for {
+		if !f.nextPhoto() {
+			return false
+		}
+		if !f.skip[f.cur.ID] {
+			return true
+		}
+	}
+}
+
+func (f *fetcher) nextPhoto() bool {
+	for len(f.current.Photo) == 0 {
+		if f.current.Page >= f.current.Pages {
+			return false
+		}
+		next, err := f.load(int(f.current.Page) + 1)
+		if err != nil {
+			log.Println("flickr: photos", err)
+			return false
+		}
+		if len(next.Photo) == 0 {
+			return false
+		}
+		f.current = next
+	}
+	f.cur = f.current.Photo[0]
+	f.current.Photo = f.current.Photo[1:]
+	return true
+}
+
+func (f *fetcher) Item() sources.Photo {
+	return &PhotoItem{albumName: f.albumName, photo: f.cur}
+}
+
+type service struct{}
+
+func (s *service) Kind() sources.Kind {
+	return sources.KindSource
+}
+
+func (s *service) Key() string {
+	return "flickr"
+}
+
+func (s *service) Constructor() func(creds string) sources.Source {
+	return New
+}
+
+func NewService() sources.ServiceSource {
+	return &service{}
+}
@@ -0,0 +1,218 @@
+package flickr
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/Gasoid/photoDumper/sources"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeFlickr serves responses of api methods, unknown methods and wrong keys are errors as flickr returns them
+func fakeFlickr(t *testing.T, methods map[string]func(query url.Values) map[string]interface{}) *Flickr {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		w.Header().Set("Content-Type", "application/json")
+		if query.Get("api_key") != "key" {
+			json.NewEncoder(w).Encode(map[string]interface{}{"stat": "fail", "code": 100, "message": "Invalid API Key (Key has invalid format)"})
+			return
+		}
+		handler, ok := methods[query.Get("method")]
+		if !ok {
+			json.NewEncoder(w).Encode(map[string]interface{}{"stat": "fail", "code": 112, "message": "Method not found"})
+			return
+		}
+		response := handler(query)
+		response["stat"] = "ok"
+		json.NewEncoder(w).Encode(response)
+	}))
+	t.Cleanup(server.Close)
+	restUrl = server.URL + "/"
+	return New("key").(*Flickr)
+}
+
+func photo(id string) map[string]interface{} {
+	return map[string]interface{}{
+		"id": id, "title": "IMG_" + id, "media": "photo", "ownername": "Anna",
+		"url_o": "https://live.staticflickr.com/65535/" + id + "_o.jpg", "url_l": "https://live.staticflickr.com/65535/" + id + "_b.jpg",
+		"datetaken": "2021-07-03 18:04:05", "datetakenunknown": "0", "latitude": "55.7539", "longitude": 37.6208, "accuracy": "16",
+		"description": map[string]string{"_content": "Red square"},
+	}
+}
+
+func TestFlickr_AllAlbums(t *testing.T) {
+	f := fakeFlickr(t, map[string]func(url.Values) map[string]interface{}{
+		"flickr.people.findByUsername": func(query url.Values) map[string]interface{} {
+			assert.Equal(t, "anna", query.Get("username"))
+			return map[string]interface{}{"user": map[string]interface{}{"id": "1234@N01", "nsid": "1234@N01"}}
+		},
+		"flickr.photosets.getList": func(query url.Values) map[string]interface{} {
+			assert.Equal(t, "1234@N01", query.Get("user_id"))
+			return map[string]interface{}{"photosets": map[string]interface{}{
+				"page": 1, "pages": 1,
+				"photoset": []map[string]interface{}{{
+					"id": "7215", "photos": 3, "videos": "1", "date_create": "1625335445",
+					"title":                map[string]string{"_content": "Summer"},
+					"primary_photo_extras": map[string]string{"url_m": "https://live.staticflickr.com/m.jpg"},
+				}},
+			}}
+		},
+	})
+
+	_, err := f.AllAlbums()
+	assert.IsType(t, &sources.AccessError{}, err)
+
+	f.SetOwner("anna")
+	albums, err := f.AllAlbums()
+	assert.NoError(t, err)
+	assert.Equal(t, []map[string]string{
+		{"id": "7215", "title": "Summer", "size": "4", "created": "2021-07-03T18:04:05Z", "thumb": "https://live.staticflickr.com/m.jpg"},
+		{"id": notInSetAlbumID, "title": "Not in a set"},
+		{"id": photostreamAlbumID, "title": "Photostream", "derived": "true"},
+	}, albums)
+
+	f.api.key = "wrong"
+	f.SetOwner("1234@N01")
+	_, err = f.AllAlbums()
+	assert.IsType(t, &sources.AccessError{}, err)
+}
+
+func TestFlickr_AlbumPhotos(t *testing.T) {
+	f := fakeFlickr(t, map[string]func(url.Values) map[string]interface{}{
+		"flickr.photosets.getPhotos": func(query url.Values) map[string]interface{} {
+			assert.Equal(t, "7215", query.Get("photoset_id"))
+			assert.Contains(t, query.Get("extras"), "url_o")
+			photos := []map[string]interface{}{photo("1"), photo("2")}
+			if query.Get("page") == "2" {
+				video := photo("3")
+				video["media"] = "video"
+				video["originalsecret"] = "abc"
+				delete(video, "url_o")
+				video["datetakenunknown"] = "1"
+				video["latitude"], video["longitude"] = 0, 0
+				photos = []map[string]interface{}{video}
+			}
+			return map[string]interface{}{"photoset": map[string]interface{}{
+				"id": "7215", "owner": "1234@N01", "title": "Summer", "page": query.Get("page"), "pages": 2, "total": "3", "photo": photos,
+			}}
+		},
+		"flickr.people.getPublicPhotos": func(query url.Values) map[string]interface{} {
+			return map[string]interface{}{"photos": map[string]interface{}{
+				"page": 1, "pages": 1, "photo": []map[string]interface{}{photo("4")},
+			}}
+		},
+	})
+	f.SetOwner("1234@N01")
+
+	fetcher, err := f.AlbumPhotos("7215")
+	assert.NoError(t, err)
+	photos := []*PhotoItem{}
+	for fetcher.Next() {
+		photos = append(photos, fetcher.Item().(*PhotoItem))
+	}
+	if !assert.Len(t, photos, 3) {
+		return
+	}
+	assert.Equal(t, "Anna/Summer", photos[0].AlbumName())
+	assert.Equal(t, "https://live.staticflickr.com/65535/1_o.jpg", photos[0].Url())
+	assert.Equal(t, "1", photos[0].Filename())
+	assert.Equal(t, "", photos[0].ThumbnailUrl())
+	info, err := photos[0].ExifInfo()
+	assert.NoError(t, err)
+	assert.Equal(t, "Red square", info.Description())
+	moscow, _ := time.LoadLocation("Europe/Moscow")
+	assert.Equal(t, time.Date(2021, 7, 3, 18, 4, 5, 0, moscow).Unix(), info.Created().Unix())
+	if assert.NotNil(t, info.GPS()) {
+		assert.Equal(t, 55.7539, info.GPS().Latitude)
+	}
+
+	video := photos[2]
+	assert.Equal(t, sources.MediaVideo, video.Kind())
+	assert.Equal(t, "https://www.flickr.com/photos/1234@N01/3/play/orig/abc/", video.Url())
+	assert.Equal(t, "https://live.staticflickr.com/65535/3_b.jpg", video.ThumbnailUrl())
+	info, err = video.ExifInfo()
+	assert.NoError(t, err)
+	assert.True(t, info.Created().IsZero())
+	assert.Nil(t, info.GPS())
+
+	fetcher, err = f.AlbumPhotos(photostreamAlbumID)
+	assert.NoError(t, err)
+	assert.True(t, fetcher.Next())
+	assert.Equal(t, "Anna/Photostream", fetcher.Item().AlbumName())
+	assert.False(t, fetcher.Next())
+
+	f.api.key = "wrong"
+	_, err = f.AlbumPhotos("7215")
+	assert.IsType(t, &sources.AccessError{}, err)
+}
+
+func TestFlickr_AlbumPhotosNotInSet(t *testing.T) {
+	f := fakeFlickr(t, map[string]func(url.Values) map[string]interface{}{
+		"flickr.photosets.getList": func(query url.Values) map[string]interface{} {
+			return map[string]interface{}{"photosets": map[string]interface{}{
+				"page": 1, "pages": 1,
+				"photoset": []map[string]interface{}{{"id": "7215", "title": map[string]string{"_content": "Summer"}}},
+			}}
+		},
+		"flickr.photosets.getPhotos": func(query url.Values) map[string]interface{} {
+			photos := []map[string]interface{}{photo("1")}
+			if query.Get("page") == "2" {
+				photos = []map[string]interface{}{photo("3")}
+			}
+			return map[string]interface{}{"photoset": map[string]interface{}{
+				"id": "7215", "owner": "1234@N01", "title": "Summer", "page": query.Get("page"), "pages": 2, "photo": photos,
+			}}
+		},
+		"flickr.people.getPublicPhotos": func(query url.Values) map[string]interface{} {
+			photos := []map[string]interface{}{photo("1"), photo("2")}
+			if query.Get("page") == "2" {
+				photos = []map[string]interface{}{photo("3"), photo("4")}
+			}
+			return map[string]interface{}{"photos": map[string]interface{}{
+				"page": query.Get("page"), "pages": 2, "photo": photos,
+			}}
+		},
+	})
+	f.SetOwner("1234@N01")
+
+	fetcher, err := f.AlbumPhotos(notInSetAlbumID)
+	assert.NoError(t, err)
+	ids := []string{}
+	for fetcher.Next() {
+		assert.Equal(t, "Anna/Not in a set", fetcher.Item().AlbumName())
+		ids = append(ids, fetcher.Item().(*PhotoItem).Filename())
+	}
+	assert.Equal(t, []string{"2", "4"}, ids)
+}
+
+func Test_apiError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want interface{}
+	}{
+		{name: "invalid key", err: &Error{Code: codeInvalidKey, StatusCode: http.StatusOK}, want: &sources.AccessError{}},
+		{name: "rate limit", err: &Error{StatusCode: http.StatusTooManyRequests}, want: &sources.RateLimitError{}},
+		{name: "not found", err: &Error{Code: codeNotFound, StatusCode: http.StatusOK}, want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := apiError(tt.err, "text")
+			if tt.want == nil {
+				assert.ErrorIs(t, err, tt.err)
+				return
+			}
+			assert.IsType(t, tt.want, err)
+		})
+	}
+}
+
+func Test_albumName(t *testing.T) {
+	assert.Equal(t, "Anna/Summer", albumName(&page{Title: "Summer", Photo: []*Photo{{OwnerName: "Anna"}}}))
+	assert.Equal(t, "_/.._.ssh", albumName(&page{Title: "../.ssh", Photo: []*Photo{{OwnerName: ".."}}}))
+	assert.Equal(t, "Trip _ Day 1", albumName(&page{Title: "Trip / Day 1"}))
+}
@@ -0,0 +1,201 @@
+package googlephotos
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/Gasoid/photoDumper/sources"
+)
+
+// libraryUrl is a variable to point tests to a fake server
+var libraryUrl = "https://photoslibrary.googleapis.com/v1/"
+
+const (
+	albumsPageSize = 50
+	mediaPageSize  = 100
+)
+
+type Album struct {
+	ID                string `json:"id"`
+	Title             string `json:"title"`
+	ProductUrl        string `json:"productUrl"`
+	MediaItemsCount   string `json:"mediaItemsCount"`
+	CoverPhotoBaseUrl string `json:"coverPhotoBaseUrl"`
+}
+
+type MediaMetadata struct {
+	CreationTime string          `json:"creationTime"`
+	Width        string          `json:"width"`
+	Height       string          `json:"height"`
+	Photo        json.RawMessage `json:"photo,omitempty"`
+	Video        *struct {
+		Status string `json:"status"`
+	} `json:"video,omitempty"`
+}
+
+type MediaItem struct {
+	ID            string         `json:"id"`
+	Description   string         `json:"description"`
+	ProductUrl    string         `json:"productUrl"`
+	BaseUrl       string         `json:"baseUrl"`
+	MimeType      string         `json:"mimeType"`
+	Filename      string         `json:"filename"`
+	MediaMetadata *MediaMetadata `json:"mediaMetadata"`
+}
+
+type albumsResponse struct {
+	Albums        []*Album `json:"albums"`
+	NextPageToken string   `json:"nextPageToken"`
+}
+
+type mediaResponse struct {
+	MediaItems    []*MediaItem `json:"mediaItems"`
+	NextPageToken string       `json:"nextPageToken"`
+}
+
+// Error is the error envelope of google apis
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Status  string `json:"status"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("google photos: %s (code %d, %s)", e.Message, e.Code, e.Status)
+}
+
+// Unwrap makes errors.Is(err, sources.ErrTokenExpired) work, access tokens live for an hour
+func (e *Error) Unwrap() error {
+	if e.Code == http.StatusUnauthorized {
+		return sources.ErrTokenExpired
+	}
+	return nil
+}
+
+// apiError converts errors of the api into sources.AccessError and sources.RateLimitError
+func apiError(err error, text string) error {
+	var e *Error
+	if !errors.As(err, &e) {
+		return fmt.Errorf("%s: %w", text, err)
+	}
+	switch e.Code {
+	case http.StatusUnauthorized:
+		return &sources.AccessError{Err: err, Text: "token has expired, please log in again"}
+	case http.StatusForbidden:
+		return &sources.AccessError{Err: err, Text: "token has no access to the library"}
+	case http.StatusTooManyRequests:
+		return &sources.RateLimitError{Err: err, Text: "google photos api limit is reached, try again later", RetryAfter: time.Hour}
+	}
+	return fmt.Errorf("%s: %w", text, err)
+}
+
+func decodeError(resp *http.Response) error {
+	envelope := &struct {
+		Error *Error `json:"error"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(envelope); err != nil || envelope.Error == nil {
+		return &Error{Code: resp.StatusCode, Message: http.StatusText(resp.StatusCode)}
+	}
+	envelope.Error.Code = resp.StatusCode
+	return envelope.Error
+}
+
+type API struct {
+	tokens *tokenSource
+}
+
+func NewAPI(tokens *tokenSource) *API {
+	return &API{tokens: tokens}
+}
+
+// Albums returns all albums of the library
+func (api *API) Albums() ([]*Album, error) {
+	albums := []*Album{}
+	params := url.Values{"pageSize": {fmt.Sprint(albumsPageSize)}}
+	for {
+		r := &albumsResponse{}
+		if err := api.do(http.MethodGet, "albums?"+params.Encode(), nil, r); err != nil {
+			return nil, err
+		}
+		albums = append(albums, r.Albums...)
+		if r.NextPageToken == "" {
+			return albums, nil
+		}
+		params.Set("pageToken", r.NextPageToken)
+	}
+}
+
+// Album returns the album by its ID
+func (api *API) Album(albumID string) (*Album, error) {
+	album := &Album{}
+	return album, api.do(http.MethodGet, "albums/"+url.PathEscape(albumID), nil, album)
+}
+
+// Search returns a page of media items of the album, all items of the library are listed if albumID is empty
+func (api *API) Search(albumID, pageToken string) (*mediaResponse, error) {
+	r := &mediaResponse{}
+	if albumID == "" {
+		params := url.Values{"pageSize": {fmt.Sprint(mediaPageSize)}}
+		if pageToken != "" {
+			params.Set("pageToken", pageToken)
+		}
+		return r, api.do(http.MethodGet, "mediaItems?"+params.Encode(), nil, r)
+	}
+	body := map[string]interface{}{"albumId": albumID, "pageSize": mediaPageSize}
+	if pageToken != "" {
+		body["pageToken"] = pageToken
+	}
+	return r, api.do(http.MethodPost, "mediaItems:search", body, r)
+}
+
+// do sends the request with the access token, the token is refreshed and the request is repeated once if it's rejected
+func (api *API) do(method, path string, body interface{}, r interface{}) error {
+	err := api.send(method, path, body, r)
+	var e *Error
+	if errors.As(err, &e) && e.Code == http.StatusUnauthorized && api.tokens.refreshable() {
+		api.tokens.invalidate()
+		return api.send(method, path, body, r)
+	}
+	return err
+}
+
+func (api *API) send(method, path string, body interface{}, r interface{}) error {
+	token, err := api.tokens.token()
+	if err != nil {
+		return err
+	}
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, libraryUrl+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return decodeError(resp)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(r); err != nil {
+		return fmt.Errorf("google photos: error decoding body; %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,250 @@
+package googlephotos
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"github.com/Gasoid/photoDumper/sources"
+)
+
+const (
+	// libraryAlbumID is all media items of the library, they are skipped when all albums are downloaded
+	libraryAlbumID = "library"
+	libraryTitle   = "All Google Photos"
+	// notInAlbumID is media items which aren't in albums, it completes albums when all albums are downloaded
+	notInAlbumID    = "notinalbum"
+	notInAlbumTitle = "Not in an album"
+)
+
+// PhotoItem is a media item of the library
+type PhotoItem struct {
+	albumName string
+	item      *MediaItem
+}
+
+// Url downloads the original: =d is the photo with its EXIF (without location), =dv is the video
+func (f *PhotoItem) Url() string {
+	if f.Kind() == sources.MediaVideo {
+		return f.item.BaseUrl + "=dv"
+	}
+	return f.item.BaseUrl + "=d"
+}
+
+func (f *PhotoItem) AlbumName() string {
+	return f.albumName
+}
+
+func (f *PhotoItem) Kind() sources.MediaKind {
+	if strings.HasPrefix(f.item.MimeType, "video/") || f.item.MediaMetadata != nil && f.item.MediaMetadata.Video != nil {
+		return sources.MediaVideo
+	}
+	return sources.MediaImage
+}
+
+// Filename keeps the name of the uploaded file
+func (f *PhotoItem) Filename() string {
+	return f.item.Filename
+}
+
+// ThumbnailUrl returns a frame of a video
+func (f *PhotoItem) ThumbnailUrl() string {
+	if f.Kind() != sources.MediaVideo {
+		return ""
+	}
+	return f.item.BaseUrl + "=w1280-h1280"
+}
+
+// Metadata returns the media item as it is received from the api
+func (f *PhotoItem) Metadata() interface{} {
+	return f.item
+}
+
+// ExifInfo uses creationTime of the media item, the api doesn't provide locations
+func (f *PhotoItem) ExifInfo() (sources.ExifInfo, error) {
+	exif := &exifInfo{description: strings.TrimSpace(f.item.Description)}
+	if f.item.MediaMetadata != nil && f.item.MediaMetadata.CreationTime != "" {
+		created, err := time.Parse(time.RFC3339, f.item.MediaMetadata.CreationTime)
+		if err != nil {
+			log.Println("google photos: item", f.item.ID, "wrong creationTime", f.item.MediaMetadata.CreationTime)
+		}
+		exif.created = created
+	}
+	return exif, nil
+}
+
+type exifInfo struct {
+	description string
+	created     time.Time
+}
+
+func (e *exifInfo) Description() string {
+	return e.description
+}
+
+func (e *exifInfo) Created() time.Time {
+	return e.created
+}
+
+func (e *exifInfo) GPS() *sources.GPS {
+	return nil
+}
+
+type GooglePhotos struct {
+	api *API
+}
+
+// New takes an access token, use NewServiceWithClient to accept refresh tokens
+func New(creds string) sources.Source {
+	return &GooglePhotos{api: NewAPI(newTokenSource(creds, "", ""))}
+}
+
+// AllAlbums returns albums of the library, media items which aren't in albums and the library itself
+func (g *GooglePhotos) AllAlbums() ([]map[string]string, error) {
+	resp, err := g.api.Albums()
+	if err != nil {
+		return nil, apiError(err, "can't get albums")
+	}
+	albums := make([]map[string]string, 0, len(resp)+1)
+	for _, album := range resp {
+		albums = append(albums, map[string]string{
+			"thumb": album.CoverPhotoBaseUrl,
+			"title": album.Title,
+			"id":    album.ID,
+			"size":  album.MediaItemsCount,
+		})
+	}
+	return append(albums, map[string]string{
+		"title": notInAlbumTitle,
+		"id":    notInAlbumID,
+	}, map[string]string{
+		"title":   libraryTitle,
+		"id":      libraryAlbumID,
+		"derived": "true",
+	}), nil
+}
+
+// albumItems returns ids of media items of every album of the library
+func (g *GooglePhotos) albumItems() (map[string]bool, error) {
+	albums, err := g.api.Albums()
+	if err != nil {
+		return nil, err
+	}
+	ids := map[string]bool{}
+	for _, album := range albums {
+		pageToken := ""
+		for {
+			page, err := g.api.Search(album.ID, pageToken)
+			if err != nil {
+				return nil, err
+			}
+			for _, item := range page.MediaItems {
+				ids[item.ID] = true
+			}
+			if page.NextPageToken == "" {
+				break
+			}
+			pageToken = page.NextPageToken
+		}
+	}
+	return ids, nil
+}
+
+// AlbumPhotos returns media items of the album, the first page is requested at once to report access errors
+func (g *GooglePhotos) AlbumPhotos(albumID string) (sources.ItemFetcher, error) {
+	albumName := libraryTitle
+	searchID := ""
+	var skip map[string]bool
+	switch albumID {
+	case libraryAlbumID:
+	case notInAlbumID:
+		ids, err := g.albumItems()
+		if err != nil {
+			return nil, apiError(err, "can't get albums")
+		}
+		albumName, skip = notInAlbumTitle, ids
+	default:
+		album, err := g.api.Album(albumID)
+		if err != nil {
+			return nil, apiError(err, "can't get album")
+		}
+		// titles of albums are folders, the ID replaces titles which aren't safe
+		albumName, searchID = sources.FolderName(album.Title, album.ID), album.ID
+	}
+	page, err := g.api.Search(searchID, "")
+	if err != nil {
+		return nil, apiError(err, "can't get media items")
+	}
+	return &fetcher{api: g.api, albumID: searchID, albumName: albumName, page: page, skip: skip}, nil
+}
+
+// fetcher loads pages of media items, items of skip are passed over
+type fetcher struct {
+	api       *API
+	albumID   string
+	albumName string
+	page      *mediaResponse
+	cur       *MediaItem
+	skip      map[string]bool
+}
+
+func (f *fetcher) Next() bool {
+	for {
+		if !f.nextItem() {
+			return false
+		}
+		if !f.skip[f.cur.ID] {
+			return true
+		}
+	}
+}
+
+func (f *fetcher) nextItem() bool {
+	for len(f.page.MediaItems) == 0 {
+		if f.page.NextPageToken == "" {
+			return false
+		}
+		page, err := f.api.Search(f.albumID, f.page.NextPageToken)
+		if err != nil {
+			log.Println("google photos: media items", err)
+			return false
+		}
+		f.page = page
+	}
+	f.cur = f.page.MediaItems[0]
+	f.page.MediaItems = f.page.MediaItems[1:]
+	return true
+}
+
+func (f *fetcher) Item() sources.Photo {
+	return &PhotoItem{albumName: f.albumName, item: f.cur}
+}
+
+type service struct {
+	clientID     string
+	clientSecret string
+}
+
+func (s *service) Kind() sources.Kind {
+	return sources.KindSource
+}
+
+func (s *service) Key() string {
+	return "googlephotos"
+}
+
+func (s *service) Constructor() func(creds string) sources.Source {
+	return func(creds string) sources.Source {
+		return &GooglePhotos{api: NewAPI(newTokenSource(creds, s.clientID, s.clientSecret))}
+	}
+}
+
+func NewService() sources.ServiceSource {
+	return &service{}
+}
+
+// NewServiceWithClient accepts refresh tokens (1//...) as creds and refreshes access tokens
+// with credentials of the oauth client of the app
+func NewServiceWithClient(clientID, clientSecret string) sources.ServiceSource {
+	return &service{clientID: clientID, clientSecret: clientSecret}
+}
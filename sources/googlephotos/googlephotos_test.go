@@ -0,0 +1,221 @@
+package googlephotos
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Gasoid/photoDumper/sources"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeLibrary serves handlers by "METHOD path", requests without the valid token are rejected as the api does
+func fakeLibrary(t *testing.T, validToken string, handlers map[string]func(r *http.Request) interface{}) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Header.Get("Authorization") != "Bearer "+validToken {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]interface{}{"error": map[string]interface{}{
+				"code": 401, "message": "Request had invalid authentication credentials.", "status": "UNAUTHENTICATED",
+			}})
+			return
+		}
+		handler, ok := handlers[r.Method+" "+r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]interface{}{"error": map[string]interface{}{"code": 404, "message": "Requested entity was not found.", "status": "NOT_FOUND"}})
+			return
+		}
+		json.NewEncoder(w).Encode(handler(r))
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		w.Header().Set("Content-Type", "application/json")
+		if r.Form.Get("refresh_token") != "1//refresh" || r.Form.Get("client_id") != "client" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant", "error_description": "Token has been expired or revoked."})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"access_token": validToken, "expires_in": 3599, "token_type": "Bearer"})
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	libraryUrl = server.URL + "/v1/"
+	tokenUrl = server.URL + "/token"
+}
+
+func mediaItem(id, mimeType string) map[string]interface{} {
+	return map[string]interface{}{
+		"id": id, "baseUrl": "https://lh3.googleusercontent.com/" + id, "mimeType": mimeType, "filename": id + ".jpg",
+		"description":   "Red square",
+		"mediaMetadata": map[string]interface{}{"creationTime": "2021-07-03T15:04:05Z", "width": "4032", "height": "3024"},
+	}
+}
+
+func TestGooglePhotos_AllAlbums(t *testing.T) {
+	fakeLibrary(t, "ya29.token", map[string]func(r *http.Request) interface{}{
+		"GET /v1/albums": func(r *http.Request) interface{} {
+			assert.Equal(t, "50", r.URL.Query().Get("pageSize"))
+			if r.URL.Query().Get("pageToken") == "" {
+				return map[string]interface{}{"albums": []map[string]string{{"id": "a1", "title": "Summer", "mediaItemsCount": "2"}}, "nextPageToken": "next"}
+			}
+			return map[string]interface{}{"albums": []map[string]string{{"id": "a2", "title": "Winter", "mediaItemsCount": "5", "coverPhotoBaseUrl": "https://lh3/cover"}}}
+		},
+	})
+
+	albums, err := New("ya29.token").AllAlbums()
+	assert.NoError(t, err)
+	assert.Equal(t, []map[string]string{
+		{"id": "a1", "title": "Summer", "size": "2", "thumb": ""},
+		{"id": "a2", "title": "Winter", "size": "5", "thumb": "https://lh3/cover"},
+		{"id": notInAlbumID, "title": "Not in an album"},
+		{"id": libraryAlbumID, "title": "All Google Photos", "derived": "true"},
+	}, albums)
+
+	_, err = New("ya29.expired").AllAlbums()
+	assert.IsType(t, &sources.AccessError{}, err)
+	assert.True(t, errors.Is(err, sources.ErrTokenExpired))
+}
+
+func TestGooglePhotos_AlbumPhotosTitle(t *testing.T) {
+	fakeLibrary(t, "ya29.token", map[string]func(r *http.Request) interface{}{
+		"GET /v1/albums/a2": func(r *http.Request) interface{} {
+			return map[string]string{"id": "a2", "title": "../../.ssh"}
+		},
+		"GET /v1/albums/a3": func(r *http.Request) interface{} {
+			return map[string]string{"id": "a3", "title": ".."}
+		},
+		"POST /v1/mediaItems:search": func(r *http.Request) interface{} {
+			return map[string]interface{}{"mediaItems": []interface{}{mediaItem("p1", "image/jpeg")}}
+		},
+	})
+	for albumID, want := range map[string]string{"a2": ".._.._.ssh", "a3": "a3"} {
+		fetcher, err := New("ya29.token").AlbumPhotos(albumID)
+		assert.NoError(t, err)
+		assert.True(t, fetcher.Next())
+		assert.Equal(t, want, fetcher.Item().AlbumName())
+	}
+}
+
+func TestGooglePhotos_AlbumPhotos(t *testing.T) {
+	fakeLibrary(t, "ya29.token", map[string]func(r *http.Request) interface{}{
+		"GET /v1/albums/a1": func(r *http.Request) interface{} {
+			return map[string]string{"id": "a1", "title": "Summer"}
+		},
+		"POST /v1/mediaItems:search": func(r *http.Request) interface{} {
+			body := map[string]interface{}{}
+			json.NewDecoder(r.Body).Decode(&body)
+			assert.Equal(t, "a1", body["albumId"])
+			if body["pageToken"] == nil {
+				return map[string]interface{}{"mediaItems": []interface{}{mediaItem("p1", "image/jpeg")}, "nextPageToken": "next"}
+			}
+			assert.Equal(t, "next", body["pageToken"])
+			video := mediaItem("v1", "video/mp4")
+			video["mediaMetadata"].(map[string]interface{})["video"] = map[string]string{"status": "READY"}
+			return map[string]interface{}{"mediaItems": []interface{}{video}}
+		},
+		"GET /v1/mediaItems": func(r *http.Request) interface{} {
+			item := mediaItem("p2", "image/heic")
+			delete(item, "mediaMetadata")
+			return map[string]interface{}{"mediaItems": []interface{}{item}}
+		},
+	})
+	g := New("ya29.token")
+
+	fetcher, err := g.AlbumPhotos("a1")
+	assert.NoError(t, err)
+	photos := []*PhotoItem{}
+	for fetcher.Next() {
+		photos = append(photos, fetcher.Item().(*PhotoItem))
+	}
+	if !assert.Len(t, photos, 2) {
+		return
+	}
+	assert.Equal(t, "Summer", photos[0].AlbumName())
+	assert.Equal(t, "https://lh3.googleusercontent.com/p1=d", photos[0].Url())
+	assert.Equal(t, "p1.jpg", photos[0].Filename())
+	assert.Equal(t, "", photos[0].ThumbnailUrl())
+	info, err := photos[0].ExifInfo()
+	assert.NoError(t, err)
+	assert.Equal(t, "Red square", info.Description())
+	assert.Equal(t, time.Date(2021, 7, 3, 15, 4, 5, 0, time.UTC), info.Created())
+	assert.Nil(t, info.GPS())
+
+	assert.Equal(t, sources.MediaVideo, photos[1].Kind())
+	assert.Equal(t, "https://lh3.googleusercontent.com/v1=dv", photos[1].Url())
+	assert.Equal(t, "https://lh3.googleusercontent.com/v1=w1280-h1280", photos[1].ThumbnailUrl())
+
+	fetcher, err = g.AlbumPhotos(libraryAlbumID)
+	assert.NoError(t, err)
+	assert.True(t, fetcher.Next())
+	assert.Equal(t, "All Google Photos", fetcher.Item().AlbumName())
+	info, _ = fetcher.Item().ExifInfo()
+	assert.True(t, info.Created().IsZero())
+	assert.False(t, fetcher.Next())
+
+	_, err = g.AlbumPhotos("missing")
+	assert.Error(t, err)
+}
+
+func TestGooglePhotos_AlbumPhotosNotInAlbum(t *testing.T) {
+	fakeLibrary(t, "ya29.token", map[string]func(r *http.Request) interface{}{
+		"GET /v1/albums": func(r *http.Request) interface{} {
+			return map[string]interface{}{"albums": []map[string]string{{"id": "a1", "title": "Summer"}}}
+		},
+		"POST /v1/mediaItems:search": func(r *http.Request) interface{} {
+			body := map[string]interface{}{}
+			json.NewDecoder(r.Body).Decode(&body)
+			if body["pageToken"] == nil {
+				return map[string]interface{}{"mediaItems": []interface{}{mediaItem("p1", "image/jpeg")}, "nextPageToken": "next"}
+			}
+			return map[string]interface{}{"mediaItems": []interface{}{mediaItem("p3", "image/jpeg")}}
+		},
+		"GET /v1/mediaItems": func(r *http.Request) interface{} {
+			if r.URL.Query().Get("pageToken") == "" {
+				return map[string]interface{}{"mediaItems": []interface{}{mediaItem("p1", "image/jpeg"), mediaItem("p2", "image/jpeg")}, "nextPageToken": "next"}
+			}
+			return map[string]interface{}{"mediaItems": []interface{}{mediaItem("p3", "image/jpeg"), mediaItem("p4", "image/jpeg")}}
+		},
+	})
+
+	fetcher, err := New("ya29.token").AlbumPhotos(notInAlbumID)
+	assert.NoError(t, err)
+	names := []string{}
+	for fetcher.Next() {
+		assert.Equal(t, "Not in an album", fetcher.Item().AlbumName())
+		names = append(names, fetcher.Item().(*PhotoItem).Filename())
+	}
+	assert.Equal(t, []string{"p2.jpg", "p4.jpg"}, names)
+}
+
+func TestGooglePhotos_refreshToken(t *testing.T) {
+	fakeLibrary(t, "ya29.fresh", map[string]func(r *http.Request) interface{}{
+		"GET /v1/albums": func(r *http.Request) interface{} {
+			return map[string]interface{}{"albums": []map[string]string{{"id": "a1", "title": "Summer"}}}
+		},
+	})
+	constructor := NewServiceWithClient("client", "secret").Constructor()
+
+	g := constructor("1//refresh").(*GooglePhotos)
+	albums, err := g.AllAlbums()
+	assert.NoError(t, err)
+	assert.Len(t, albums, 3)
+
+	// the access token is rejected before it expires, it's refreshed and the request is repeated
+	g.api.tokens.accessToken = "ya29.revoked"
+	_, err = g.AllAlbums()
+	assert.NoError(t, err)
+	assert.Equal(t, "ya29.fresh", g.api.tokens.accessToken)
+
+	_, err = constructor("1//revoked").AllAlbums()
+	assert.IsType(t, &sources.AccessError{}, err)
+	assert.True(t, errors.Is(err, sources.ErrTokenExpired))
+
+	// access tokens are used as they are even if the client is set
+	_, err = constructor("ya29.fresh").AllAlbums()
+	assert.NoError(t, err)
+}
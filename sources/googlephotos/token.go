@@ -0,0 +1,102 @@
+package googlephotos
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tokenUrl is a variable to point tests to a fake server
+var tokenUrl = "https://oauth2.googleapis.com/token"
+
+// refreshTokenPrefix starts refresh tokens of google, other creds are access tokens
+const refreshTokenPrefix = "1//"
+
+// defaultExpiresIn is the lifetime of access tokens in seconds if the response has none
+const defaultExpiresIn = 3600
+
+// expiryDelta refreshes access tokens a bit earlier, so requests don't fail in the middle of a download
+const expiryDelta = time.Minute
+
+// tokenSource provides access tokens: a given access token is used as it is,
+// a refresh token is exchanged for access tokens if client credentials of the app are set
+type tokenSource struct {
+	mu           sync.Mutex
+	accessToken  string
+	refreshToken string
+	expires      time.Time
+	clientID     string
+	clientSecret string
+}
+
+func newTokenSource(creds, clientID, clientSecret string) *tokenSource {
+	if strings.HasPrefix(creds, refreshTokenPrefix) && clientID != "" {
+		return &tokenSource{refreshToken: creds, clientID: clientID, clientSecret: clientSecret}
+	}
+	return &tokenSource{accessToken: creds}
+}
+
+func (ts *tokenSource) refreshable() bool {
+	return ts.refreshToken != ""
+}
+
+// invalidate makes the next call of token refresh the access token
+func (ts *tokenSource) invalidate() {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.expires = time.Time{}
+	ts.accessToken = ""
+}
+
+func (ts *tokenSource) token() (string, error) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if !ts.refreshable() || ts.accessToken != "" && time.Now().Add(expiryDelta).Before(ts.expires) {
+		return ts.accessToken, nil
+	}
+	if err := ts.refresh(); err != nil {
+		return "", err
+	}
+	return ts.accessToken, nil
+}
+
+// refresh exchanges the refresh token for a new access token
+func (ts *tokenSource) refresh() error {
+	resp, err := http.PostForm(tokenUrl, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {ts.refreshToken},
+		"client_id":     {ts.clientID},
+		"client_secret": {ts.clientSecret},
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	token := &struct {
+		AccessToken      string `json:"access_token"`
+		ExpiresIn        int    `json:"expires_in"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(token); err != nil && resp.StatusCode == http.StatusOK {
+		return fmt.Errorf("google photos: error decoding token; %w", err)
+	}
+	if resp.StatusCode != http.StatusOK || token.AccessToken == "" {
+		// invalid_grant means the refresh token is revoked or expired
+		message := strings.TrimSpace(token.Error + " " + token.ErrorDescription)
+		if message == "" {
+			message = http.StatusText(resp.StatusCode)
+		}
+		return &Error{Code: http.StatusUnauthorized, Message: message, Status: "UNAUTHENTICATED"}
+	}
+	if token.ExpiresIn <= 0 {
+		token.ExpiresIn = defaultExpiresIn
+	}
+	ts.accessToken = token.AccessToken
+	ts.expires = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	return nil
+}
@@ -0,0 +1,58 @@
+package sources
+
+import (
+	"fmt"
+	"math"
+)
+
+// GPS is a location where a photo was taken
+type GPS struct {
+	Latitude  float64
+	Longitude float64
+	// Altitude in meters above sea level, nil if unknown
+	Altitude *float64
+	// Accuracy is a horizontal positioning error in meters, nil if unknown
+	Accuracy *float64
+}
+
+// NewGPS validates coordinates and returns a location,
+// (0,0) is what most APIs return for photos without location, so it's treated as no location at all
+func NewGPS(latitude, longitude float64) (*GPS, error) {
+	if latitude == 0 && longitude == 0 {
+		return nil, nil
+	}
+	gps := &GPS{Latitude: latitude, Longitude: longitude}
+	if err := gps.Validate(); err != nil {
+		return nil, err
+	}
+	return gps, nil
+}
+
+// WithAltitude sets altitude in meters
+func (g *GPS) WithAltitude(altitude float64) *GPS {
+	g.Altitude = &altitude
+	return g
+}
+
+// WithAccuracy sets horizontal positioning error in meters
+func (g *GPS) WithAccuracy(accuracy float64) *GPS {
+	g.Accuracy = &accuracy
+	return g
+}
+
+// Validate checks ranges of coordinates
+func (g *GPS) Validate() error {
+	if math.IsNaN(g.Latitude) || g.Latitude < -90 || g.Latitude > 90 {
+		return fmt.Errorf("gps: latitude %v is out of range", g.Latitude)
+	}
+	if math.IsNaN(g.Longitude) || g.Longitude < -180 || g.Longitude > 180 {
+		return fmt.Errorf("gps: longitude %v is out of range", g.Longitude)
+	}
+	if g.Altitude != nil && (math.IsNaN(*g.Altitude) || math.IsInf(*g.Altitude, 0)) {
+		return fmt.Errorf("gps: altitude %v is invalid", *g.Altitude)
+	}
+	if g.Accuracy != nil && (math.IsNaN(*g.Accuracy) || *g.Accuracy < 0) {
+		return fmt.Errorf("gps: accuracy %v is invalid", *g.Accuracy)
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+package instagram
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// IDs of albums derived from posts: all posts, posts of a year, of a month and posts with a hashtag
+const (
+	allAlbumID    = "all_photos_and_videos"
+	yearPrefix    = "year_"
+	monthPrefix   = "month_"
+	hashtagPrefix = "tag_"
+)
+
+var hashtagRe = regexp.MustCompile(`#([\p{L}\p{N}_]+)`)
+
+// hashtags returns lowercased unique hashtags of the caption, instagram treats them case insensitively
+func hashtags(caption string) []string {
+	tags := []string{}
+	seen := map[string]bool{}
+	for _, match := range hashtagRe.FindAllStringSubmatch(caption, -1) {
+		tag := strings.ToLower(match[1])
+		if !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// mediaTime parses timestamp of the media, zero time means it's unknown
+func mediaTime(media *MediaItem) time.Time {
+	if media.Timestamp == "" {
+		return time.Time{}
+	}
+	t, err := time.Parse("2006-01-02T15:04:05-0700", media.Timestamp)
+	if err != nil {
+		return time.Time{}
+	}
+	return t.UTC()
+}
+
+// albumFilter selects posts of an album, folder is a subfolder of the username folder
+type albumFilter struct {
+	folder string
+	match  func(media *MediaItem) bool
+}
+
+func parseAlbumID(albumID string) (*albumFilter, error) {
+	switch {
+	case albumID == allAlbumID:
+		return &albumFilter{match: func(*MediaItem) bool { return true }}, nil
+	case strings.HasPrefix(albumID, yearPrefix):
+		year := strings.TrimPrefix(albumID, yearPrefix)
+		if _, err := time.Parse("2006", year); err != nil {
+			return nil, fmt.Errorf("wrong album %q: %w", albumID, err)
+		}
+		return &albumFilter{folder: year, match: func(media *MediaItem) bool {
+			t := mediaTime(media)
+			return !t.IsZero() && t.Format("2006") == year
+		}}, nil
+	case strings.HasPrefix(albumID, monthPrefix):
+		month := strings.TrimPrefix(albumID, monthPrefix)
+		if _, err := time.Parse("2006-01", month); err != nil {
+			return nil, fmt.Errorf("wrong album %q: %w", albumID, err)
+		}
+		return &albumFilter{folder: month, match: func(media *MediaItem) bool {
+			t := mediaTime(media)
+			return !t.IsZero() && t.Format("2006-01") == month
+		}}, nil
+	case strings.HasPrefix(albumID, hashtagPrefix) && len(albumID) > len(hashtagPrefix):
+		tag := strings.ToLower(strings.TrimPrefix(albumID, hashtagPrefix))
+		return &albumFilter{folder: "#" + tag, match: func(media *MediaItem) bool {
+			for _, t := range hashtags(media.Caption) {
+				if t == tag {
+					return true
+				}
+			}
+			return false
+		}}, nil
+	}
+	return nil, fmt.Errorf("no such an album %q", albumID)
+}
+
+// derivedAlbum collects posts of a year, a month or a hashtag
+type derivedAlbum struct {
+	id      string
+	title   string
+	cover   string
+	created string
+	count   int
+}
+
+func (a *derivedAlbum) add(media *MediaItem) {
+	// media is listed from the newest post, so the cover and date are of the latest one
+	if a.count == 0 {
+		a.cover = media.MediaUrl
+		if media.MediaType == VIDEO_TYPE && media.ThumbnailUrl != "" {
+			a.cover = media.ThumbnailUrl
+		}
+		a.created = media.Timestamp
+	}
+	a.count++
+}
+
+func (a *derivedAlbum) toMap() map[string]string {
+	return map[string]string{
+		"thumb":   a.cover,
+		"title":   a.title,
+		"id":      a.id,
+		"created": a.created,
+		"size":    fmt.Sprint(a.count),
+		"derived": fmt.Sprint(a.id != allAlbumID),
+	}
+}
+
+// albumGroups groups posts by year, month and hashtag
+type albumGroups struct {
+	years  map[string]*derivedAlbum
+	months map[string]*derivedAlbum
+	tags   map[string]*derivedAlbum
+}
+
+func newAlbumGroups() *albumGroups {
+	return &albumGroups{
+		years:  map[string]*derivedAlbum{},
+		months: map[string]*derivedAlbum{},
+		tags:   map[string]*derivedAlbum{},
+	}
+}
+
+func group(groups map[string]*derivedAlbum, id, title string) *derivedAlbum {
+	album, ok := groups[id]
+	if !ok {
+		album = &derivedAlbum{id: id, title: title}
+		groups[id] = album
+	}
+	return album
+}
+
+func (g *albumGroups) add(media *MediaItem) {
+	if t := mediaTime(media); !t.IsZero() {
+		group(g.years, yearPrefix+t.Format("2006"), t.Format("2006")).add(media)
+		group(g.months, monthPrefix+t.Format("2006-01"), t.Format("January 2006")).add(media)
+	}
+	for _, tag := range hashtags(media.Caption) {
+		group(g.tags, hashtagPrefix+tag, "#"+tag).add(media)
+	}
+}
+
+// albums returns years and months from the latest one, then hashtags from the most used one
+func (g *albumGroups) albums() []map[string]string {
+	sorted := func(groups map[string]*derivedAlbum, less func(a, b *derivedAlbum) bool) []map[string]string {
+		list := make([]*derivedAlbum, 0, len(groups))
+		for _, album := range groups {
+			list = append(list, album)
+		}
+		sort.Slice(list, func(i, j int) bool { return less(list[i], list[j]) })
+		albums := make([]map[string]string, 0, len(list))
+		for _, album := range list {
+			albums = append(albums, album.toMap())
+		}
+		return albums
+	}
+	latest := func(a, b *derivedAlbum) bool { return a.id > b.id }
+	popular := func(a, b *derivedAlbum) bool {
+		if a.count != b.count {
+			return a.count > b.count
+		}
+		return a.id < b.id
+	}
+	albums := sorted(g.years, latest)
+	albums = append(albums, sorted(g.months, latest)...)
+	return append(albums, sorted(g.tags, popular)...)
+}
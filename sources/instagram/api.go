@@ -1,31 +1,21 @@
 package instagram
 
 import (
-	"encoding/json"
-	"fmt"
-	"io"
-	"net/http"
 	"net/url"
 	"strings"
+
+	"github.com/Gasoid/photoDumper/sources/internal/graph"
 )
 
+// graphUrl is a variable to point tests to a fake server
+var graphUrl = "https://graph.instagram.com/v14.0/"
+
 const (
-	graphUrl            = "https://graph.instagram.com/v14.0/"
 	IMAGE_TYPE          = "IMAGE"
 	VIDEO_TYPE          = "VIDEO"
 	CAROUSEL_ALBUM_TYPE = "CAROUSEL_ALBUM"
 )
 
-type Cursors struct {
-	After  string `json:"after"`
-	Before string `json:"before"`
-}
-
-type Paging struct {
-	Next    string   `json:"next"`
-	Cursors *Cursors `json:"cursors"`
-}
-
 type UserResponse struct {
 	AccountType string `json:"account_type"`
 	ID          string `json:"id"`
@@ -44,35 +34,8 @@ type MediaItem struct {
 	Username     string `json:"username"`
 }
 
-type PagingResponse struct {
-	Data   []*MediaItem `json:"data"`
-	Paging *Paging      `json:"paging"`
-	cur    int
-	next   int
-	api    *InstagramApi
-}
-
-func (p *PagingResponse) Item() *MediaItem {
-	return p.Data[p.cur]
-}
-
-func (p *PagingResponse) Next() bool {
-	p.cur = p.next
-	if len(p.Data) == p.cur {
-		if p.Paging.Next == "" {
-			return false
-		}
-		p.cur = 0
-		p.next = 0
-		if err := p.api.next(p.Paging.Next, p); err != nil {
-			return false
-		}
-	}
-	p.next++
-	return true
-}
-
-// type apiResponse map[string]interface{}
+// PagingResponse is a list of media, Next requests the following pages
+type PagingResponse = graph.List[*MediaItem]
 
 type InstagramApi struct {
 	access_token string
@@ -84,14 +47,14 @@ func NewAPI(token string) *InstagramApi {
 	}
 }
 
-func (api *InstagramApi) Me(fields ...string) *UserResponse {
+func (api *InstagramApi) Me(fields ...string) (*UserResponse, error) {
 	params := url.Values{}
 	if len(fields) > 0 {
 		params.Set("fields", strings.Join(fields, ","))
 	}
 	r := &UserResponse{}
-	api.get("me", params, r)
-	return r
+	err := api.get("me", params, r)
+	return r, err
 }
 
 func (api *InstagramApi) MeMedia(fields ...string) (*PagingResponse, error) {
@@ -103,72 +66,32 @@ func (api *InstagramApi) UserMedia(userID string, fields ...string) (*PagingResp
 	if len(fields) > 0 {
 		params.Set("fields", strings.Join(fields, ","))
 	}
-	r := &PagingResponse{}
-	err := api.get(userID+"/media", params, r)
-	return r, err
+	return graph.GetList[*MediaItem](api.client(), graphUrl+userID+"/media", params)
 }
 
-func buildGetRequest(urlStr string, params url.Values) (*http.Request, error) {
-	u, err := url.Parse(urlStr)
-	if err != nil {
-		return nil, err
-	}
-
-	// If we are getting, then we can't merge query params
-	if params != nil {
-		if u.RawQuery != "" {
-			return nil, fmt.Errorf("Cannot merge query params in urlStr and params")
-		}
-		u.RawQuery = params.Encode()
+// MediaChildren returns images and videos of a carousel album
+func (api *InstagramApi) MediaChildren(mediaID string, fields ...string) (*PagingResponse, error) {
+	params := url.Values{}
+	if len(fields) > 0 {
+		params.Set("fields", strings.Join(fields, ","))
 	}
-
-	return http.NewRequest(http.MethodGet, u.String(), nil)
+	return graph.GetList[*MediaItem](api.client(), graphUrl+mediaID+"/children", params)
 }
 
-func (api *InstagramApi) next(urlStr string, r interface{}) error {
-	req, err := buildGetRequest(urlStr, nil)
-	if err != nil {
-		return err
-	}
-	return api.do(req, r)
+// client requests the Graph API with the current token, it's replaced by long-lived tokens
+func (api *InstagramApi) client() *graph.Client {
+	return &graph.Client{Service: "instagram", Token: api.access_token}
 }
 
 func (api *InstagramApi) get(path string, params url.Values, r interface{}) error {
-	u := graphUrl + path
-	params.Set("access_token", api.access_token)
-	req, err := buildGetRequest(u, params)
-	if err != nil {
-		return err
-	}
-	return api.do(req, r)
-}
-
-func (api *InstagramApi) do(req *http.Request, r interface{}) error {
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return err
-	}
-	defer func() {
-		io.CopyN(io.Discard, resp.Body, 512)
-		resp.Body.Close()
-	}()
-
-	if resp.StatusCode == 401 {
-		return fmt.Errorf("auth error %d", resp.StatusCode)
-	}
-
-	if resp.StatusCode != 200 {
-		return fmt.Errorf("access error %d", resp.StatusCode)
-	}
-
-	return decodeResponse(resp.Body, r)
+	return api.client().Get(graphUrl+path, params, r)
 }
 
-func decodeResponse(body io.Reader, to interface{}) error {
-	err := json.NewDecoder(body).Decode(to)
+// GraphError is the error envelope of the Graph API
+type GraphError = graph.Error
 
-	if err != nil {
-		return fmt.Errorf("instagram: error decoding body; %s", err.Error())
-	}
-	return nil
+// apiError converts Graph API errors: OAuth errors become sources.AccessError,
+// rate limits become sources.RateLimitError, other errors are returned as is
+func apiError(err error, text string) error {
+	return graph.APIError(err, text)
 }
@@ -0,0 +1,113 @@
+package instagram
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Gasoid/photoDumper/sources"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_apiError(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		access  bool
+		expired bool
+		rate    bool
+		message string
+	}{
+		{
+			name:    "expired",
+			status:  http.StatusBadRequest,
+			body:    `{"error":{"message":"Session has expired","type":"OAuthException","code":190,"error_subcode":463,"fbtrace_id":"AbCd"}}`,
+			access:  true,
+			expired: true,
+			message: "instagram: Session has expired (status 400, code 190, subcode 463, fbtrace_id AbCd)",
+		},
+		{
+			name:    "invalid token",
+			status:  http.StatusBadRequest,
+			body:    `{"error":{"message":"Invalid OAuth access token","type":"OAuthException","code":190,"fbtrace_id":"AbCd"}}`,
+			access:  true,
+			message: "instagram: Invalid OAuth access token (status 400, code 190, fbtrace_id AbCd)",
+		},
+		{
+			name:    "permission",
+			status:  http.StatusForbidden,
+			body:    `{"error":{"message":"Permissions error","type":"OAuthException","code":200}}`,
+			access:  true,
+			message: "instagram: Permissions error (status 403, code 200)",
+		},
+		{
+			name:    "rate limit",
+			status:  http.StatusForbidden,
+			body:    `{"error":{"message":"Application request limit reached","type":"OAuthException","code":4}}`,
+			rate:    true,
+			message: "instagram: Application request limit reached (status 403, code 4)",
+		},
+		{
+			name:    "unknown error",
+			status:  http.StatusInternalServerError,
+			body:    `{"error":{"message":"An unknown error has occurred.","type":"OAuthException","code":1}}`,
+			message: "instagram: An unknown error has occurred. (status 500, code 1)",
+		},
+		{
+			name:    "server error",
+			status:  http.StatusInternalServerError,
+			body:    `{"error":{"message":"Service temporarily unavailable","type":"ServiceException","code":2}}`,
+			message: "instagram: Service temporarily unavailable (status 500, code 2)",
+		},
+		{
+			name:    "no envelope",
+			status:  http.StatusUnauthorized,
+			body:    `unauthorized`,
+			access:  true,
+			message: "instagram: Unauthorized (status 401, code 0)",
+		},
+		{
+			name:    "too many requests",
+			status:  http.StatusTooManyRequests,
+			body:    ``,
+			rate:    true,
+			message: "instagram: Too Many Requests (status 429, code 0)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+			old := graphUrl
+			graphUrl = server.URL + "/"
+			defer func() { graphUrl = old }()
+
+			_, err := NewAPI("token").Me("id")
+			var graphErr *GraphError
+			assert.True(t, errors.As(err, &graphErr))
+			assert.Equal(t, tt.message, err.Error())
+
+			err = apiError(err, "can't get user")
+			var accessErr *sources.AccessError
+			assert.Equal(t, tt.access, errors.As(err, &accessErr))
+			if tt.access {
+				assert.Equal(t, tt.expired, accessErr.Expired())
+			}
+			var rateErr *sources.RateLimitError
+			assert.Equal(t, tt.rate, errors.As(err, &rateErr))
+		})
+	}
+}
+
+func TestInstagram_AllAlbumsMeError(t *testing.T) {
+	fakeGraph(t, map[string]interface{}{
+		"me/media": map[string]interface{}{"data": []map[string]string{}},
+	})
+	_, err := New("token").AllAlbums()
+	assert.Error(t, err)
+}
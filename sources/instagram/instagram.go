@@ -2,6 +2,9 @@ package instagram
 
 import (
 	"fmt"
+	"log"
+	"path"
+	"sync"
 	"time"
 
 	"github.com/Gasoid/photoDumper/sources"
@@ -11,6 +14,7 @@ type PhotoItem struct {
 	url       string
 	albumName string
 	created   time.Time
+	media     *MediaItem
 }
 
 func (f *PhotoItem) Url() string {
@@ -21,15 +25,51 @@ func (f *PhotoItem) AlbumName() string {
 	return f.albumName
 }
 
+func (f *PhotoItem) Kind() sources.MediaKind {
+	if f.media != nil && f.media.MediaType == VIDEO_TYPE {
+		return sources.MediaVideo
+	}
+	return sources.MediaImage
+}
+
+// Filename is ID of the media, cdn urls have meaningless basenames
+func (f *PhotoItem) Filename() string {
+	if f.media == nil {
+		return ""
+	}
+	return f.media.ID
+}
+
+// ThumbnailUrl returns a preview of a video
+func (f *PhotoItem) ThumbnailUrl() string {
+	if f.media == nil || f.media.MediaType != VIDEO_TYPE {
+		return ""
+	}
+	return f.media.ThumbnailUrl
+}
+
+// Metadata returns the media object as it is received from instagram api
+func (f *PhotoItem) Metadata() interface{} {
+	return f.media
+}
+
 // It's setting EXIF data for the downloaded file.
 func (f *PhotoItem) ExifInfo() (sources.ExifInfo, error) {
 	exif := &exifInfo{
-		description: fmt.Sprintf("Dumped by photoDumper. Source is vk. Username: %s", f.albumName),
+		description: f.description(),
 		created:     f.created,
 	}
 	return exif, nil
 }
 
+// description names the source and the author of the post
+func (f *PhotoItem) description() string {
+	if f.media == nil || f.media.Username == "" {
+		return "Dumped by photoDumper. Source is instagram"
+	}
+	return fmt.Sprintf("Dumped by photoDumper. Source is instagram. Username: %s", f.media.Username)
+}
+
 type exifInfo struct {
 	description string
 	created     time.Time
@@ -43,11 +83,14 @@ func (e *exifInfo) Created() time.Time {
 	return e.created
 }
 
-func (e *exifInfo) GPS() []float64 {
+func (e *exifInfo) GPS() *sources.GPS {
 	return nil
 }
 
-type service struct{}
+type service struct {
+	store        TokenStore
+	clientSecret string
+}
 
 func (s *service) Kind() sources.Kind {
 	return sources.KindSource
@@ -58,72 +101,226 @@ func (s *service) Key() string {
 }
 
 func (s *service) Constructor() func(creds string) sources.Source {
-	return New
+	if s.store == nil {
+		return New
+	}
+	return func(creds string) sources.Source {
+		ig := New(creds).(*Instagram)
+		ig.tokens = &tokenManager{store: s.store, clientSecret: s.clientSecret}
+		return ig
+	}
 }
 
 func NewService() sources.ServiceSource {
 	return &service{}
 }
 
+// NewServiceWithTokens keeps long-lived tokens in the store and refreshes them before they expire,
+// short-lived tokens are exchanged for long-lived ones if clientSecret of the app is provided
+func NewServiceWithTokens(store TokenStore, clientSecret string) sources.ServiceSource {
+	return &service{store: store, clientSecret: clientSecret}
+}
+
 func New(creds string) sources.Source {
 	api := &InstagramApi{access_token: creds}
 	return &Instagram{api: api}
 }
 
 type Instagram struct {
-	api *InstagramApi
+	api    *InstagramApi
+	tokens *tokenManager
 }
 
-func (ig *Instagram) AllAlbums() ([]map[string]string, error) {
-	resp := ig.api.Me("id", "username", "media_count")
-	media, err := ig.api.MeMedia("id", "media_url", "timestamp", "caption")
+// authorize replaces the token given by user with the stored long-lived one, refreshing it if it's needed
+func (ig *Instagram) authorize() error {
+	if ig.tokens == nil {
+		return nil
+	}
+	token, err := ig.tokens.token(ig.api.access_token)
 	if err != nil {
-		return nil, &sources.AccessError{Err: err, Text: "token is invalid?"}
+		return &sources.AccessError{Err: err, Text: "token has expired, please log in again"}
 	}
-	album := map[string]string{}
-	for media.Next() {
-		album = map[string]string{
-			"thumb":   media.Item().MediaUrl,
-			"title":   "All Instagram photos and videos",
-			"id":      "all_photos_and_videos",
-			"created": media.Item().Timestamp,
-			"size":    fmt.Sprint(resp.MediaCount),
+	ig.api.access_token = token
+	ig.tokens = nil
+	return nil
+}
+
+// albumsTTL is how long albums of a token are cached, listing them pages through every post of the user
+const albumsTTL = 10 * time.Minute
+
+type cachedAlbums struct {
+	albums  []map[string]string
+	expires time.Time
+}
+
+// albumCache keeps albums by token, so refreshes of the list don't exhaust rate limits of the Graph API
+type albumCache struct {
+	mu     sync.Mutex
+	albums map[string]*cachedAlbums
+}
+
+func newAlbumCache() *albumCache {
+	return &albumCache{albums: map[string]*cachedAlbums{}}
+}
+
+var listedAlbums = newAlbumCache()
+
+// get returns a copy of cached albums of the token, expired albums are dropped
+func (c *albumCache) get(token string) ([]map[string]string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	for t, cached := range c.albums {
+		if now.After(cached.expires) {
+			delete(c.albums, t)
 		}
-		break
 	}
+	cached, ok := c.albums[token]
+	if !ok {
+		return nil, false
+	}
+	return copyAlbums(cached.albums), true
+}
+
+func (c *albumCache) put(token string, albums []map[string]string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.albums[token] = &cachedAlbums{albums: copyAlbums(albums), expires: time.Now().Add(albumsTTL)}
+}
 
-	albums := make([]map[string]string, 1)
-	albums[0] = album
+func copyAlbums(albums []map[string]string) []map[string]string {
+	list := make([]map[string]string, 0, len(albums))
+	for _, album := range albums {
+		a := make(map[string]string, len(album))
+		for k, v := range album {
+			a[k] = v
+		}
+		list = append(list, a)
+	}
+	return list
+}
+
+// AllAlbums returns all posts as a single album followed by albums derived from posts:
+// per year, per month and per hashtag of captions, albums are cached for albumsTTL
+func (ig *Instagram) AllAlbums() ([]map[string]string, error) {
+	if err := ig.authorize(); err != nil {
+		return nil, err
+	}
+	if albums, ok := listedAlbums.get(ig.api.access_token); ok {
+		return albums, nil
+	}
+	resp, err := ig.api.Me("id", "username", "media_count")
+	if err != nil {
+		return nil, apiError(err, "can't get user")
+	}
+	media, err := ig.api.MeMedia("id", "media_type", "media_url", "thumbnail_url", "timestamp", "caption")
+	if err != nil {
+		return nil, apiError(err, "can't get media")
+	}
+	all := &derivedAlbum{id: allAlbumID, title: "All Instagram photos and videos"}
+	groups := newAlbumGroups()
+	for media.Next() {
+		all.add(media.Item())
+		groups.add(media.Item())
+	}
+	if err := media.Err(); err != nil {
+		return nil, apiError(err, "can't get media")
+	}
+	album := all.toMap()
+	album["size"] = fmt.Sprint(resp.MediaCount)
+	albums := append([]map[string]string{album}, groups.albums()...)
+	listedAlbums.put(ig.api.access_token, albums)
 	return albums, nil
 }
 
+var mediaFields = []string{"id", "media_type", "media_url", "permalink", "thumbnail_url", "timestamp", "caption", "username"}
+
 type fetcher struct {
-	media *PagingResponse
+	api    *InstagramApi
+	media  *PagingResponse
+	filter *albumFilter
+	queue  []*PhotoItem
+	cur    *PhotoItem
 }
 
 func (f *fetcher) Next() bool {
-	return f.media.Next()
+	for len(f.queue) == 0 {
+		if !f.media.Next() {
+			if err := f.media.Err(); err != nil {
+				log.Println("instagram: media", err)
+			}
+			return false
+		}
+		if f.filter.match(f.media.Item()) {
+			f.queue = f.items(f.media.Item())
+		}
+	}
+	f.cur = f.queue[0]
+	f.queue = f.queue[1:]
+	return true
 }
 
 func (f *fetcher) Item() sources.Photo {
-	photo := f.media.Item()
-	date, err := time.Parse("2006-01-02T15:04:05-0700", photo.Timestamp)
+	return f.cur
+}
+
+// items returns the media itself or every child of a carousel album,
+// children are grouped into a subfolder named by ID of the post
+func (f *fetcher) items(media *MediaItem) []*PhotoItem {
+	folder := path.Join(media.Username, f.filter.folder)
+	if media.MediaType != CAROUSEL_ALBUM_TYPE {
+		return []*PhotoItem{newPhotoItem(media, folder)}
+	}
+	children, err := f.api.MediaChildren(media.ID, "id", "media_type", "media_url", "thumbnail_url", "timestamp")
 	if err != nil {
-		date = time.Now()
+		log.Println("instagram: children of", media.ID, err)
+		return []*PhotoItem{newPhotoItem(media, folder)}
+	}
+	albumName := path.Join(folder, media.ID)
+	items := []*PhotoItem{}
+	for children.Next() {
+		child := *children.Item()
+		child.Caption = media.Caption
+		child.Permalink = media.Permalink
+		child.Username = media.Username
+		if child.Timestamp == "" {
+			child.Timestamp = media.Timestamp
+		}
+		items = append(items, newPhotoItem(&child, albumName))
+	}
+	if len(items) == 0 {
+		return []*PhotoItem{newPhotoItem(media, folder)}
+	}
+	return items
+}
+
+func newPhotoItem(media *MediaItem, albumName string) *PhotoItem {
+	// zero date means unknown, it's better than a made up one
+	date := mediaTime(media)
+	if date.IsZero() && media.Timestamp != "" {
+		log.Println("instagram: media", media.ID, "wrong timestamp", media.Timestamp)
 	}
 	return &PhotoItem{
-		url:       photo.MediaUrl,
-		albumName: photo.Username,
+		url:       media.MediaUrl,
+		albumName: albumName,
 		created:   date,
-		// latitude:  photo.Lat,
-		// longitude: photo.Long,
+		media:     media,
 	}
 }
 
+// AlbumPhotos returns posts of an album listed by AllAlbums,
+// posts of a derived album are stored in a subfolder, e.g. username/2022-05
 func (ig *Instagram) AlbumPhotos(albumID string) (sources.ItemFetcher, error) {
-	media, err := ig.api.MeMedia("id", "media_url", "timestamp", "caption", "username")
+	filter, err := parseAlbumID(albumID)
+	if err != nil {
+		return nil, err
+	}
+	if err := ig.authorize(); err != nil {
+		return nil, err
+	}
+	media, err := ig.api.MeMedia(mediaFields...)
 	if err != nil {
-		return nil, &sources.AccessError{Err: err, Text: "token is invalid?"}
+		return nil, apiError(err, "can't get media")
 	}
-	return &fetcher{media: media}, nil
+	return &fetcher{api: ig.api, media: media, filter: filter}, nil
 }
@@ -0,0 +1,214 @@
+package instagram
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Gasoid/photoDumper/sources"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeGraph serves responses by path of the request
+func fakeGraph(t *testing.T, responses map[string]interface{}) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp, ok := responses[strings.TrimPrefix(r.URL.Path, "/")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	old, oldToken := graphUrl, tokenUrl
+	graphUrl = server.URL + "/"
+	tokenUrl = server.URL + "/"
+	t.Cleanup(func() {
+		graphUrl, tokenUrl = old, oldToken
+		listedAlbums = newAlbumCache()
+		server.Close()
+	})
+}
+
+func TestInstagram_AlbumPhotosCarousel(t *testing.T) {
+	fakeGraph(t, map[string]interface{}{
+		"me/media": map[string]interface{}{
+			"data": []map[string]string{
+				{"id": "1", "media_type": IMAGE_TYPE, "media_url": "https://cdn.example.com/1.jpg", "username": "gasoid", "timestamp": "2022-05-01T10:00:00+0000"},
+				{"id": "2", "media_type": CAROUSEL_ALBUM_TYPE, "media_url": "https://cdn.example.com/cover.jpg", "username": "gasoid", "caption": "trip", "timestamp": "2022-05-02T10:00:00+0000"},
+			},
+		},
+		"2/children": map[string]interface{}{
+			"data": []map[string]string{
+				{"id": "21", "media_type": IMAGE_TYPE, "media_url": "https://cdn.example.com/21.jpg"},
+				{"id": "22", "media_type": VIDEO_TYPE, "media_url": "https://cdn.example.com/22.mp4"},
+			},
+		},
+	})
+	ig := New("token")
+	cur, err := ig.AlbumPhotos("all_photos_and_videos")
+	assert.Nil(t, err)
+
+	type item struct{ url, album, caption string }
+	got := []item{}
+	for cur.Next() {
+		photo := cur.Item().(*PhotoItem)
+		got = append(got, item{photo.Url(), photo.AlbumName(), photo.media.Caption})
+	}
+	assert.Equal(t, []item{
+		{"https://cdn.example.com/1.jpg", "gasoid", ""},
+		{"https://cdn.example.com/21.jpg", "gasoid/2", "trip"},
+		{"https://cdn.example.com/22.mp4", "gasoid/2", "trip"},
+	}, got)
+}
+
+func TestInstagram_AlbumPhotosCarouselFallback(t *testing.T) {
+	fakeGraph(t, map[string]interface{}{
+		"me/media": map[string]interface{}{
+			"data": []map[string]string{
+				{"id": "2", "media_type": CAROUSEL_ALBUM_TYPE, "media_url": "https://cdn.example.com/cover.jpg", "username": "gasoid"},
+			},
+		},
+	})
+	ig := New("token")
+	cur, err := ig.AlbumPhotos("all_photos_and_videos")
+	assert.Nil(t, err)
+	assert.True(t, cur.Next())
+	assert.Equal(t, "https://cdn.example.com/cover.jpg", cur.Item().Url())
+	assert.Equal(t, "gasoid", cur.Item().AlbumName())
+	assert.False(t, cur.Next())
+}
+
+func TestPhotoItem_Video(t *testing.T) {
+	video := &PhotoItem{media: &MediaItem{ID: "17895695668004550", MediaType: VIDEO_TYPE, ThumbnailUrl: "https://cdn.example.com/thumb.jpg"}}
+	assert.Equal(t, sources.MediaVideo, video.Kind())
+	assert.Equal(t, "17895695668004550", video.Filename())
+	assert.Equal(t, "https://cdn.example.com/thumb.jpg", video.ThumbnailUrl())
+
+	image := &PhotoItem{media: &MediaItem{ID: "1", MediaType: IMAGE_TYPE, ThumbnailUrl: "https://cdn.example.com/thumb.jpg"}}
+	assert.Equal(t, sources.MediaImage, image.Kind())
+	assert.Equal(t, "", image.ThumbnailUrl())
+}
+
+func TestPhotoItem_ExifInfo(t *testing.T) {
+	photo := &PhotoItem{albumName: "gasoid/2022-05", media: &MediaItem{ID: "2", Username: "gasoid"}}
+	info, err := photo.ExifInfo()
+	assert.NoError(t, err)
+	assert.Equal(t, "Dumped by photoDumper. Source is instagram. Username: gasoid", info.Description())
+
+	info, err = (&PhotoItem{}).ExifInfo()
+	assert.NoError(t, err)
+	assert.Equal(t, "Dumped by photoDumper. Source is instagram", info.Description())
+}
+
+func Test_hashtags(t *testing.T) {
+	assert.Equal(t, []string{"travel", "море", "sun_set"}, hashtags("#Travel to the #море! #travel #sun_set"))
+	assert.Equal(t, []string{}, hashtags("no tags"))
+}
+
+var postsResponse = map[string]interface{}{
+	"me": map[string]interface{}{"id": "1", "username": "gasoid", "media_count": 3},
+	"me/media": map[string]interface{}{
+		"data": []map[string]string{
+			{"id": "3", "media_type": VIDEO_TYPE, "media_url": "https://cdn.example.com/3.mp4", "thumbnail_url": "https://cdn.example.com/3.jpg", "username": "gasoid", "caption": "#Sea", "timestamp": "2022-06-01T10:00:00+0000"},
+			{"id": "2", "media_type": IMAGE_TYPE, "media_url": "https://cdn.example.com/2.jpg", "username": "gasoid", "caption": "#sea #sun", "timestamp": "2022-05-02T10:00:00+0000"},
+			{"id": "1", "media_type": IMAGE_TYPE, "media_url": "https://cdn.example.com/1.jpg", "username": "gasoid", "timestamp": "2021-05-01T10:00:00+0000"},
+		},
+	},
+}
+
+func TestInstagram_AllAlbums(t *testing.T) {
+	fakeGraph(t, postsResponse)
+	ig := New("token")
+	albums, err := ig.AllAlbums()
+	assert.Nil(t, err)
+
+	type album struct{ id, title, thumb, size string }
+	got := []album{}
+	for _, a := range albums {
+		got = append(got, album{a["id"], a["title"], a["thumb"], a["size"]})
+	}
+	assert.Equal(t, []album{
+		{"all_photos_and_videos", "All Instagram photos and videos", "https://cdn.example.com/3.jpg", "3"},
+		{"year_2022", "2022", "https://cdn.example.com/3.jpg", "2"},
+		{"year_2021", "2021", "https://cdn.example.com/1.jpg", "1"},
+		{"month_2022-06", "June 2022", "https://cdn.example.com/3.jpg", "1"},
+		{"month_2022-05", "May 2022", "https://cdn.example.com/2.jpg", "1"},
+		{"month_2021-05", "May 2021", "https://cdn.example.com/1.jpg", "1"},
+		{"tag_sea", "#sea", "https://cdn.example.com/3.jpg", "2"},
+		{"tag_sun", "#sun", "https://cdn.example.com/2.jpg", "1"},
+	}, got)
+}
+
+func TestInstagram_AllAlbumsCache(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		resp, ok := postsResponse[strings.TrimPrefix(r.URL.Path, "/")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	old := graphUrl
+	graphUrl = server.URL + "/"
+	t.Cleanup(func() {
+		graphUrl = old
+		listedAlbums = newAlbumCache()
+		server.Close()
+	})
+
+	albums, err := New("token").AllAlbums()
+	assert.NoError(t, err)
+	listed := requests
+	// every source of a request is new, albums of the token are listed once
+	cached, err := New("token").AllAlbums()
+	assert.NoError(t, err)
+	assert.Equal(t, albums, cached)
+	assert.Equal(t, listed, requests)
+	cached[0]["title"] = "changed"
+
+	listedAlbums.albums["token"].expires = time.Now().Add(-time.Second)
+	albums, err = New("token").AllAlbums()
+	assert.NoError(t, err)
+	assert.Equal(t, "All Instagram photos and videos", albums[0]["title"])
+	assert.Equal(t, 2*listed, requests)
+}
+
+func TestInstagram_AlbumPhotos(t *testing.T) {
+	fakeGraph(t, postsResponse)
+	ig := New("token")
+	tests := []struct {
+		albumID string
+		want    []string
+		folder  string
+		wantErr bool
+	}{
+		{albumID: "all_photos_and_videos", want: []string{"3", "2", "1"}, folder: "gasoid"},
+		{albumID: "year_2022", want: []string{"3", "2"}, folder: "gasoid/2022"},
+		{albumID: "month_2021-05", want: []string{"1"}, folder: "gasoid/2021-05"},
+		{albumID: "tag_sea", want: []string{"3", "2"}, folder: "gasoid/#sea"},
+		{albumID: "tag_SUN", want: []string{"2"}, folder: "gasoid/#sun"},
+		{albumID: "year_abc", wantErr: true},
+		{albumID: "123", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.albumID, func(t *testing.T) {
+			cur, err := ig.AlbumPhotos(tt.albumID)
+			assert.Equal(t, tt.wantErr, err != nil)
+			if tt.wantErr {
+				return
+			}
+			got := []string{}
+			for cur.Next() {
+				photo := cur.Item().(*PhotoItem)
+				assert.Equal(t, tt.folder, photo.AlbumName())
+				got = append(got, photo.Filename())
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
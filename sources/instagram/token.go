@@ -0,0 +1,200 @@
+package instagram
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/Gasoid/photoDumper/sources"
+)
+
+// tokenUrl is the host of token endpoints, they are not versioned
+var tokenUrl = "https://graph.instagram.com/"
+
+// refreshBefore is how long before expiration a long-lived token is refreshed,
+// instagram refreshes only tokens which are at least 24 hours old
+const refreshBefore = 30 * 24 * time.Hour
+
+// ErrTokenNotFound is returned by TokenStore if there is no token for the key
+var ErrTokenNotFound = errors.New("token not found")
+
+// Token is a long-lived access token, it's valid for 60 days
+type Token struct {
+	AccessToken string    `json:"access_token"`
+	TokenType   string    `json:"token_type"`
+	ExpiresIn   int       `json:"expires_in"`
+	Expires     time.Time `json:"expires"`
+}
+
+// Expired reports whether the token can't be used anymore
+func (t *Token) Expired() bool {
+	return !t.Expires.IsZero() && time.Now().After(t.Expires)
+}
+
+// ExchangeToken exchanges the short-lived token of the api for a long-lived one, clientSecret is the secret of the app
+func (api *InstagramApi) ExchangeToken(clientSecret string) (*Token, error) {
+	params := url.Values{}
+	params.Set("grant_type", "ig_exchange_token")
+	params.Set("client_secret", clientSecret)
+	return api.requestToken("access_token", params)
+}
+
+// RefreshToken extends a long-lived token of the api for another 60 days
+func (api *InstagramApi) RefreshToken() (*Token, error) {
+	params := url.Values{}
+	params.Set("grant_type", "ig_refresh_token")
+	return api.requestToken("refresh_access_token", params)
+}
+
+func (api *InstagramApi) requestToken(path string, params url.Values) (*Token, error) {
+	token := &Token{}
+	if err := api.client().Get(tokenUrl+path, params, token); err != nil {
+		return nil, err
+	}
+	if token.AccessToken == "" {
+		return nil, errors.New("instagram: token is empty")
+	}
+	// zero Expires means the expiry is unknown
+	if token.ExpiresIn > 0 {
+		token.Expires = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	}
+	return token, nil
+}
+
+// TokenStore persists long-lived tokens, keys are derived from tokens given by users
+type TokenStore interface {
+	Get(key string) (*Token, error)
+	Put(key string, token *Token) error
+}
+
+// FileTokenStore keeps tokens in a JSON file readable by the owner only
+type FileTokenStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+func NewFileTokenStore(path string) *FileTokenStore {
+	return &FileTokenStore{path: path}
+}
+
+func (s *FileTokenStore) load() (map[string]*Token, error) {
+	tokens := map[string]*Token{}
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return tokens, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &tokens); err != nil {
+		return nil, fmt.Errorf("token store %s: %w", s.path, err)
+	}
+	return tokens, nil
+}
+
+func (s *FileTokenStore) Get(key string) (*Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tokens, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	token, ok := tokens[key]
+	if !ok {
+		return nil, ErrTokenNotFound
+	}
+	return token, nil
+}
+
+func (s *FileTokenStore) Put(key string, token *Token) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tokens, err := s.load()
+	if err != nil {
+		return err
+	}
+	tokens[key] = token
+	data, err := json.MarshalIndent(tokens, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return err
+	}
+	// the file is replaced atomically, a crash must not lose all tokens
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// tokenManager maps tokens given by users to stored long-lived tokens
+type tokenManager struct {
+	store        TokenStore
+	clientSecret string
+}
+
+// tokenKey doesn't keep tokens given by users in the store as is
+func tokenKey(creds string) string {
+	sum := sha256.Sum256([]byte(creds))
+	return hex.EncodeToString(sum[:])
+}
+
+// token returns an access token to be used instead of creds,
+// failed exchanges and refreshes are logged and creds or the stored token are used as is
+func (m *tokenManager) token(creds string) (string, error) {
+	key := tokenKey(creds)
+	stored, err := m.store.Get(key)
+	switch {
+	case errors.Is(err, ErrTokenNotFound):
+		stored = m.newToken(creds)
+		if stored == nil {
+			return creds, nil
+		}
+	case err != nil:
+		log.Println("instagram: token store", err)
+		return creds, nil
+	case stored.Expired():
+		return "", fmt.Errorf("%w at %s", sources.ErrTokenExpired, stored.Expires.Format(time.RFC3339))
+	case !stored.Expires.IsZero() && time.Until(stored.Expires) < refreshBefore:
+		refreshed, err := NewAPI(stored.AccessToken).RefreshToken()
+		if err != nil {
+			log.Println("instagram: refresh token", err)
+			return stored.AccessToken, nil
+		}
+		stored = refreshed
+	default:
+		return stored.AccessToken, nil
+	}
+	if err := m.store.Put(key, stored); err != nil {
+		log.Println("instagram: token store", err)
+	}
+	return stored.AccessToken, nil
+}
+
+// newToken exchanges a short-lived token or refreshes a long-lived one, it returns nil if both failed
+func (m *tokenManager) newToken(creds string) *Token {
+	api := NewAPI(creds)
+	if m.clientSecret != "" {
+		token, err := api.ExchangeToken(m.clientSecret)
+		if err == nil {
+			return token
+		}
+		log.Println("instagram: exchange token", err)
+	}
+	token, err := api.RefreshToken()
+	if err != nil {
+		log.Println("instagram: refresh token", err)
+		return nil
+	}
+	return token
+}
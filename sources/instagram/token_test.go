@@ -0,0 +1,215 @@
+package instagram
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Gasoid/photoDumper/sources"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeTokens exchanges "short" for "long" and refreshes "long" and "old" into "refreshed",
+// "unknown_expiry" is refreshed without expires_in,
+// any other token is invalid, "expired" is expired
+func fakeTokens(t *testing.T) *int {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		q := r.URL.Query()
+		token := q.Get("access_token")
+		var resp interface{}
+		switch {
+		case r.URL.Path == "/access_token" && q.Get("grant_type") == "ig_exchange_token" && token == "short" && q.Get("client_secret") == "secret":
+			resp = map[string]interface{}{"access_token": "long", "token_type": "bearer", "expires_in": 5184000}
+		case r.URL.Path == "/refresh_access_token" && q.Get("grant_type") == "ig_refresh_token" && (token == "long" || token == "old"):
+			resp = map[string]interface{}{"access_token": "refreshed", "token_type": "bearer", "expires_in": 5184000}
+		case r.URL.Path == "/refresh_access_token" && q.Get("grant_type") == "ig_refresh_token" && token == "unknown_expiry":
+			resp = map[string]interface{}{"access_token": "unknown_expiry", "token_type": "bearer"}
+		case token == "expired":
+			w.WriteHeader(http.StatusBadRequest)
+			resp = map[string]interface{}{"error": map[string]interface{}{"message": "Error validating access token: Session has expired", "type": "OAuthException", "code": 190, "error_subcode": 463}}
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			resp = map[string]interface{}{"error": map[string]interface{}{"message": "Invalid OAuth access token", "type": "OAuthException", "code": 190}}
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	old, oldToken := graphUrl, tokenUrl
+	graphUrl = server.URL + "/"
+	tokenUrl = server.URL + "/"
+	t.Cleanup(func() {
+		graphUrl, tokenUrl = old, oldToken
+		listedAlbums = newAlbumCache()
+		server.Close()
+	})
+	return &requests
+}
+
+func TestFileTokenStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config", "tokens.json")
+	store := NewFileTokenStore(path)
+	_, err := store.Get("key")
+	assert.ErrorIs(t, err, ErrTokenNotFound)
+
+	expires := time.Date(2022, 7, 1, 0, 0, 0, 0, time.UTC)
+	assert.NoError(t, store.Put("key", &Token{AccessToken: "long", Expires: expires}))
+	assert.NoError(t, store.Put("other", &Token{AccessToken: "other"}))
+	token, err := NewFileTokenStore(path).Get("key")
+	assert.NoError(t, err)
+	assert.Equal(t, "long", token.AccessToken)
+	assert.True(t, expires.Equal(token.Expires))
+
+	stat, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), stat.Mode().Perm())
+}
+
+func TestTokenManager_token(t *testing.T) {
+	tests := []struct {
+		name         string
+		creds        string
+		stored       *Token
+		clientSecret string
+		want         string
+		wantStored   string
+		wantErr      error
+	}{
+		{
+			name:         "exchange short-lived token",
+			creds:        "short",
+			clientSecret: "secret",
+			want:         "long",
+			wantStored:   "long",
+		},
+		{
+			name:       "refresh long-lived token",
+			creds:      "long",
+			want:       "refreshed",
+			wantStored: "refreshed",
+		},
+		{
+			name:  "token can't be refreshed",
+			creds: "fresh",
+			want:  "fresh",
+		},
+		{
+			name:       "stored token",
+			creds:      "short",
+			stored:     &Token{AccessToken: "long", Expires: time.Now().Add(50 * 24 * time.Hour)},
+			want:       "long",
+			wantStored: "long",
+		},
+		{
+			name:       "stored token expires soon",
+			creds:      "short",
+			stored:     &Token{AccessToken: "old", Expires: time.Now().Add(24 * time.Hour)},
+			want:       "refreshed",
+			wantStored: "refreshed",
+		},
+		{
+			name:       "stored token expires soon and can't be refreshed",
+			creds:      "short",
+			stored:     &Token{AccessToken: "fresh", Expires: time.Now().Add(24 * time.Hour)},
+			want:       "fresh",
+			wantStored: "fresh",
+		},
+		{
+			name:       "stored token with unknown expiry",
+			creds:      "short",
+			stored:     &Token{AccessToken: "unknown_expiry"},
+			want:       "unknown_expiry",
+			wantStored: "unknown_expiry",
+		},
+		{
+			name:       "stored token has expired",
+			creds:      "short",
+			stored:     &Token{AccessToken: "long", Expires: time.Now().Add(-time.Hour)},
+			wantStored: "long",
+			wantErr:    sources.ErrTokenExpired,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeTokens(t)
+			store := NewFileTokenStore(filepath.Join(t.TempDir(), "tokens.json"))
+			if tt.stored != nil {
+				store.Put(tokenKey(tt.creds), tt.stored)
+			}
+			m := &tokenManager{store: store, clientSecret: tt.clientSecret}
+			got, err := m.token(tt.creds)
+			assert.Equal(t, tt.wantErr != nil, err != nil)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			}
+			assert.Equal(t, tt.want, got)
+			stored, err := store.Get(tokenKey(tt.creds))
+			if tt.wantStored == "" {
+				assert.ErrorIs(t, err, ErrTokenNotFound)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantStored, stored.AccessToken)
+		})
+	}
+}
+
+func TestInstagramApi_RefreshTokenUnknownExpiry(t *testing.T) {
+	requests := fakeTokens(t)
+	token, err := NewAPI("unknown_expiry").RefreshToken()
+	assert.NoError(t, err)
+	assert.True(t, token.Expires.IsZero())
+	assert.False(t, token.Expired())
+
+	// the token isn't refreshed by every call
+	store := NewFileTokenStore(filepath.Join(t.TempDir(), "tokens.json"))
+	m := &tokenManager{store: store}
+	for i := 0; i < 3; i++ {
+		got, err := m.token("unknown_expiry")
+		assert.NoError(t, err)
+		assert.Equal(t, "unknown_expiry", got)
+	}
+	assert.Equal(t, 2, *requests)
+}
+
+func TestInstagram_AllAlbumsAccessError(t *testing.T) {
+	tests := []struct {
+		creds   string
+		expired bool
+	}{
+		{creds: "expired", expired: true},
+		{creds: "invalid", expired: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.creds, func(t *testing.T) {
+			fakeTokens(t)
+			ig := New(tt.creds)
+			_, err := ig.AllAlbums()
+			var e *sources.AccessError
+			assert.True(t, errors.As(err, &e))
+			assert.Equal(t, tt.expired, e.Expired())
+		})
+	}
+}
+
+func TestInstagram_authorize(t *testing.T) {
+	requests := fakeTokens(t)
+	store := NewFileTokenStore(filepath.Join(t.TempDir(), "tokens.json"))
+	store.Put(tokenKey("short"), &Token{AccessToken: "long", Expires: time.Now().Add(50 * 24 * time.Hour)})
+	ig := NewServiceWithTokens(store, "").Constructor()("short").(*Instagram)
+	assert.NoError(t, ig.authorize())
+	assert.Equal(t, "long", ig.api.access_token)
+	assert.Equal(t, 0, *requests)
+
+	ig = NewServiceWithTokens(store, "").Constructor()("expired").(*Instagram)
+	store.Put(tokenKey("expired"), &Token{AccessToken: "long", Expires: time.Now().Add(-time.Hour)})
+	err := ig.authorize()
+	var e *sources.AccessError
+	assert.True(t, errors.As(err, &e))
+	assert.True(t, e.Expired())
+}
@@ -0,0 +1,237 @@
+// Package graph has the parts of the Graph API shared by instagram and facebook:
+// requests with an access token, cursor pagination and the error envelope
+package graph
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/Gasoid/photoDumper/sources"
+)
+
+type Cursors struct {
+	After  string `json:"after"`
+	Before string `json:"before"`
+}
+
+type Paging struct {
+	Next    string   `json:"next"`
+	Cursors *Cursors `json:"cursors"`
+}
+
+// Client requests the Graph API of the service (instagram or facebook) with the access token
+type Client struct {
+	Service string
+	Token   string
+}
+
+// List is a page of a list, Next requests the following pages
+type List[T any] struct {
+	Data   []T     `json:"data"`
+	Paging *Paging `json:"paging"`
+	cur    int
+	next   int
+	client *Client
+	err    error
+}
+
+// GetList requests the first page of the list
+func GetList[T any](c *Client, urlStr string, params url.Values) (*List[T], error) {
+	l := &List[T]{client: c}
+	err := c.Get(urlStr, params, l)
+	return l, err
+}
+
+// Err returns the error which stopped Next
+func (l *List[T]) Err() error {
+	return l.err
+}
+
+func (l *List[T]) Item() T {
+	return l.Data[l.cur]
+}
+
+func (l *List[T]) Next() bool {
+	l.cur = l.next
+	if len(l.Data) == l.cur {
+		if l.Paging == nil || l.Paging.Next == "" {
+			return false
+		}
+		l.cur = 0
+		l.next = 0
+		next := l.Paging.Next
+		l.Data, l.Paging = nil, nil
+		if err := l.client.Next(next, l); err != nil {
+			l.err = err
+			return false
+		}
+		if len(l.Data) == 0 {
+			return false
+		}
+	}
+	l.next++
+	return true
+}
+
+func BuildGetRequest(urlStr string, params url.Values) (*http.Request, error) {
+	u, err := url.Parse(urlStr)
+	if err != nil {
+		return nil, err
+	}
+
+	// If we are getting, then we can't merge query params
+	if params != nil {
+		if u.RawQuery != "" {
+			return nil, fmt.Errorf("Cannot merge query params in urlStr and params")
+		}
+		u.RawQuery = params.Encode()
+	}
+
+	return http.NewRequest(http.MethodGet, u.String(), nil)
+}
+
+// Next requests an url of paging, it already has the token
+func (c *Client) Next(urlStr string, r interface{}) error {
+	req, err := BuildGetRequest(urlStr, nil)
+	if err != nil {
+		return err
+	}
+	return c.Do(req, r)
+}
+
+func (c *Client) Get(urlStr string, params url.Values, r interface{}) error {
+	if params == nil {
+		params = url.Values{}
+	}
+	params.Set("access_token", c.Token)
+	req, err := BuildGetRequest(urlStr, params)
+	if err != nil {
+		return err
+	}
+	return c.Do(req, r)
+}
+
+func (c *Client) Do(req *http.Request, r interface{}) error {
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		io.CopyN(io.Discard, resp.Body, 512)
+		resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return DecodeError(resp, c.Service)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(r); err != nil {
+		return fmt.Errorf("%s: error decoding body; %s", c.Service, err.Error())
+	}
+	return nil
+}
+
+// codes of the Graph API errors
+const (
+	codeTooManyCalls     = 4
+	codeUserTooManyCalls = 17
+	codePageTooManyCalls = 32
+	codeCustomRateLimit  = 613
+	codeAPISession       = 102
+	codePermission       = 10
+	codeInvalidToken     = 190
+	subcodeTokenExpired  = 463
+)
+
+// Error is the error envelope of the Graph API
+type Error struct {
+	Message    string `json:"message"`
+	Type       string `json:"type"`
+	Code       int    `json:"code"`
+	Subcode    int    `json:"error_subcode"`
+	FBTraceID  string `json:"fbtrace_id"`
+	StatusCode int    `json:"-"`
+	Service    string `json:"-"`
+}
+
+func (e *Error) Error() string {
+	text := fmt.Sprintf("%s: %s (status %d, code %d", e.Service, e.Message, e.StatusCode, e.Code)
+	if e.Subcode != 0 {
+		text += fmt.Sprintf(", subcode %d", e.Subcode)
+	}
+	if e.FBTraceID != "" {
+		text += ", fbtrace_id " + e.FBTraceID
+	}
+	return text + ")"
+}
+
+// Unwrap makes errors.Is(err, sources.ErrTokenExpired) work for expired tokens
+func (e *Error) Unwrap() error {
+	if e.Expired() {
+		return sources.ErrTokenExpired
+	}
+	return nil
+}
+
+// OAuth reports whether the token is invalid, expired or has no permissions
+func (e *Error) OAuth() bool {
+	switch e.Code {
+	case codeInvalidToken, codeAPISession, codePermission:
+		return true
+	}
+	// permission errors are 200-299
+	if e.Code >= 200 && e.Code < 300 {
+		return true
+	}
+	// type is OAuthException for most errors including unknown ones, so only statuses of responses without an envelope are used
+	return e.Code == 0 && (e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden)
+}
+
+func (e *Error) Expired() bool {
+	return e.Code == codeInvalidToken && e.Subcode == subcodeTokenExpired
+}
+
+// RateLimited reports whether the request can be retried later
+func (e *Error) RateLimited() bool {
+	switch e.Code {
+	case codeTooManyCalls, codeUserTooManyCalls, codePageTooManyCalls, codeCustomRateLimit:
+		return true
+	}
+	return e.StatusCode == http.StatusTooManyRequests
+}
+
+// DecodeError reads the error envelope, statuses without an envelope are reported as they are
+func DecodeError(resp *http.Response, service string) error {
+	envelope := &struct {
+		Error *Error `json:"error"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(envelope); err != nil || envelope.Error == nil {
+		return &Error{Message: http.StatusText(resp.StatusCode), StatusCode: resp.StatusCode, Service: service}
+	}
+	envelope.Error.StatusCode = resp.StatusCode
+	envelope.Error.Service = service
+	return envelope.Error
+}
+
+// APIError converts Graph API errors: OAuth errors become sources.AccessError,
+// rate limits become sources.RateLimitError, other errors are returned as is
+func APIError(err error, text string) error {
+	var e *Error
+	if !errors.As(err, &e) {
+		return fmt.Errorf("%s: %w", text, err)
+	}
+	switch {
+	case e.Expired():
+		return &sources.AccessError{Err: err, Text: "token has expired, please log in again"}
+	case e.RateLimited():
+		return &sources.RateLimitError{Err: err, Text: e.Service + " api limit is reached, try again later", RetryAfter: time.Hour}
+	case e.OAuth():
+		return &sources.AccessError{Err: err, Text: "token is invalid?"}
+	}
+	return fmt.Errorf("%s: %w", text, err)
+}
@@ -0,0 +1,36 @@
+package graph
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestList_Next(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("after") {
+		case "":
+			w.Write([]byte(`{"data":["a","b"],"paging":{"next":"` + server.URL + `/list?after=1"}}`))
+		case "1":
+			w.Write([]byte(`{"data":["c"],"paging":{"next":"` + server.URL + `/list?after=2"}}`))
+		default:
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"error":{"message":"Application request limit reached","type":"OAuthException","code":4}}`))
+		}
+	}))
+	defer server.Close()
+
+	list, err := GetList[string](&Client{Service: "facebook", Token: "token"}, server.URL+"/list", nil)
+	assert.NoError(t, err)
+	items := []string{}
+	for list.Next() {
+		items = append(items, list.Item())
+	}
+	assert.Equal(t, []string{"a", "b", "c"}, items)
+	if assert.Error(t, list.Err()) {
+		assert.Equal(t, "facebook: Application request limit reached (status 403, code 4)", list.Err().Error())
+	}
+}
@@ -0,0 +1,140 @@
+package localdir
+
+import (
+	"strings"
+	"time"
+
+	"github.com/Gasoid/photoDumper/sources"
+	goexif "github.com/dsoprea/go-exif/v2"
+	exifcommon "github.com/dsoprea/go-exif/v2/common"
+)
+
+// paths and IDs of tags read from files
+const (
+	ifdRoot = "IFD"
+	ifdExif = "IFD/Exif"
+	ifdGPS  = "IFD/GPSInfo"
+
+	imageDescriptionTag   = 0x010e
+	dateTimeTag           = 0x0132
+	dateTimeOriginalTag   = 0x9003
+	offsetTimeOriginalTag = 0x9011
+	gpsLatitudeRefTag     = 0x0001
+	gpsLatitudeTag        = 0x0002
+	gpsLongitudeRefTag    = 0x0003
+	gpsLongitudeTag       = 0x0004
+	gpsAltitudeRefTag     = 0x0005
+	gpsAltitudeTag        = 0x0006
+)
+
+type exifInfo struct {
+	description string
+	created     time.Time
+	gps         *sources.GPS
+}
+
+func (e *exifInfo) Description() string {
+	return e.description
+}
+
+func (e *exifInfo) Created() time.Time {
+	return e.created
+}
+
+func (e *exifInfo) GPS() *sources.GPS {
+	return e.gps
+}
+
+// readExif reads description, capture time and location of the file,
+// files without EXIF (e.g. videos or PNG) have empty info
+func readExif(path string) (*exifInfo, error) {
+	info := &exifInfo{}
+	raw, err := goexif.SearchFileAndExtractExif(path)
+	// go-exif wraps errors without Unwrap, so the message is compared
+	if err != nil && strings.Contains(err.Error(), goexif.ErrNoExif.Error()) {
+		return info, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	tags, err := goexif.GetFlatExifData(raw)
+	if err != nil {
+		return nil, err
+	}
+	values := map[string]map[uint16]interface{}{}
+	for _, tag := range tags {
+		if values[tag.IfdPath] == nil {
+			values[tag.IfdPath] = map[uint16]interface{}{}
+		}
+		values[tag.IfdPath][tag.TagId] = tag.Value
+	}
+	info.description, _ = values[ifdRoot][imageDescriptionTag].(string)
+	info.description = strings.TrimSpace(info.description)
+	info.created = captureTime(values)
+	info.gps = gps(values[ifdGPS])
+	return info, nil
+}
+
+// captureTime parses DateTimeOriginal or DateTime, OffsetTimeOriginal is used if it's present, otherwise time is UTC
+func captureTime(values map[string]map[uint16]interface{}) time.Time {
+	value, ok := values[ifdExif][dateTimeOriginalTag].(string)
+	if !ok {
+		value, _ = values[ifdRoot][dateTimeTag].(string)
+	}
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}
+	}
+	if offset, ok := values[ifdExif][offsetTimeOriginalTag].(string); ok {
+		if t, err := time.Parse("2006:01:02 15:04:05-07:00", value+strings.TrimSpace(offset)); err == nil {
+			return t
+		}
+	}
+	t, err := time.Parse("2006:01:02 15:04:05", value)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// coordinate converts degrees, minutes and seconds, south and west are negative
+func coordinate(value interface{}, ref interface{}) (float64, bool) {
+	rationals, ok := value.([]exifcommon.Rational)
+	if !ok || len(rationals) != 3 {
+		return 0, false
+	}
+	result := 0.0
+	for i, divider := range []float64{1, 60, 3600} {
+		if rationals[i].Denominator == 0 {
+			return 0, false
+		}
+		result += float64(rationals[i].Numerator) / float64(rationals[i].Denominator) / divider
+	}
+	if r, _ := ref.(string); r == "S" || r == "W" {
+		result = -result
+	}
+	return result, true
+}
+
+func gps(values map[uint16]interface{}) *sources.GPS {
+	lat, ok := coordinate(values[gpsLatitudeTag], values[gpsLatitudeRefTag])
+	if !ok {
+		return nil
+	}
+	long, ok := coordinate(values[gpsLongitudeTag], values[gpsLongitudeRefTag])
+	if !ok {
+		return nil
+	}
+	location, err := sources.NewGPS(lat, long)
+	if err != nil || location == nil {
+		return nil
+	}
+	if altitude, ok := values[gpsAltitudeTag].([]exifcommon.Rational); ok && len(altitude) == 1 && altitude[0].Denominator != 0 {
+		meters := float64(altitude[0].Numerator) / float64(altitude[0].Denominator)
+		if ref, ok := values[gpsAltitudeRefTag].([]byte); ok && len(ref) == 1 && ref[0] == 1 {
+			meters = -meters
+		}
+		location = location.WithAltitude(meters)
+	}
+	return location
+}
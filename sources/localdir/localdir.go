@@ -0,0 +1,257 @@
+package localdir
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Gasoid/photoDumper/sources"
+)
+
+// rootAlbumID is an album of files stored in the root directory itself
+const rootAlbumID = "."
+
+// mediaKinds are extensions of files which are dumped, sidecars and other files are skipped
+var mediaKinds = map[string]sources.MediaKind{
+	".jpg":  sources.MediaImage,
+	".jpeg": sources.MediaImage,
+	".png":  sources.MediaImage,
+	".gif":  sources.MediaImage,
+	".webp": sources.MediaImage,
+	".heic": sources.MediaImage,
+	".heif": sources.MediaImage,
+	".tif":  sources.MediaImage,
+	".tiff": sources.MediaImage,
+	".mp4":  sources.MediaVideo,
+	".m4v":  sources.MediaVideo,
+	".mov":  sources.MediaVideo,
+}
+
+// KindOf returns the kind of a media file by its extension, ok is false for other files
+func KindOf(name string) (sources.MediaKind, bool) {
+	kind, ok := mediaKinds[strings.ToLower(filepath.Ext(name))]
+	return kind, ok
+}
+
+// PhotoItem is a file of the directory, metadata is read from EXIF of the file
+type PhotoItem struct {
+	path      string
+	albumName string
+	kind      sources.MediaKind
+}
+
+// Url is a file url of the file, storages copy the file from Open
+func (f *PhotoItem) Url() string {
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(f.path)}).String()
+}
+
+func (f *PhotoItem) Open() (io.ReadCloser, error) {
+	return os.Open(f.path)
+}
+
+func (f *PhotoItem) AlbumName() string {
+	return f.albumName
+}
+
+func (f *PhotoItem) Kind() sources.MediaKind {
+	return f.kind
+}
+
+// Filename keeps the original name of the file
+func (f *PhotoItem) Filename() string {
+	return filepath.Base(f.path)
+}
+
+func (f *PhotoItem) ExifInfo() (sources.ExifInfo, error) {
+	return readExif(f.path)
+}
+
+// LocalDir is a source of photos already stored on disk, every directory is an album
+type LocalDir struct {
+	root string
+}
+
+// New takes the root directory as creds
+func New(creds string) sources.Source {
+	root := creds
+	if strings.HasPrefix(root, "~") {
+		if home, err := os.UserHomeDir(); err == nil {
+			root = filepath.Join(home, root[1:])
+		}
+	}
+	return &LocalDir{root: filepath.Clean(root)}
+}
+
+// albumPath returns the directory of the album, albums can't be outside of the root
+func (l *LocalDir) albumPath(albumID string) (string, error) {
+	if albumID == rootAlbumID {
+		return l.root, nil
+	}
+	if albumID == "" || path.Clean(albumID) != albumID || path.IsAbs(albumID) || strings.HasPrefix(albumID, "..") {
+		return "", fmt.Errorf("wrong album %q", albumID)
+	}
+	return filepath.Join(l.root, filepath.FromSlash(albumID)), nil
+}
+
+// media returns media files of the directory sorted by name
+func media(dir string) ([]os.DirEntry, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	files := []os.DirEntry{}
+	for _, entry := range entries {
+		if _, ok := KindOf(entry.Name()); ok && entry.Type().IsRegular() {
+			files = append(files, entry)
+		}
+	}
+	return files, nil
+}
+
+// AllAlbums returns every directory with media files, ID of an album is the path relative to the root
+func (l *LocalDir) AllAlbums() ([]map[string]string, error) {
+	stat, err := os.Stat(l.root)
+	if err != nil {
+		return nil, &sources.AccessError{Text: "directory is not readable", Err: err}
+	}
+	if !stat.IsDir() {
+		return nil, &sources.AccessError{Text: "it's not a directory", Err: errors.New(l.root)}
+	}
+	albums := []map[string]string{}
+	err = filepath.WalkDir(l.root, func(dir string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.IsDir() {
+			return nil
+		}
+		files, err := media(dir)
+		if err != nil || len(files) == 0 {
+			return err
+		}
+		id, err := filepath.Rel(l.root, dir)
+		if err != nil {
+			return err
+		}
+		var created string
+		if info, err := files[0].Info(); err == nil {
+			created = info.ModTime().UTC().Format(time.RFC3339)
+		}
+		albums = append(albums, map[string]string{
+			"title":   albumTitle(l.root, dir),
+			"id":      filepath.ToSlash(id),
+			"created": created,
+			"size":    fmt.Sprint(len(files)),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(albums, func(i, j int) bool { return albums[i]["id"] < albums[j]["id"] })
+	return albums, nil
+}
+
+// CheckDestination refuses dir if it's the root, a directory inside of it or its parent,
+// albums stored there would overwrite the files they are copied from
+func (l *LocalDir) CheckDestination(dir string) error {
+	root, err := resolvePath(l.root)
+	if err != nil {
+		return err
+	}
+	dest, err := resolvePath(dir)
+	if err != nil {
+		return err
+	}
+	// files of the root are stored into the album named by the root directory
+	for _, albumDir := range []string{dest, filepath.Join(dest, filepath.Base(l.root))} {
+		if inside(root, albumDir) {
+			return fmt.Errorf("%q can't be dumped into %q, files would be stored over themselves", l.root, dir)
+		}
+	}
+	return nil
+}
+
+// resolvePath returns the absolute path without symlinks, the path itself if it doesn't exist yet
+func resolvePath(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+		return resolved, nil
+	}
+	return dir, nil
+}
+
+// inside reports whether path is dir or a path inside of it
+func inside(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// albumTitle is the path relative to the root, files of the root are titled by the root directory
+func albumTitle(root, dir string) string {
+	rel, err := filepath.Rel(root, dir)
+	if err != nil || rel == "." {
+		return filepath.Base(root)
+	}
+	return filepath.ToSlash(rel)
+}
+
+// AlbumPhotos returns media files of the directory, subdirectories are albums of their own
+func (l *LocalDir) AlbumPhotos(albumID string) (sources.ItemFetcher, error) {
+	dir, err := l.albumPath(albumID)
+	if err != nil {
+		return nil, err
+	}
+	files, err := media(dir)
+	if err != nil {
+		return nil, fmt.Errorf("can't read album: %w", err)
+	}
+	return &fetcher{dir: dir, files: files, albumName: albumTitle(l.root, dir), cur: -1}, nil
+}
+
+type fetcher struct {
+	dir       string
+	albumName string
+	files     []os.DirEntry
+	cur       int
+}
+
+func (f *fetcher) Next() bool {
+	f.cur++
+	return f.cur < len(f.files)
+}
+
+func (f *fetcher) Item() sources.Photo {
+	name := f.files[f.cur].Name()
+	kind, _ := KindOf(name)
+	return &PhotoItem{path: filepath.Join(f.dir, name), albumName: f.albumName, kind: kind}
+}
+
+type service struct{}
+
+func (s *service) Kind() sources.Kind {
+	return sources.KindSource
+}
+
+func (s *service) Key() string {
+	return "localdir"
+}
+
+func (s *service) Constructor() func(creds string) sources.Source {
+	return New
+}
+
+func NewService() sources.ServiceSource {
+	return &service{}
+}
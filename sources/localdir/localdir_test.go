@@ -0,0 +1,175 @@
+package localdir
+
+import (
+	"image"
+	"image/jpeg"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Gasoid/photoDumper/sources"
+	"github.com/Gasoid/photoDumper/storage/localfs"
+	"github.com/stretchr/testify/assert"
+)
+
+// writeJPEG creates a small JPEG, exif is written into it if info is set
+func writeJPEG(t *testing.T, path string, info sources.ExifInfo) {
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img := image.NewRGBA(image.Rect(0, 0, 16, 16))
+	if err := jpeg.Encode(f, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	if info == nil {
+		return
+	}
+	policy, _ := sources.ParseMergePolicy("")
+	if _, err := localfs.New().SetExif(path, info, policy); err != nil {
+		t.Fatal(err)
+	}
+}
+
+type testExif struct {
+	description string
+	created     time.Time
+	gps         *sources.GPS
+}
+
+func (e *testExif) Description() string { return e.description }
+func (e *testExif) Created() time.Time  { return e.created }
+func (e *testExif) GPS() *sources.GPS   { return e.gps }
+
+func TestLocalDir_AllAlbums(t *testing.T) {
+	root := t.TempDir()
+	writeJPEG(t, filepath.Join(root, "cover.jpg"), nil)
+	writeJPEG(t, filepath.Join(root, "2021", "sea.JPG"), nil)
+	writeJPEG(t, filepath.Join(root, "2021", "summer", "1.jpg"), nil)
+	os.WriteFile(filepath.Join(root, "2021", "summer", "2.mp4"), []byte("video"), 0640)
+	os.WriteFile(filepath.Join(root, "2021", "summer", "1.jpg.xmp"), []byte("xmp"), 0640)
+	os.MkdirAll(filepath.Join(root, "empty"), 0750)
+
+	albums, err := New(root).AllAlbums()
+	assert.NoError(t, err)
+	ids := []string{}
+	for _, album := range albums {
+		ids = append(ids, album["id"])
+	}
+	assert.Equal(t, []string{".", "2021", "2021/summer"}, ids)
+	assert.Equal(t, filepath.Base(root), albums[0]["title"])
+	assert.Equal(t, "2021/summer", albums[2]["title"])
+	assert.Equal(t, "2", albums[2]["size"])
+
+	_, err = New(filepath.Join(root, "missing")).AllAlbums()
+	assert.IsType(t, &sources.AccessError{}, err)
+}
+
+func TestLocalDir_AlbumPhotos(t *testing.T) {
+	root := t.TempDir()
+	writeJPEG(t, filepath.Join(root, "summer", "1.jpg"), nil)
+	os.WriteFile(filepath.Join(root, "summer", "2.mp4"), []byte("video"), 0640)
+	os.WriteFile(filepath.Join(root, "summer", "notes.txt"), []byte("notes"), 0640)
+
+	fetcher, err := New(root).AlbumPhotos("summer")
+	assert.NoError(t, err)
+	photos := []*PhotoItem{}
+	for fetcher.Next() {
+		photos = append(photos, fetcher.Item().(*PhotoItem))
+	}
+	if assert.Len(t, photos, 2) {
+		assert.Equal(t, "1.jpg", photos[0].Filename())
+		assert.Equal(t, sources.MediaImage, photos[0].Kind())
+		assert.Equal(t, "file://"+filepath.ToSlash(filepath.Join(root, "summer", "1.jpg")), photos[0].Url())
+		assert.Equal(t, "summer", photos[0].AlbumName())
+		assert.Equal(t, sources.MediaVideo, photos[1].Kind())
+		r, err := photos[1].Open()
+		if assert.NoError(t, err) {
+			data, _ := io.ReadAll(r)
+			r.Close()
+			assert.Equal(t, "video", string(data))
+		}
+	}
+
+	for _, albumID := range []string{"", "../", "/etc", "summer/../.."} {
+		_, err := New(root).AlbumPhotos(albumID)
+		assert.Error(t, err, albumID)
+	}
+}
+
+func TestPhotoItem_ExifInfo(t *testing.T) {
+	root := t.TempDir()
+	created := time.Date(2021, 7, 3, 18, 4, 5, 0, time.FixedZone("", 3*3600))
+	gps, _ := sources.NewGPS(-33.8568, 151.2153)
+	writeJPEG(t, filepath.Join(root, "tagged.jpg"), &testExif{description: "Opera house", created: created, gps: gps.WithAltitude(12)})
+	writeJPEG(t, filepath.Join(root, "plain.jpg"), nil)
+
+	fetcher, err := New(root).AlbumPhotos(rootAlbumID)
+	assert.NoError(t, err)
+	infos := map[string]sources.ExifInfo{}
+	for fetcher.Next() {
+		photo := fetcher.Item().(*PhotoItem)
+		info, err := photo.ExifInfo()
+		assert.NoError(t, err)
+		infos[photo.Filename()] = info
+	}
+
+	tagged := infos["tagged.jpg"]
+	assert.Equal(t, "Opera house", tagged.Description())
+	assert.True(t, created.Equal(tagged.Created()), tagged.Created())
+	if assert.NotNil(t, tagged.GPS()) {
+		assert.InDelta(t, -33.8568, tagged.GPS().Latitude, 0.0001)
+		assert.InDelta(t, 151.2153, tagged.GPS().Longitude, 0.0001)
+	}
+
+	plain := infos["plain.jpg"]
+	assert.Equal(t, "", plain.Description())
+	assert.True(t, plain.Created().IsZero())
+	assert.Nil(t, plain.GPS())
+}
+
+func TestLocalDir_CheckDestination(t *testing.T) {
+	parent := t.TempDir()
+	root := filepath.Join(parent, "trip")
+	os.MkdirAll(filepath.Join(root, "day1"), 0750)
+	l := New(root).(*LocalDir)
+
+	// the root itself, its albums and its parent would get copies over the originals
+	for _, dir := range []string{root, filepath.Join(root, "day1"), parent, root + string(filepath.Separator)} {
+		assert.Error(t, l.CheckDestination(dir), dir)
+	}
+	for _, dir := range []string{filepath.Join(parent, "dump"), filepath.Join(parent, "trip2")} {
+		assert.NoError(t, l.CheckDestination(dir), dir)
+	}
+}
+
+func TestLocalDir_dumpIntoRoot(t *testing.T) {
+	root := t.TempDir()
+	writeJPEG(t, filepath.Join(root, "day1", "1.jpg"), nil)
+	want, err := os.ReadFile(filepath.Join(root, "day1", "1.jpg"))
+	assert.NoError(t, err)
+	assert.Error(t, New(root).(*LocalDir).CheckDestination(root))
+
+	// the storage refuses to copy the file over itself anyway
+	fetcher, err := New(root).AlbumPhotos("day1")
+	assert.NoError(t, err)
+	assert.True(t, fetcher.Next())
+	item := fetcher.Item().(*PhotoItem)
+	storage := localfs.New()
+	dir, err := storage.CreateAlbumDir(root, item.AlbumName())
+	assert.NoError(t, err)
+	r, err := item.Open()
+	assert.NoError(t, err)
+	defer r.Close()
+	_, err = storage.SavePhoto(r, dir, item.Filename(), sources.FileInfo{})
+	assert.Error(t, err)
+	got, err := os.ReadFile(filepath.Join(root, "day1", "1.jpg"))
+	assert.NoError(t, err)
+	assert.Equal(t, want, got)
+}
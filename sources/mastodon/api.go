@@ -0,0 +1,169 @@
+package mastodon
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Gasoid/photoDumper/sources"
+)
+
+// scheme is a variable to point tests to a fake server without tls
+var scheme = "https"
+
+// statusesLimit is the maximum page size of statuses
+const statusesLimit = "40"
+
+// publicCreds are creds of public accounts, requests are sent without a token
+const publicCreds = "public"
+
+type Account struct {
+	ID          string `json:"id"`
+	Username    string `json:"username"`
+	Acct        string `json:"acct"`
+	DisplayName string `json:"display_name"`
+}
+
+type Attachment struct {
+	ID          string `json:"id"`
+	Type        string `json:"type"`
+	Url         string `json:"url"`
+	PreviewUrl  string `json:"preview_url"`
+	RemoteUrl   string `json:"remote_url,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+type Status struct {
+	ID               string        `json:"id"`
+	CreatedAt        time.Time     `json:"created_at"`
+	Content          string        `json:"content"`
+	SpoilerText      string        `json:"spoiler_text,omitempty"`
+	Url              string        `json:"url"`
+	MediaAttachments []*Attachment `json:"media_attachments"`
+}
+
+// Collection is a collection of Pixelfed, Mastodon has none
+type Collection struct {
+	ID    string `json:"id"`
+	Title string `json:"title"`
+}
+
+// Error is the error response of the api
+type Error struct {
+	Message    string `json:"error"`
+	StatusCode int    `json:"-"`
+	RetryAfter time.Duration
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("mastodon: %s (status %d)", e.Message, e.StatusCode)
+}
+
+// apiError converts errors of the api into sources.AccessError and sources.RateLimitError
+func apiError(err error, text string) error {
+	var e *Error
+	if !errors.As(err, &e) {
+		return fmt.Errorf("%s: %w", text, err)
+	}
+	switch e.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return &sources.AccessError{Err: err, Text: "token is invalid?"}
+	case http.StatusTooManyRequests:
+		return &sources.RateLimitError{Err: err, Text: "api limit of the server is reached, try again later", RetryAfter: e.RetryAfter}
+	}
+	return fmt.Errorf("%s: %w", text, err)
+}
+
+// API requests a server with Mastodon compatible api
+type API struct {
+	host  string
+	token string
+}
+
+func NewAPI(host, token string) *API {
+	if token == publicCreds {
+		token = ""
+	}
+	return &API{host: host, token: token}
+}
+
+func (api *API) get(path string, params url.Values, r interface{}) error {
+	u := url.URL{Scheme: scheme, Host: api.host, Path: path, RawQuery: params.Encode()}
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	if api.token != "" {
+		req.Header.Set("Authorization", "Bearer "+api.token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		e := &Error{StatusCode: resp.StatusCode}
+		if err := json.NewDecoder(resp.Body).Decode(e); err != nil || e.Message == "" {
+			e.Message = http.StatusText(resp.StatusCode)
+		}
+		// X-RateLimit-Reset is the time when the limit is reset
+		if reset, err := time.Parse(time.RFC3339, resp.Header.Get("X-RateLimit-Reset")); err == nil {
+			e.RetryAfter = time.Until(reset)
+		}
+		return e
+	}
+	if err := json.NewDecoder(resp.Body).Decode(r); err != nil {
+		return fmt.Errorf("mastodon: error decoding body; %w", err)
+	}
+	return nil
+}
+
+// Lookup finds the account by its username on the server
+func (api *API) Lookup(username string) (*Account, error) {
+	account := &Account{}
+	err := api.get("/api/v1/accounts/lookup", url.Values{"acct": {username}}, account)
+	return account, err
+}
+
+// Statuses returns statuses with media older than maxID, the newest are returned if maxID is empty
+func (api *API) Statuses(accountID, maxID string) ([]*Status, error) {
+	params := url.Values{"only_media": {"true"}, "exclude_reblogs": {"true"}, "limit": {statusesLimit}}
+	if maxID != "" {
+		params.Set("max_id", maxID)
+	}
+	statuses := []*Status{}
+	err := api.get("/api/v1/accounts/"+url.PathEscape(accountID)+"/statuses", params, &statuses)
+	return statuses, err
+}
+
+// Collections returns collections of a Pixelfed account, servers without collections return none
+func (api *API) Collections(accountID string) ([]*Collection, error) {
+	collections := []*Collection{}
+	err := api.get("/api/v1.1/collections/accounts/"+url.PathEscape(accountID), url.Values{}, &collections)
+	var e *Error
+	if errors.As(err, &e) && e.StatusCode == http.StatusNotFound {
+		return []*Collection{}, nil
+	}
+	return collections, err
+}
+
+// CollectionStatuses returns statuses of a Pixelfed collection
+func (api *API) CollectionStatuses(collectionID string) ([]*Status, error) {
+	statuses := []*Status{}
+	err := api.get("/api/v1.1/collections/items/"+url.PathEscape(collectionID), url.Values{}, &statuses)
+	return statuses, err
+}
+
+// parseHandle splits user@example.social (a leading @ is allowed) into the username and the server
+func parseHandle(handle string) (string, string, error) {
+	handle = strings.TrimPrefix(strings.TrimSpace(handle), "@")
+	parts := strings.Split(handle, "@")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("owner_id has to be an account like user@example.social, got %q", handle)
+	}
+	return parts[0], parts[1], nil
+}
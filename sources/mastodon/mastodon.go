@@ -0,0 +1,355 @@
+// Package mastodon dumps media of statuses of an account on a server with Mastodon compatible api,
+// e.g. Mastodon or Pixelfed
+package mastodon
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Gasoid/photoDumper/sources"
+)
+
+const (
+	yearAlbumPrefix       = "year_"
+	collectionAlbumPrefix = "collection_"
+)
+
+var (
+	lineBreaks = regexp.MustCompile(`(?i)<br\s*/?>|</p>`)
+	tags       = regexp.MustCompile(`<[^>]*>`)
+)
+
+// plainText converts html of a status into text
+func plainText(content string) string {
+	content = lineBreaks.ReplaceAllString(content, "\n")
+	return strings.TrimSpace(html.UnescapeString(tags.ReplaceAllString(content, "")))
+}
+
+// PhotoItem is a media attachment of a status
+type PhotoItem struct {
+	albumName  string
+	status     *Status
+	attachment *Attachment
+}
+
+// Url is the original file stored by the server, remote media are downloaded from their origin
+func (f *PhotoItem) Url() string {
+	if f.attachment.Url == "" {
+		return f.attachment.RemoteUrl
+	}
+	return f.attachment.Url
+}
+
+func (f *PhotoItem) AlbumName() string {
+	return f.albumName
+}
+
+func (f *PhotoItem) Kind() sources.MediaKind {
+	if f.attachment.Type == "video" || f.attachment.Type == "gifv" {
+		return sources.MediaVideo
+	}
+	return sources.MediaImage
+}
+
+// Filename is ID of the attachment
+func (f *PhotoItem) Filename() string {
+	return f.attachment.ID
+}
+
+// ThumbnailUrl returns a preview of a video
+func (f *PhotoItem) ThumbnailUrl() string {
+	if f.Kind() != sources.MediaVideo {
+		return ""
+	}
+	return f.attachment.PreviewUrl
+}
+
+// Metadata returns the status as it is received from the server
+func (f *PhotoItem) Metadata() interface{} {
+	return f.status
+}
+
+// ExifInfo uses alt text of the attachment, text of the status is used if there is no alt text
+func (f *PhotoItem) ExifInfo() (sources.ExifInfo, error) {
+	description := strings.TrimSpace(f.attachment.Description)
+	if description == "" {
+		description = plainText(f.status.Content)
+	}
+	return &exifInfo{description: description, created: f.status.CreatedAt.UTC()}, nil
+}
+
+type exifInfo struct {
+	description string
+	created     time.Time
+}
+
+func (e *exifInfo) Description() string {
+	return e.description
+}
+
+func (e *exifInfo) Created() time.Time {
+	return e.created
+}
+
+func (e *exifInfo) GPS() *sources.GPS {
+	return nil
+}
+
+// Mastodon dumps media of the account set by owner_id (user@example.social),
+// creds is an access token of the server or "public" for public statuses
+type Mastodon struct {
+	creds    string
+	api      *API
+	username string
+	account  *Account
+}
+
+func New(creds string) sources.Source {
+	return &Mastodon{creds: creds}
+}
+
+// SetOwner takes the account as user@example.social
+func (m *Mastodon) SetOwner(ownerID string) error {
+	username, host, err := parseHandle(ownerID)
+	if err != nil {
+		return err
+	}
+	m.api, m.username, m.account = NewAPI(host, m.creds), username, nil
+	return nil
+}
+
+// lookup finds the account once
+func (m *Mastodon) lookup() (*Account, error) {
+	if m.account != nil {
+		return m.account, nil
+	}
+	if m.api == nil {
+		return nil, &sources.AccessError{Text: "owner_id (user@example.social) is required", Err: errors.New("no owner")}
+	}
+	account, err := m.api.Lookup(m.username)
+	if err != nil {
+		return nil, apiError(err, "can't find account")
+	}
+	if account.Acct == "" {
+		account.Acct = m.username
+	}
+	m.account = account
+	return account, nil
+}
+
+// media returns attachments which can be dumped, audio is skipped
+func media(status *Status) []*Attachment {
+	list := []*Attachment{}
+	for _, attachment := range status.MediaAttachments {
+		switch attachment.Type {
+		case "image", "video", "gifv":
+			list = append(list, attachment)
+		}
+	}
+	return list
+}
+
+// AllAlbums returns statuses grouped by years followed by collections of Pixelfed
+func (m *Mastodon) AllAlbums() ([]map[string]string, error) {
+	account, err := m.lookup()
+	if err != nil {
+		return nil, err
+	}
+	years := []int{}
+	counts := map[int]int{}
+	walker := &statuses{api: m.api, accountID: account.ID}
+	for status := walker.next(); status != nil; status = walker.next() {
+		year := status.CreatedAt.UTC().Year()
+		if _, ok := counts[year]; !ok {
+			years = append(years, year)
+		}
+		counts[year] += len(media(status))
+	}
+	if walker.err != nil {
+		return nil, apiError(walker.err, "can't get statuses")
+	}
+	albums := []map[string]string{}
+	for _, year := range years {
+		if counts[year] == 0 {
+			continue
+		}
+		albums = append(albums, map[string]string{
+			"title":   fmt.Sprint(year),
+			"id":      fmt.Sprint(yearAlbumPrefix, year),
+			"created": time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC).Format(time.RFC3339),
+			"size":    fmt.Sprint(counts[year]),
+		})
+	}
+	collections, err := m.api.Collections(account.ID)
+	if err != nil {
+		// statuses are available even if collections are not
+		log.Println("mastodon: collections", err)
+		return albums, nil
+	}
+	for _, collection := range collections {
+		albums = append(albums, map[string]string{
+			"title": collection.Title,
+			"id":    collectionAlbumPrefix + collection.ID,
+			// photos of collections are in albums of years too
+			"derived": "true",
+		})
+	}
+	return albums, nil
+}
+
+// AlbumPhotos returns media of statuses of the year or of the collection, they are stored in a folder named by the account
+func (m *Mastodon) AlbumPhotos(albumID string) (sources.ItemFetcher, error) {
+	account, err := m.lookup()
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(albumID, collectionAlbumPrefix) {
+		return m.collection(account, strings.TrimPrefix(albumID, collectionAlbumPrefix))
+	}
+	year, err := strconv.Atoi(strings.TrimPrefix(albumID, yearAlbumPrefix))
+	if err != nil || !strings.HasPrefix(albumID, yearAlbumPrefix) {
+		return nil, fmt.Errorf("wrong album %q", albumID)
+	}
+	walker := &statuses{api: m.api, accountID: account.ID}
+	// the first page is requested at once to report access errors
+	if err := walker.fetch(); err != nil {
+		return nil, apiError(err, "can't get statuses")
+	}
+	return &fetcher{
+		albumName: sources.AlbumPath(account.Acct, fmt.Sprint(year)),
+		next: func() *Status {
+			for status := walker.next(); status != nil; status = walker.next() {
+				switch y := status.CreatedAt.UTC().Year(); {
+				case y == year:
+					return status
+				case y < year:
+					// statuses are sorted from the newest
+					return nil
+				}
+			}
+			if walker.err != nil {
+				log.Println("mastodon: statuses", walker.err)
+			}
+			return nil
+		},
+	}, nil
+}
+
+func (m *Mastodon) collection(account *Account, collectionID string) (sources.ItemFetcher, error) {
+	collections, err := m.api.Collections(account.ID)
+	if err != nil {
+		return nil, apiError(err, "can't get collections")
+	}
+	title := ""
+	for _, collection := range collections {
+		if collection.ID == collectionID {
+			title = collection.Title
+		}
+	}
+	if title == "" {
+		return nil, errors.New("no such a collection")
+	}
+	list, err := m.api.CollectionStatuses(collectionID)
+	if err != nil {
+		return nil, apiError(err, "can't get collection")
+	}
+	return &fetcher{
+		// titles of collections are folders, the ID replaces titles which aren't safe
+		albumName: sources.AlbumPath(account.Acct, sources.FolderName(title, collectionID)),
+		next: func() *Status {
+			if len(list) == 0 {
+				return nil
+			}
+			status := list[0]
+			list = list[1:]
+			return status
+		},
+	}, nil
+}
+
+// statuses requests pages of statuses with media on demand
+type statuses struct {
+	api       *API
+	accountID string
+	page      []*Status
+	maxID     string
+	done      bool
+	err       error
+}
+
+func (s *statuses) fetch() error {
+	page, err := s.api.Statuses(s.accountID, s.maxID)
+	if err != nil {
+		s.err, s.done = err, true
+		return err
+	}
+	if len(page) == 0 {
+		s.done = true
+	} else {
+		s.maxID = page[len(page)-1].ID
+	}
+	s.page = page
+	return nil
+}
+
+// next returns nil when there are no more statuses or a request failed, the error is kept in err
+func (s *statuses) next() *Status {
+	for len(s.page) == 0 {
+		if s.done || s.fetch() != nil || len(s.page) == 0 {
+			return nil
+		}
+	}
+	status := s.page[0]
+	s.page = s.page[1:]
+	return status
+}
+
+type fetcher struct {
+	albumName string
+	next      func() *Status
+	queue     []*PhotoItem
+	cur       *PhotoItem
+}
+
+func (f *fetcher) Next() bool {
+	for len(f.queue) == 0 {
+		status := f.next()
+		if status == nil {
+			return false
+		}
+		for _, attachment := range media(status) {
+			f.queue = append(f.queue, &PhotoItem{albumName: f.albumName, status: status, attachment: attachment})
+		}
+	}
+	f.cur = f.queue[0]
+	f.queue = f.queue[1:]
+	return true
+}
+
+func (f *fetcher) Item() sources.Photo {
+	return f.cur
+}
+
+type service struct{}
+
+func (s *service) Kind() sources.Kind {
+	return sources.KindSource
+}
+
+func (s *service) Key() string {
+	return "mastodon"
+}
+
+func (s *service) Constructor() func(creds string) sources.Source {
+	return New
+}
+
+func NewService() sources.ServiceSource {
+	return &service{}
+}
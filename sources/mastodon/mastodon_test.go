@@ -0,0 +1,148 @@
+package mastodon
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Gasoid/photoDumper/sources"
+	"github.com/stretchr/testify/assert"
+)
+
+func status(id, createdAt string, attachments ...map[string]string) map[string]interface{} {
+	return map[string]interface{}{
+		"id": id, "created_at": createdAt, "content": "<p>Red square<br>Moscow &amp; sun</p>", "url": "https://pixelfed.test/p/anna/" + id,
+		"media_attachments": attachments,
+	}
+}
+
+// fakeServer serves a Pixelfed account "anna", it returns its host
+func fakeServer(t *testing.T, token string) string {
+	pages := map[string][]map[string]interface{}{
+		"": {
+			status("30", "2022-05-01T10:00:00.000Z", map[string]string{"id": "301", "type": "image", "url": "https://cdn.test/301.jpg", "description": "Alt text"}),
+			status("20", "2021-07-03T18:04:05.000Z",
+				map[string]string{"id": "201", "type": "image", "url": "https://cdn.test/201.jpg"},
+				map[string]string{"id": "202", "type": "video", "url": "https://cdn.test/202.mp4", "preview_url": "https://cdn.test/202.jpg"},
+				map[string]string{"id": "203", "type": "audio", "url": "https://cdn.test/203.mp3"}),
+		},
+		"20": {status("10", "2020-01-01T00:00:00.000Z", map[string]string{"id": "101", "type": "image", "remote_url": "https://remote.test/101.jpg"})},
+		"10": {},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Header.Get("Authorization") != token {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": "The access token is invalid"})
+			return
+		}
+		switch r.URL.Path {
+		case "/api/v1/accounts/lookup":
+			assert.Equal(t, "anna", r.URL.Query().Get("acct"))
+			json.NewEncoder(w).Encode(map[string]string{"id": "7", "username": "anna", "acct": "anna"})
+		case "/api/v1/accounts/7/statuses":
+			assert.Equal(t, "true", r.URL.Query().Get("only_media"))
+			json.NewEncoder(w).Encode(pages[r.URL.Query().Get("max_id")])
+		case "/api/v1.1/collections/accounts/7":
+			json.NewEncoder(w).Encode([]map[string]string{{"id": "5", "title": "Best"}, {"id": "6", "title": ".."}})
+		case "/api/v1.1/collections/items/5", "/api/v1.1/collections/items/6":
+			json.NewEncoder(w).Encode(pages["20"])
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Record not found"})
+		}
+	}))
+	old := scheme
+	scheme = "http"
+	t.Cleanup(func() {
+		scheme = old
+		server.Close()
+	})
+	return strings.TrimPrefix(server.URL, "http://")
+}
+
+func TestMastodon_AllAlbums(t *testing.T) {
+	host := fakeServer(t, "Bearer token")
+	m := New("token")
+
+	_, err := m.AllAlbums()
+	assert.IsType(t, &sources.AccessError{}, err)
+	assert.Error(t, m.(*Mastodon).SetOwner("anna"))
+
+	assert.NoError(t, m.(*Mastodon).SetOwner("@anna@"+host))
+	albums, err := m.AllAlbums()
+	assert.NoError(t, err)
+	assert.Equal(t, []map[string]string{
+		{"id": "year_2022", "title": "2022", "size": "1", "created": "2022-01-01T00:00:00Z"},
+		{"id": "year_2021", "title": "2021", "size": "2", "created": "2021-01-01T00:00:00Z"},
+		{"id": "year_2020", "title": "2020", "size": "1", "created": "2020-01-01T00:00:00Z"},
+		{"id": "collection_5", "title": "Best", "derived": "true"},
+		{"id": "collection_6", "title": "..", "derived": "true"},
+	}, albums)
+
+	wrong := New("wrong").(*Mastodon)
+	wrong.SetOwner("anna@" + host)
+	_, err = wrong.AllAlbums()
+	assert.IsType(t, &sources.AccessError{}, err)
+}
+
+func TestMastodon_AlbumPhotos(t *testing.T) {
+	host := fakeServer(t, "")
+	m := New(publicCreds).(*Mastodon)
+	m.SetOwner("anna@" + host)
+
+	fetcher, err := m.AlbumPhotos("year_2021")
+	assert.NoError(t, err)
+	photos := []*PhotoItem{}
+	for fetcher.Next() {
+		photos = append(photos, fetcher.Item().(*PhotoItem))
+	}
+	if !assert.Len(t, photos, 2) {
+		return
+	}
+	assert.Equal(t, "anna/2021", photos[0].AlbumName())
+	assert.Equal(t, "https://cdn.test/201.jpg", photos[0].Url())
+	assert.Equal(t, "201", photos[0].Filename())
+	info, err := photos[0].ExifInfo()
+	assert.NoError(t, err)
+	assert.Equal(t, "Red square\nMoscow & sun", info.Description())
+	assert.Equal(t, time.Date(2021, 7, 3, 18, 4, 5, 0, time.UTC), info.Created())
+	assert.Equal(t, sources.MediaVideo, photos[1].Kind())
+	assert.Equal(t, "https://cdn.test/202.jpg", photos[1].ThumbnailUrl())
+
+	fetcher, err = m.AlbumPhotos("year_2022")
+	assert.NoError(t, err)
+	assert.True(t, fetcher.Next())
+	info, _ = fetcher.Item().ExifInfo()
+	assert.Equal(t, "Alt text", info.Description())
+	assert.False(t, fetcher.Next())
+
+	fetcher, err = m.AlbumPhotos("collection_5")
+	assert.NoError(t, err)
+	assert.True(t, fetcher.Next())
+	assert.Equal(t, "anna/Best", fetcher.Item().AlbumName())
+	assert.Equal(t, "https://remote.test/101.jpg", fetcher.Item().Url())
+	assert.False(t, fetcher.Next())
+
+	// titles which aren't safe folder names are replaced by the ID of the collection
+	fetcher, err = m.AlbumPhotos("collection_6")
+	assert.NoError(t, err)
+	assert.True(t, fetcher.Next())
+	assert.Equal(t, "anna/6", fetcher.Item().AlbumName())
+
+	_, err = m.AlbumPhotos("collection_9")
+	assert.Error(t, err)
+	_, err = m.AlbumPhotos("2021")
+	assert.Error(t, err)
+}
+
+func Test_apiError(t *testing.T) {
+	err := apiError(&Error{StatusCode: http.StatusTooManyRequests, RetryAfter: time.Minute}, "text")
+	if assert.IsType(t, &sources.RateLimitError{}, err) {
+		assert.Equal(t, time.Minute, err.(*sources.RateLimitError).RetryAfter)
+	}
+	assert.IsType(t, &sources.AccessError{}, apiError(&Error{StatusCode: http.StatusForbidden}, "text"))
+}
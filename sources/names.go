@@ -0,0 +1,47 @@
+package sources
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// folderReplacer replaces separators of paths in titles of albums
+var folderReplacer = strings.NewReplacer("/", "_", "\\", "_", "\x00", "")
+
+// FolderName makes the title of an album safe to be a single directory: separators are replaced,
+// titles which are empty, "." or ".." are replaced by fallback
+func FolderName(title, fallback string) string {
+	for _, name := range []string{title, fallback} {
+		name = strings.TrimSpace(folderReplacer.Replace(name))
+		if strings.Trim(name, ". ") != "" {
+			return name
+		}
+	}
+	return "_"
+}
+
+// AlbumPath is the name of a nested album, e.g. the owner and the title, every part is a FolderName,
+// empty parts are skipped
+func AlbumPath(parts ...string) string {
+	names := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		names = append(names, FolderName(part, ""))
+	}
+	return strings.Join(names, "/")
+}
+
+// AlbumDir returns the directory of the album in rootDir, "/" separates nested directories of the name,
+// parts are FolderNames, so the directory is never outside of rootDir
+func AlbumDir(rootDir, albumName string) string {
+	dir := rootDir
+	for _, part := range strings.Split(albumName, "/") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		dir = filepath.Join(dir, FolderName(part, ""))
+	}
+	return dir
+}
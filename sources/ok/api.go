@@ -0,0 +1,195 @@
+package ok
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Gasoid/photoDumper/sources"
+)
+
+// apiUrl is a variable to point tests to a fake server
+var apiUrl = "https://api.ok.ru/fb.do"
+
+// pageSize is the maximum count of photos.getAlbums and photos.getPhotos
+const pageSize = 100
+
+// codes of ok.ru api errors
+const (
+	codeFloodBlocked   = 8
+	codePermission     = 10
+	codeLimitReached   = 11
+	codeParamAPIKey    = 101
+	codeSessionExpired = 102
+	codeSessionKey     = 103
+	codeSignature      = 104
+)
+
+// Error is the error response of the api
+type Error struct {
+	Code    int    `json:"error_code"`
+	Message string `json:"error_msg"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("ok: %s (code %d)", e.Message, e.Code)
+}
+
+// Unwrap makes errors.Is(err, sources.ErrTokenExpired) work for expired sessions
+func (e *Error) Unwrap() error {
+	if e.Code == codeSessionExpired {
+		return sources.ErrTokenExpired
+	}
+	return nil
+}
+
+// apiError converts errors of the api into sources.AccessError and sources.RateLimitError
+func apiError(err error, text string) error {
+	var e *Error
+	if !errors.As(err, &e) {
+		return fmt.Errorf("%s: %w", text, err)
+	}
+	switch e.Code {
+	case codeSessionExpired:
+		return &sources.AccessError{Err: err, Text: "token has expired, please log in again"}
+	case codePermission, codeParamAPIKey, codeSessionKey, codeSignature:
+		return &sources.AccessError{Err: err, Text: "token is invalid?"}
+	case codeFloodBlocked, codeLimitReached:
+		return &sources.RateLimitError{Err: err, Text: "ok api limit is reached, try again later", RetryAfter: time.Hour}
+	}
+	return fmt.Errorf("%s: %w", text, err)
+}
+
+type Album struct {
+	ID          string `json:"aid"`
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Created     string `json:"created,omitempty"`
+	PhotosCount int    `json:"photos_count"`
+	MainPhoto   *Photo `json:"main_photo,omitempty"`
+}
+
+type Photo struct {
+	ID        string `json:"id"`
+	AlbumID   string `json:"album_id,omitempty"`
+	PicMax    string `json:"pic_max,omitempty"`
+	Pic640    string `json:"pic640x480,omitempty"`
+	Text      string `json:"text,omitempty"`
+	CreatedMs int64  `json:"created_ms,omitempty"`
+}
+
+type albumsResponse struct {
+	Albums       []*Album `json:"albums"`
+	HasMore      bool     `json:"hasMore"`
+	PagingAnchor string   `json:"pagingAnchor"`
+}
+
+type photosResponse struct {
+	Photos  []*Photo `json:"photos"`
+	HasMore bool     `json:"hasMore"`
+	Anchor  string   `json:"anchor"`
+}
+
+// API signs requests with the session secret key derived from the token and the secret of the app
+type API struct {
+	token          string
+	applicationKey string
+	secretKey      string
+}
+
+func NewAPI(token, applicationKey, applicationSecret string) *API {
+	sum := md5.Sum([]byte(token + applicationSecret))
+	return &API{token: token, applicationKey: applicationKey, secretKey: hex.EncodeToString(sum[:])}
+}
+
+// sign returns md5 of sorted parameters followed by the secret key, the token is not signed
+func (api *API) sign(params url.Values) string {
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		if key != "access_token" && key != "session_key" && key != "sig" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	b := strings.Builder{}
+	for _, key := range keys {
+		b.WriteString(key + "=" + params.Get(key))
+	}
+	b.WriteString(api.secretKey)
+	sum := md5.Sum([]byte(b.String()))
+	return hex.EncodeToString(sum[:])
+}
+
+func (api *API) call(method string, params url.Values, r interface{}) error {
+	params.Set("application_key", api.applicationKey)
+	params.Set("method", method)
+	params.Set("format", "json")
+	params.Set("sig", api.sign(params))
+	params.Set("access_token", api.token)
+	resp, err := http.PostForm(apiUrl, params)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return &Error{Code: resp.StatusCode, Message: http.StatusText(resp.StatusCode)}
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	// errors are returned with status 200
+	e := &Error{}
+	if err := json.Unmarshal(body, e); err == nil && e.Code != 0 {
+		return e
+	}
+	if err := json.Unmarshal(body, r); err != nil {
+		return fmt.Errorf("ok: error decoding body; %w", err)
+	}
+	return nil
+}
+
+// Albums returns all albums of the user
+func (api *API) Albums() ([]*Album, error) {
+	albums := []*Album{}
+	params := url.Values{
+		"count":  {fmt.Sprint(pageSize)},
+		"fields": {"album.aid,album.title,album.description,album.created,album.photos_count,album.main_photo,photo.pic640x480"},
+	}
+	for {
+		r := &albumsResponse{}
+		if err := api.call("photos.getAlbums", params, r); err != nil {
+			return nil, err
+		}
+		albums = append(albums, r.Albums...)
+		if !r.HasMore || r.PagingAnchor == "" || len(r.Albums) == 0 {
+			return albums, nil
+		}
+		params.Set("pagingAnchor", r.PagingAnchor)
+	}
+}
+
+// Photos returns a page of photos of the album, personal photos are returned if albumID is empty
+func (api *API) Photos(albumID, anchor string) (*photosResponse, error) {
+	params := url.Values{
+		"count":  {fmt.Sprint(pageSize)},
+		"fields": {"photo.id,photo.album_id,photo.pic_max,photo.pic640x480,photo.text,photo.created_ms"},
+	}
+	if albumID != "" {
+		params.Set("aid", albumID)
+	}
+	if anchor != "" {
+		params.Set("anchor", anchor)
+		params.Set("direction", "FORWARD")
+	}
+	r := &photosResponse{}
+	return r, api.call("photos.getPhotos", params, r)
+}
@@ -0,0 +1,180 @@
+package ok
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/Gasoid/photoDumper/sources"
+)
+
+// personalAlbumID is photos which are not in any album
+const personalAlbumID = "personal"
+
+type PhotoItem struct {
+	albumName string
+	photo     *Photo
+}
+
+// Url is the biggest size of the photo
+func (f *PhotoItem) Url() string {
+	if f.photo.PicMax != "" {
+		return f.photo.PicMax
+	}
+	return f.photo.Pic640
+}
+
+func (f *PhotoItem) AlbumName() string {
+	return f.albumName
+}
+
+func (f *PhotoItem) Kind() sources.MediaKind {
+	return sources.MediaImage
+}
+
+// Filename is ID of the photo, urls of ok.ru have no file names
+func (f *PhotoItem) Filename() string {
+	return f.photo.ID
+}
+
+// Metadata returns the photo object as it is received from ok.ru api
+func (f *PhotoItem) Metadata() interface{} {
+	return f.photo
+}
+
+// ExifInfo uses the upload time and the description of the photo, ok.ru has no locations
+func (f *PhotoItem) ExifInfo() (sources.ExifInfo, error) {
+	exif := &exifInfo{description: strings.TrimSpace(f.photo.Text)}
+	if f.photo.CreatedMs > 0 {
+		exif.created = time.UnixMilli(f.photo.CreatedMs).UTC()
+	}
+	return exif, nil
+}
+
+type exifInfo struct {
+	description string
+	created     time.Time
+}
+
+func (e *exifInfo) Description() string {
+	return e.description
+}
+
+func (e *exifInfo) Created() time.Time {
+	return e.created
+}
+
+func (e *exifInfo) GPS() *sources.GPS {
+	return nil
+}
+
+type Ok struct {
+	api *API
+}
+
+// AllAlbums returns albums of the user and personal photos which are not in any album
+func (o *Ok) AllAlbums() ([]map[string]string, error) {
+	resp, err := o.api.Albums()
+	if err != nil {
+		return nil, apiError(err, "can't get albums")
+	}
+	albums := make([]map[string]string, 0, len(resp)+1)
+	albums = append(albums, map[string]string{"title": "Personal photos", "id": personalAlbumID})
+	for _, album := range resp {
+		var thumb string
+		if album.MainPhoto != nil {
+			thumb = album.MainPhoto.Pic640
+		}
+		albums = append(albums, map[string]string{
+			"thumb":   thumb,
+			"title":   album.Title,
+			"id":      album.ID,
+			"created": album.Created,
+			"size":    fmt.Sprint(album.PhotosCount),
+		})
+	}
+	return albums, nil
+}
+
+// AlbumPhotos returns photos of the album, the first page is requested at once to report access errors
+func (o *Ok) AlbumPhotos(albumID string) (sources.ItemFetcher, error) {
+	albumName, aid := "Personal photos", ""
+	if albumID != personalAlbumID {
+		albums, err := o.api.Albums()
+		if err != nil {
+			return nil, apiError(err, "can't get albums")
+		}
+		for _, album := range albums {
+			if album.ID == albumID {
+				// titles of albums are folders, the ID replaces titles which aren't safe
+				albumName, aid = sources.FolderName(album.Title, album.ID), album.ID
+			}
+		}
+		if aid == "" {
+			return nil, fmt.Errorf("no such an album")
+		}
+	}
+	page, err := o.api.Photos(aid, "")
+	if err != nil {
+		return nil, apiError(err, "can't get photos")
+	}
+	return &fetcher{api: o.api, albumID: aid, albumName: albumName, page: page}, nil
+}
+
+type fetcher struct {
+	api       *API
+	albumID   string
+	albumName string
+	page      *photosResponse
+	cur       *Photo
+}
+
+func (f *fetcher) Next() bool {
+	for len(f.page.Photos) == 0 {
+		if !f.page.HasMore || f.page.Anchor == "" {
+			return false
+		}
+		page, err := f.api.Photos(f.albumID, f.page.Anchor)
+		if err != nil {
+			log.Println("ok: photos", err)
+			return false
+		}
+		if len(page.Photos) == 0 {
+			return false
+		}
+		f.page = page
+	}
+	f.cur = f.page.Photos[0]
+	f.page.Photos = f.page.Photos[1:]
+	return true
+}
+
+func (f *fetcher) Item() sources.Photo {
+	return &PhotoItem{albumName: f.albumName, photo: f.cur}
+}
+
+type service struct {
+	applicationKey    string
+	applicationSecret string
+}
+
+func (s *service) Kind() sources.Kind {
+	return sources.KindSource
+}
+
+func (s *service) Key() string {
+	return "ok"
+}
+
+// Constructor takes an access token, requests are signed with the secret of the app
+func (s *service) Constructor() func(creds string) sources.Source {
+	return func(creds string) sources.Source {
+		return &Ok{api: NewAPI(creds, s.applicationKey, s.applicationSecret)}
+	}
+}
+
+// NewService needs the public key and the secret key of the ok.ru app
+func NewService(applicationKey, applicationSecret string) sources.ServiceSource {
+	return &service{applicationKey: applicationKey, applicationSecret: applicationSecret}
+}
@@ -0,0 +1,149 @@
+package ok
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/Gasoid/photoDumper/sources"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeOK serves methods for the app "KEY" with the secret "sec", requests with a wrong signature are rejected
+func fakeOK(t *testing.T, methods map[string]func(form url.Values) interface{}) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		w.Header().Set("Content-Type", "application/json")
+		expected := NewAPI(r.Form.Get("access_token"), "KEY", "sec")
+		switch {
+		case r.Form.Get("access_token") == "expired":
+			json.NewEncoder(w).Encode(map[string]interface{}{"error_code": 102, "error_msg": "PARAM_SESSION_EXPIRED : Session expired"})
+			return
+		case r.Form.Get("sig") != expected.sign(r.Form):
+			json.NewEncoder(w).Encode(map[string]interface{}{"error_code": 104, "error_msg": "PARAM_SIGNATURE : Invalid signature"})
+			return
+		}
+		handler, ok := methods[r.Form.Get("method")]
+		if !ok {
+			json.NewEncoder(w).Encode(map[string]interface{}{"error_code": 3, "error_msg": "METHOD : Method not found"})
+			return
+		}
+		json.NewEncoder(w).Encode(handler(r.Form))
+	}))
+	old := apiUrl
+	apiUrl = server.URL + "/fb.do"
+	t.Cleanup(func() {
+		apiUrl = old
+		server.Close()
+	})
+}
+
+func TestAPI_sign(t *testing.T) {
+	api := NewAPI("tkn", "KEY", "sec")
+	params := url.Values{"application_key": {"KEY"}, "count": {"100"}, "format": {"json"}, "method": {"photos.getAlbums"}, "access_token": {"tkn"}}
+	assert.Equal(t, "1c6de25c64e979019734e8678c2fab63", api.secretKey)
+	assert.Equal(t, "ae4ffc165d1f4f2d537b2727736b35df", api.sign(params))
+}
+
+func albumsMethod(form url.Values) interface{} {
+	if form.Get("pagingAnchor") == "" {
+		return map[string]interface{}{
+			"albums":  []map[string]interface{}{{"aid": "901", "title": "Summer", "created": "2021-07-03", "photos_count": 2}},
+			"hasMore": true, "pagingAnchor": "next",
+		}
+	}
+	return map[string]interface{}{
+		"albums":  []map[string]interface{}{{"aid": "902", "title": "Winter", "photos_count": 1, "main_photo": map[string]string{"id": "5", "pic640x480": "https://i.mycdn.me/5.jpg"}}},
+		"hasMore": false,
+	}
+}
+
+func TestOk_AllAlbums(t *testing.T) {
+	fakeOK(t, map[string]func(url.Values) interface{}{"photos.getAlbums": albumsMethod})
+	constructor := NewService("KEY", "sec").Constructor()
+
+	albums, err := constructor("token").AllAlbums()
+	assert.NoError(t, err)
+	assert.Equal(t, []map[string]string{
+		{"id": personalAlbumID, "title": "Personal photos"},
+		{"id": "901", "title": "Summer", "created": "2021-07-03", "size": "2", "thumb": ""},
+		{"id": "902", "title": "Winter", "created": "", "size": "1", "thumb": "https://i.mycdn.me/5.jpg"},
+	}, albums)
+
+	_, err = constructor("expired").AllAlbums()
+	assert.IsType(t, &sources.AccessError{}, err)
+	assert.True(t, errors.Is(err, sources.ErrTokenExpired))
+
+	_, err = NewService("KEY", "wrong secret").Constructor()("token").AllAlbums()
+	assert.IsType(t, &sources.AccessError{}, err)
+}
+
+func TestOk_AlbumPhotos(t *testing.T) {
+	fakeOK(t, map[string]func(url.Values) interface{}{
+		"photos.getAlbums": albumsMethod,
+		"photos.getPhotos": func(form url.Values) interface{} {
+			if form.Get("aid") == "" {
+				return map[string]interface{}{"photos": []map[string]interface{}{{"id": "9", "pic640x480": "https://i.mycdn.me/9.jpg"}}}
+			}
+			assert.Equal(t, "901", form.Get("aid"))
+			if form.Get("anchor") == "" {
+				return map[string]interface{}{
+					"photos":  []map[string]interface{}{{"id": "1", "pic_max": "https://i.mycdn.me/1_max.jpg", "text": "Red square", "created_ms": 1625335445000}},
+					"hasMore": true, "anchor": "a1",
+				}
+			}
+			return map[string]interface{}{"photos": []map[string]interface{}{{"id": "2", "pic_max": "https://i.mycdn.me/2_max.jpg"}}, "hasMore": false}
+		},
+	})
+	o := NewService("KEY", "sec").Constructor()("token")
+
+	fetcher, err := o.AlbumPhotos("901")
+	assert.NoError(t, err)
+	photos := []*PhotoItem{}
+	for fetcher.Next() {
+		photos = append(photos, fetcher.Item().(*PhotoItem))
+	}
+	if !assert.Len(t, photos, 2) {
+		return
+	}
+	assert.Equal(t, "Summer", photos[0].AlbumName())
+	assert.Equal(t, "https://i.mycdn.me/1_max.jpg", photos[0].Url())
+	assert.Equal(t, "1", photos[0].Filename())
+	info, err := photos[0].ExifInfo()
+	assert.NoError(t, err)
+	assert.Equal(t, "Red square", info.Description())
+	assert.Equal(t, time.Date(2021, 7, 3, 18, 4, 5, 0, time.UTC), info.Created())
+	info, _ = photos[1].ExifInfo()
+	assert.True(t, info.Created().IsZero())
+
+	fetcher, err = o.AlbumPhotos(personalAlbumID)
+	assert.NoError(t, err)
+	assert.True(t, fetcher.Next())
+	assert.Equal(t, "https://i.mycdn.me/9.jpg", fetcher.Item().Url())
+	assert.False(t, fetcher.Next())
+
+	_, err = o.AlbumPhotos("missing")
+	assert.Error(t, err)
+}
+
+func TestOk_AlbumPhotosTitle(t *testing.T) {
+	fakeOK(t, map[string]func(url.Values) interface{}{
+		"photos.getAlbums": func(form url.Values) interface{} {
+			return map[string]interface{}{"albums": []map[string]interface{}{{"aid": "903", "title": "../../.ssh"}, {"aid": "904", "title": ".."}}}
+		},
+		"photos.getPhotos": func(form url.Values) interface{} {
+			return map[string]interface{}{"photos": []map[string]interface{}{{"id": "1", "pic_max": "https://i.mycdn.me/1_max.jpg"}}}
+		},
+	})
+	o := NewService("KEY", "sec").Constructor()("token")
+	for albumID, want := range map[string]string{"903": ".._.._.ssh", "904": "904"} {
+		fetcher, err := o.AlbumPhotos(albumID)
+		assert.NoError(t, err)
+		assert.True(t, fetcher.Next())
+		assert.Equal(t, want, fetcher.Item().AlbumName())
+	}
+}
@@ -0,0 +1,88 @@
+package sources
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"sync"
+)
+
+// maxReports limits the number of job reports kept in memory, the oldest ones are dropped
+const maxReports = 100
+
+var (
+	reportsMu   sync.Mutex
+	reports     = map[string]*Report{}
+	reportOrder []string
+)
+
+// FileReport describes what has been done to a downloaded file
+type FileReport struct {
+	Path string `json:"path"`
+	// Changed lists metadata fields written into the file
+	Changed []string `json:"changed"`
+	// DateUnknown marks files the source has no capture time for, no date is written into them
+	DateUnknown bool   `json:"date_unknown,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// Report collects results of a download job
+type Report struct {
+	mu sync.Mutex
+	id string
+	// owner is a hash of credentials the job has been started with
+	owner [sha256.Size]byte
+	files []FileReport
+}
+
+// newReport creates a report of a job started with creds and registers it, so it can be found by FindReport
+func newReport(creds string) *Report {
+	b := make([]byte, 8)
+	rand.Read(b)
+	r := &Report{id: hex.EncodeToString(b), owner: sha256.Sum256([]byte(creds))}
+	reportsMu.Lock()
+	defer reportsMu.Unlock()
+	reports[r.id] = r
+	reportOrder = append(reportOrder, r.id)
+	if len(reportOrder) > maxReports {
+		delete(reports, reportOrder[0])
+		reportOrder = reportOrder[1:]
+	}
+	return r
+}
+
+// FindReport returns a report of the job
+func FindReport(id string) (*Report, bool) {
+	reportsMu.Lock()
+	defer reportsMu.Unlock()
+	r, ok := reports[id]
+	return r, ok
+}
+
+// ID returns ID of the job
+func (r *Report) ID() string {
+	return r.id
+}
+
+// OwnedBy reports whether the job has been started with creds
+func (r *Report) OwnedBy(creds string) bool {
+	owner := sha256.Sum256([]byte(creds))
+	return subtle.ConstantTimeCompare(r.owner[:], owner[:]) == 1
+}
+
+// Add appends a result of a file
+func (r *Report) Add(file FileReport) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.files = append(r.files, file)
+}
+
+// Files returns a copy of results collected so far
+func (r *Report) Files() []FileReport {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	files := make([]FileReport, len(r.files))
+	copy(files, r.files)
+	return files
+}
@@ -1,8 +1,13 @@
 package sources
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"path/filepath"
+	"strings"
 	"time"
 )
 
@@ -13,6 +18,49 @@ const (
 	KindStorage
 )
 
+// MediaKind is a type of downloaded media
+type MediaKind string
+
+const (
+	MediaImage MediaKind = "image"
+	MediaVideo MediaKind = "video"
+)
+
+// ParseMediaKind validates kind, empty kind and "all" mean any kind and return ""
+func ParseMediaKind(kind string) (MediaKind, error) {
+	switch k := MediaKind(kind); k {
+	case "", "all":
+		return "", nil
+	case MediaImage, MediaVideo:
+		return k, nil
+	}
+	return "", fmt.Errorf("unknown media kind %q", kind)
+}
+
+// MetadataMode defines where metadata of a downloaded file is written to
+type MetadataMode string
+
+const (
+	// MetadataEmbed writes metadata into the file, falls back to a sidecar if the format can't be edited
+	MetadataEmbed MetadataMode = "embed"
+	// MetadataSidecar writes metadata into <file>.xmp only
+	MetadataSidecar MetadataMode = "sidecar"
+	// MetadataBoth writes metadata into the file and into <file>.xmp
+	MetadataBoth MetadataMode = "both"
+	// MetadataNone leaves downloaded files untouched
+	MetadataNone MetadataMode = "none"
+)
+
+// Metadata fields which can be written into a file
+const (
+	FieldDescription = "description"
+	FieldCreated     = "created"
+	FieldGPS         = "gps"
+)
+
+// ErrExifUnsupported is returned by a storage if metadata can't be written into the file in place
+var ErrExifUnsupported = errors.New("exif is not supported for this file format")
+
 var (
 	registeredSources  = map[string]func(creds string) Source{}
 	registeredStorages = map[string]func() Storage{}
@@ -46,6 +94,9 @@ func (e *SourceError) Unwrap() error {
 	return e.err
 }
 
+// ErrTokenExpired is wrapped by AccessError if the token was valid but it has expired, user has to log in again
+var ErrTokenExpired = errors.New("token has expired")
+
 type AccessError struct {
 	Text string
 	Err  error
@@ -59,6 +110,32 @@ func (e *AccessError) Unwrap() error {
 	return e.Err
 }
 
+// Expired reports whether the token has expired, otherwise it's invalid or has no permissions
+func (e *AccessError) Expired() bool {
+	return errors.Is(e.Err, ErrTokenExpired)
+}
+
+// RateLimitError is returned if the source refuses requests for a while, the request can be retried later
+type RateLimitError struct {
+	Text string
+	// RetryAfter is a hint when to retry, zero means it's unknown
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("Rate limit: %s", e.Text)
+}
+
+func (e *RateLimitError) Unwrap() error {
+	return e.Err
+}
+
+// Temporary reports that the error is retryable
+func (e *RateLimitError) Temporary() bool {
+	return true
+}
+
 type ItemFetcher interface {
 	Next() bool
 	Item() Photo
@@ -69,33 +146,191 @@ type Source interface {
 	AlbumPhotos(albumdID string) (ItemFetcher, error)
 }
 
+// OwnerSource is an optional interface of Source, it switches albums to another user or a community
+type OwnerSource interface {
+	SetOwner(ownerID string) error
+}
+
+// DestinationSource is an optional interface of Source reading the disk,
+// it refuses a dump directory where files of the source would be stored over themselves
+type DestinationSource interface {
+	CheckDestination(dir string) error
+}
+
 type ExifInfo interface {
 	Description() string
+	// Created returns zero time if the source doesn't know when the photo was taken
 	Created() time.Time
-	GPS() []float64
+	// GPS returns nil if location of the photo is unknown
+	GPS() *GPS
 }
 
 type Photo interface {
 	Url() string
 	AlbumName() string
 	ExifInfo() (ExifInfo, error)
+	Kind() MediaKind
+}
+
+// NamedPhoto is an optional interface of Photo,
+// Filename is used instead of the basename of the url, extension is added by storage if it's missing
+type NamedPhoto interface {
+	Filename() string
+}
+
+// ThumbnailPhoto is an optional interface of Photo, e.g. a preview of a video,
+// the thumbnail is stored next to the file as <name>.thumb.<ext>
+type ThumbnailPhoto interface {
+	ThumbnailUrl() string
+}
+
+// LocalPhoto is an optional interface of Photo for sources reading files themselves: the disk (local directories,
+// takeouts, exports) or urls which contain secrets (files of telegram bots), storages copy the content returned by Open,
+// file:// urls are never downloaded
+type LocalPhoto interface {
+	Open() (io.ReadCloser, error)
+}
+
+// LocalThumbnailPhoto is an optional interface of LocalPhoto, e.g. a preview of a video of an export,
+// OpenThumbnail returns nil if the file has no thumbnail
+type LocalThumbnailPhoto interface {
+	OpenThumbnail() (io.ReadCloser, error)
+}
+
+// MetadataPhoto is an optional interface of Photo,
+// it exposes the original object of the source which is stored as <file>.json
+type MetadataPhoto interface {
+	Metadata() interface{}
 }
 
 type payload struct {
-	photo   Photo
-	rootDir string
+	source   string
+	photo    Photo
+	rootDir  string
+	options  JobOptions
+	report   *Report
+	comments *albumComments
 }
 
 type Storage interface {
 	Prepare(dir string) (string, error)
 	CreateAlbumDir(rootDir, dir string) (string, error)
-	DownloadPhoto(photoUrl, dir string) (string, error)
-	SetExif(filepath string, info ExifInfo) error
+	// DownloadPhoto stores the file into dir, name may be empty or have no extension,
+	// storage derives them from the url and the content type
+	DownloadPhoto(photoUrl, dir, name string, info FileInfo) (string, error)
+	// SavePhoto stores content of a file of a local source into dir,
+	// storage derives an extension from the content if name has none
+	SavePhoto(r io.Reader, dir, name string, info FileInfo) (string, error)
+	// SetExif merges info into existing metadata of the file according to policy,
+	// it returns fields which have been written
+	SetExif(filepath string, info ExifInfo, policy MergePolicy) ([]string, error)
+	WriteSidecar(filepath string, data []byte) error
+}
+
+// FileInfo describes a file for storages which need to know it before the file is stored, e.g. to upload it
+type FileInfo struct {
+	// Source is the key of the source, e.g. vk
+	Source string
+	// Created is the date of the photo, it's zero if the source doesn't know it
+	Created time.Time
+}
+
+// MergePolicy defines which metadata fields already present in a file may be overwritten,
+// fields the file lacks are always filled in
+type MergePolicy struct {
+	override map[string]bool
+}
+
+// ParseMergePolicy parses a comma separated list of fields to override, "all" overrides every field
+func ParseMergePolicy(fields string) (MergePolicy, error) {
+	policy := MergePolicy{override: map[string]bool{}}
+	for _, field := range strings.Split(fields, ",") {
+		switch field = strings.TrimSpace(field); field {
+		case "":
+		case "all":
+			policy.override[FieldDescription] = true
+			policy.override[FieldCreated] = true
+			policy.override[FieldGPS] = true
+		case FieldDescription, FieldCreated, FieldGPS:
+			policy.override[field] = true
+		default:
+			return MergePolicy{}, fmt.Errorf("unknown metadata field %q", field)
+		}
+	}
+	return policy, nil
+}
+
+// Write reports whether field has to be written, exists tells whether the file already has it
+func (p MergePolicy) Write(field string, exists bool) bool {
+	return !exists || p.override[field]
+}
+
+// JobOptions are settings of a particular download job
+type JobOptions struct {
+	Metadata MetadataMode
+	// JSONSidecar enables <file>.json with the original object of the source
+	JSONSidecar bool
+	Merge       MergePolicy
+	// InferTimeZone converts capture time into the local time zone of the photo location,
+	// otherwise capture time is stored in UTC
+	InferTimeZone bool
+	// Kind limits downloaded media to images or videos, empty kind means everything
+	Kind MediaKind
+	// Comments adds captions and comments of photos to the description and to comments.json of the album
+	Comments bool
+}
+
+// Accepts reports whether media of the kind should be downloaded
+func (o JobOptions) Accepts(kind MediaKind) bool {
+	return o.Kind == "" || o.Kind == kind
+}
+
+// ParseMetadataMode validates mode, empty mode means MetadataEmbed
+func ParseMetadataMode(mode string) (MetadataMode, error) {
+	switch m := MetadataMode(mode); m {
+	case "":
+		return MetadataEmbed, nil
+	case MetadataEmbed, MetadataSidecar, MetadataBoth, MetadataNone:
+		return m, nil
+	}
+	return "", fmt.Errorf("unknown metadata mode %q", mode)
 }
 
 type Social struct {
-	source  Source
-	storage Storage
+	// sourceKey is the key the source is registered with
+	sourceKey string
+	// creds are kept to bind reports of jobs to them
+	creds    string
+	source   Source
+	storage  Storage
+	options  JobOptions
+	report   *Report
+	comments *albumComments
+}
+
+// SetOptions sets options for jobs started by DownloadAlbum and DownloadAllAlbums
+func (s *Social) SetOptions(options JobOptions) {
+	s.options = options
+}
+
+// Report returns the report of the download job, it's nil until a download is started
+func (s *Social) Report() *Report {
+	return s.report
+}
+
+// SetOwner lists and downloads albums of another owner (e.g. a community) if the source supports it
+func (s *Social) SetOwner(ownerID string) error {
+	if ownerID == "" {
+		return nil
+	}
+	source, ok := s.source.(OwnerSource)
+	if !ok {
+		return &SourceError{text: "owner_id is not supported by the source"}
+	}
+	if err := source.SetOwner(ownerID); err != nil {
+		return &SourceError{text: err.Error(), err: err}
+	}
+	return nil
 }
 
 // Albums returns albums
@@ -108,17 +343,30 @@ func (s *Social) Albums() ([]map[string]string, error) {
 }
 
 func (s *Social) DownloadAllAlbums(dir string) (string, error) {
-	dir, err := s.storage.Prepare(dir)
+	dir, err := s.prepare(dir)
 	if err != nil {
 		log.Println("DownloadAllAlbums(dir string)", err)
-		return "", &StorageError{text: "dir can't be created", err: err}
+		return "", err
 	}
 
 	albums, err := s.source.AllAlbums()
 	if err != nil {
 		return "", err
 	}
+	if s.report == nil {
+		s.report = newReport(s.creds)
+	}
+	if s.options.Comments && s.comments == nil {
+		s.comments = newAlbumComments()
+	}
 	for _, album := range albums {
+		if kind, ok := album["kind"]; ok && !s.options.Accepts(MediaKind(kind)) {
+			continue
+		}
+		// derived albums (e.g. instagram posts of a year) are views of other albums, photos would be downloaded twice
+		if album["derived"] == "true" {
+			continue
+		}
 		go func(albumID string) {
 			_, err := s.DownloadAlbum(albumID, dir)
 			if err != nil {
@@ -130,20 +378,44 @@ func (s *Social) DownloadAllAlbums(dir string) (string, error) {
 	return dir, nil
 }
 
+// prepare creates the dump directory and checks that the source doesn't read it
+func (s *Social) prepare(dir string) (string, error) {
+	dir, err := s.storage.Prepare(dir)
+	if err != nil {
+		return "", &StorageError{text: "dir can't be created", err: err}
+	}
+	if source, ok := s.source.(DestinationSource); ok {
+		if err := source.CheckDestination(dir); err != nil {
+			return "", &SourceError{text: err.Error(), err: err}
+		}
+	}
+	return dir, nil
+}
+
 // DownloadAlbum runs copying process to a particular directory
 func (s *Social) DownloadAlbum(albumID, dir string) (string, error) {
-	dir, err := s.storage.Prepare(dir)
+	dir, err := s.prepare(dir)
 	if err != nil {
 		log.Println("DownloadAlbum(albumID, dir string)", err)
-		return "", &StorageError{text: "dir can't be created", err: err}
+		return "", err
 	}
 	cur, err := s.source.AlbumPhotos(albumID)
 	if err != nil {
 		return "", &SourceError{text: "can't receive photos", err: err}
 	}
+	if s.report == nil {
+		s.report = newReport(s.creds)
+	}
+	if s.options.Comments && s.comments == nil {
+		s.comments = newAlbumComments()
+	}
 	go func() {
 		for cur.Next() {
-			photoCh <- payload{photo: cur.Item(), rootDir: dir}
+			item := cur.Item()
+			if !s.options.Accepts(item.Kind()) {
+				continue
+			}
+			photoCh <- payload{source: s.sourceKey, photo: item, rootDir: dir, options: s.options, report: s.report, comments: s.comments}
 		}
 	}()
 	return dir, nil
@@ -158,25 +430,154 @@ func (s *Social) savePhotos(photoCh chan payload) {
 				log.Println(err)
 				return
 			}
-			filepath, err := s.storage.DownloadPhoto(f.photo.Url(), dir)
+			var name string
+			if np, ok := f.photo.(NamedPhoto); ok {
+				name = np.Filename()
+			}
+			// exif is read before the file is stored, storages may need the date of the photo
+			exif, exifErr := f.photo.ExifInfo()
+			info := FileInfo{Source: f.source}
+			if exifErr == nil && exif != nil {
+				exif = normalizeTime(exif, f.options.InferTimeZone)
+				info.Created = exif.Created()
+			}
+			filepath, err := s.storePhoto(f.photo, dir, name, info)
 			if err != nil {
 				log.Println(err)
 				return
 			}
-			exif, err := f.photo.ExifInfo()
-			if err != nil {
-				log.Println(err)
+			if err := s.storeThumbnail(f.photo, dir, filepath, info); err != nil {
+				log.Println("thumbnail:", err)
+			}
+			if f.options.JSONSidecar {
+				if err := s.writeJSON(filepath, f.photo); err != nil {
+					log.Println(err)
+				}
+			}
+			if exifErr != nil {
+				log.Println(exifErr)
 				return
 			}
 			if exif == nil {
 				return
 			}
-			s.storage.SetExif(filepath, exif)
+			if f.options.Comments {
+				exif = s.saveComments(filepath, f.photo, exif, f.comments)
+			}
+			file := FileReport{Path: filepath, DateUnknown: exif.Created().IsZero()}
+			file.Changed, err = s.writeMetadata(filepath, exif, f.options)
+			if err != nil {
+				log.Println(err)
+				file.Error = err.Error()
+			}
+			if f.report != nil {
+				f.report.Add(file)
+			}
 		}()
 	}
 	log.Println("channel closed")
 }
 
+// storePhoto copies files of local sources and downloads files of other sources
+func (s *Social) storePhoto(photo Photo, dir, name string, info FileInfo) (string, error) {
+	lp, ok := photo.(LocalPhoto)
+	if !ok {
+		return s.storage.DownloadPhoto(photo.Url(), dir, name, info)
+	}
+	r, err := lp.Open()
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+	return s.storage.SavePhoto(r, dir, name, info)
+}
+
+// storeThumbnail stores the thumbnail of the photo next to the file, urls of thumbnails of local files are ignored
+func (s *Social) storeThumbnail(photo Photo, dir, file string, info FileInfo) error {
+	if _, ok := photo.(LocalPhoto); ok {
+		lp, ok := photo.(LocalThumbnailPhoto)
+		if !ok {
+			return nil
+		}
+		r, err := lp.OpenThumbnail()
+		if err != nil || r == nil {
+			return err
+		}
+		defer r.Close()
+		_, err = s.storage.SavePhoto(r, dir, thumbnailName(file), info)
+		return err
+	}
+	if tp, ok := photo.(ThumbnailPhoto); ok && tp.ThumbnailUrl() != "" {
+		_, err := s.storage.DownloadPhoto(tp.ThumbnailUrl(), dir, thumbnailName(file), info)
+		return err
+	}
+	return nil
+}
+
+// saveComments adds caption and comments of the photo to comments.json of the album and returns exif with them in the description
+func (s *Social) saveComments(filepath string, photo Photo, exif ExifInfo, album *albumComments) ExifInfo {
+	comments, err := photoComments(photo)
+	if err != nil {
+		log.Println("comments:", err)
+	}
+	if comments.Caption == "" && len(comments.Comments) == 0 {
+		return exif
+	}
+	if album != nil {
+		if err := album.add(filepath, comments, s.storage.WriteSidecar); err != nil {
+			log.Println("comments:", err)
+		}
+	}
+	return withComments(exif, comments)
+}
+
+// thumbnailName returns name of a thumbnail for the file, e.g. video.thumb for video.mp4
+func thumbnailName(file string) string {
+	base := filepath.Base(file)
+	return strings.TrimSuffix(base, filepath.Ext(base)) + ".thumb"
+}
+
+// writeMetadata stores exif into the file and/or into <file>.xmp according to the metadata mode,
+// it returns fields written into the file itself
+func (s *Social) writeMetadata(filepath string, exif ExifInfo, options JobOptions) ([]string, error) {
+	mode := options.Metadata
+	sidecar := mode == MetadataSidecar || mode == MetadataBoth
+	var changed []string
+	if mode == "" || mode == MetadataEmbed || mode == MetadataBoth {
+		var err error
+		changed, err = s.storage.SetExif(filepath, exif, options.Merge)
+		switch {
+		case errors.Is(err, ErrExifUnsupported):
+			sidecar = true
+		case err != nil && !sidecar:
+			return changed, err
+		case err != nil:
+			log.Println("writeMetadata:", err)
+		}
+	}
+	if !sidecar {
+		return changed, nil
+	}
+	data, err := XMP(exif)
+	if err != nil {
+		return changed, err
+	}
+	return changed, s.storage.WriteSidecar(filepath+".xmp", data)
+}
+
+// writeJSON stores metadata of the photo into <file>.json if the source provides it
+func (s *Social) writeJSON(filepath string, photo Photo) error {
+	mp, ok := photo.(MetadataPhoto)
+	if !ok || mp.Metadata() == nil {
+		return nil
+	}
+	data, err := json.MarshalIndent(mp.Metadata(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return s.storage.WriteSidecar(filepath+".json", data)
+}
+
 // New creates a new instance of Social, you have to provide proper options
 func New(sourceName, creds string) (*Social, error) {
 	source, err := ProvideSource(sourceName, creds)
@@ -188,8 +589,10 @@ func New(sourceName, creds string) (*Social, error) {
 		return nil, err
 	}
 	s := &Social{
-		storage: storage,
-		source:  source,
+		storage:   storage,
+		source:    source,
+		sourceKey: sourceName,
+		creds:     creds,
 	}
 	if photoCh == nil {
 		photoCh = make(chan payload, maxConcurrentFiles)
@@ -1,7 +1,14 @@
 package sources
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -13,6 +20,7 @@ type PhotoItem struct {
 	albumName string
 	exifInfo  ExifInfo
 	err       error
+	kind      MediaKind
 }
 
 func (p *PhotoItem) Url() string {
@@ -24,6 +32,12 @@ func (p *PhotoItem) AlbumName() string {
 func (p *PhotoItem) ExifInfo() (ExifInfo, error) {
 	return p.exifInfo, p.err
 }
+func (p *PhotoItem) Kind() MediaKind {
+	if p.kind == "" {
+		return MediaImage
+	}
+	return p.kind
+}
 
 type StorageTest struct {
 	dir               string
@@ -33,13 +47,37 @@ type StorageTest struct {
 	downloadPhoto     string
 	downloadPhotoErr  error
 	setExifErr        error
+	changed           []string
+	sidecarErr        error
+	sidecar           string
+	// mu guards files recorded by concurrent downloads
+	mu         sync.Mutex
+	downloaded []string
+	saved      []string
+	infos      []FileInfo
 }
 
 func (s *StorageTest) Prepare(dir string) (string, error) {
 	return s.dir, s.err
 }
 
-func (s *StorageTest) DownloadPhoto(photoUrl, dir string) (string, error) {
+func (s *StorageTest) DownloadPhoto(photoUrl, dir, name string, info FileInfo) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.downloaded = append(s.downloaded, photoUrl)
+	s.infos = append(s.infos, info)
+	return s.downloadPhoto, s.downloadPhotoErr
+}
+
+func (s *StorageTest) SavePhoto(r io.Reader, dir, name string, info FileInfo) (string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.saved = append(s.saved, name+": "+string(data))
+	s.infos = append(s.infos, info)
 	return s.downloadPhoto, s.downloadPhotoErr
 }
 
@@ -47,8 +85,31 @@ func (s *StorageTest) CreateAlbumDir(rootDir, dir string) (string, error) {
 	return s.albumdir, s.createalbumdirErr
 }
 
-func (s *StorageTest) SetExif(filepath string, data ExifInfo) error {
-	return s.setExifErr
+func (s *StorageTest) SetExif(filepath string, data ExifInfo, policy MergePolicy) ([]string, error) {
+	return s.changed, s.setExifErr
+}
+
+func (s *StorageTest) WriteSidecar(filepath string, data []byte) error {
+	s.sidecar = filepath
+	return s.sidecarErr
+}
+
+type exifTest struct {
+	description string
+	created     time.Time
+	gps         *GPS
+}
+
+func (e *exifTest) Description() string {
+	return e.description
+}
+
+func (e *exifTest) Created() time.Time {
+	return e.created
+}
+
+func (e *exifTest) GPS() *GPS {
+	return e.gps
 }
 
 type testFetcher struct {
@@ -132,8 +193,10 @@ func TestNew(t *testing.T) {
 				storage:    storageTest,
 			},
 			want: &Social{
-				source:  sourceTest,
-				storage: storageTest,
+				sourceKey: "test",
+				creds:     "secrets",
+				source:    sourceTest,
+				storage:   storageTest,
 			},
 			wantErr: false,
 		},
@@ -519,3 +582,584 @@ func TestStorageError_Error(t *testing.T) {
 		})
 	}
 }
+
+func TestParseMetadataMode(t *testing.T) {
+	tests := []struct {
+		name    string
+		mode    string
+		want    MetadataMode
+		wantErr bool
+	}{
+		{name: "default", mode: "", want: MetadataEmbed},
+		{name: "sidecar", mode: "sidecar", want: MetadataSidecar},
+		{name: "both", mode: "both", want: MetadataBoth},
+		{name: "none", mode: "none", want: MetadataNone},
+		{name: "unknown", mode: "xmp", want: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseMetadataMode(tt.mode)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestSocial_writeMetadata(t *testing.T) {
+	tests := []struct {
+		name        string
+		mode        MetadataMode
+		storage     *StorageTest
+		wantSidecar string
+		wantErr     bool
+	}{
+		{
+			name:    "embed",
+			mode:    MetadataEmbed,
+			storage: &StorageTest{changed: []string{FieldDescription}},
+		},
+		{
+			name:        "embed unsupported",
+			mode:        MetadataEmbed,
+			storage:     &StorageTest{setExifErr: ErrExifUnsupported},
+			wantSidecar: "/tmp/photoD/video.mp4.xmp",
+		},
+		{
+			name:    "embed error",
+			mode:    MetadataEmbed,
+			storage: &StorageTest{setExifErr: errors.New("broken file")},
+			wantErr: true,
+		},
+		{
+			name:        "sidecar",
+			mode:        MetadataSidecar,
+			storage:     &StorageTest{setExifErr: errors.New("must not be called")},
+			wantSidecar: "/tmp/photoD/video.mp4.xmp",
+		},
+		{
+			name:        "both",
+			mode:        MetadataBoth,
+			storage:     &StorageTest{setExifErr: errors.New("broken file")},
+			wantSidecar: "/tmp/photoD/video.mp4.xmp",
+		},
+		{
+			name:    "sidecar error",
+			mode:    MetadataSidecar,
+			storage: &StorageTest{sidecarErr: errors.New("read-only")},
+			wantErr: true,
+		},
+		{
+			name:    "none",
+			mode:    MetadataNone,
+			storage: &StorageTest{setExifErr: errors.New("must not be called")},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Social{storage: tt.storage}
+			changed, err := s.writeMetadata("/tmp/photoD/video.mp4", &exifTest{description: "test"}, JobOptions{Metadata: tt.mode})
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.storage.changed, changed)
+			if tt.wantSidecar != "" {
+				assert.Equal(t, tt.wantSidecar, tt.storage.sidecar)
+			}
+		})
+	}
+}
+
+func TestXMP(t *testing.T) {
+	info := &exifTest{
+		description: "Album <1> & friends",
+		created:     time.Date(2020, 5, 17, 10, 30, 0, 0, time.UTC),
+		gps:         (&GPS{Latitude: -33.8568, Longitude: 151.2153}).WithAltitude(-5).WithAccuracy(12.5),
+	}
+	got, err := XMP(info)
+	assert.Nil(t, err)
+	assert.Contains(t, string(got), "Album &lt;1&gt; &amp; friends")
+	assert.Contains(t, string(got), "<exif:DateTimeOriginal>2020-05-17T10:30:00Z</exif:DateTimeOriginal>")
+	assert.Contains(t, string(got), "<exif:GPSLatitude>33,51.408000S</exif:GPSLatitude>")
+	assert.Contains(t, string(got), "<exif:GPSLongitude>151,12.918000E</exif:GPSLongitude>")
+	assert.Contains(t, string(got), "<exif:GPSAltitudeRef>1</exif:GPSAltitudeRef>")
+	assert.Contains(t, string(got), "<exif:GPSAltitude>500/100</exif:GPSAltitude>")
+	assert.Contains(t, string(got), "<exif:GPSHPositioningError>1250/100</exif:GPSHPositioningError>")
+
+	got, err = XMP(&exifTest{})
+	assert.Nil(t, err)
+	assert.NotContains(t, string(got), "GPSLatitude")
+	assert.NotContains(t, string(got), "dc:description")
+
+	_, err = XMP(nil)
+	assert.NotNil(t, err)
+
+	_, err = XMP(&exifTest{gps: &GPS{Latitude: 100}})
+	assert.NotNil(t, err)
+}
+
+func TestNewGPS(t *testing.T) {
+	tests := []struct {
+		name      string
+		latitude  float64
+		longitude float64
+		want      *GPS
+		wantErr   bool
+	}{
+		{name: "null island", latitude: 0, longitude: 0, want: nil},
+		{name: "equator", latitude: 0, longitude: 30.5, want: &GPS{Latitude: 0, Longitude: 30.5}},
+		{name: "south west", latitude: -33.85, longitude: -70.64, want: &GPS{Latitude: -33.85, Longitude: -70.64}},
+		{name: "latitude out of range", latitude: 90.1, longitude: 10, wantErr: true},
+		{name: "longitude out of range", latitude: 10, longitude: -180.1, wantErr: true},
+		{name: "nan", latitude: math.NaN(), longitude: 10, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewGPS(tt.latitude, tt.longitude)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestGPS_Validate(t *testing.T) {
+	assert.Nil(t, (&GPS{Latitude: 1, Longitude: 1}).WithAltitude(-400).WithAccuracy(0).Validate())
+	assert.NotNil(t, (&GPS{Latitude: 1, Longitude: 1}).WithAltitude(math.Inf(1)).Validate())
+	assert.NotNil(t, (&GPS{Latitude: 1, Longitude: 1}).WithAccuracy(-1).Validate())
+}
+
+type metadataPhotoItem struct {
+	PhotoItem
+	metadata interface{}
+}
+
+func (p *metadataPhotoItem) Metadata() interface{} {
+	return p.metadata
+}
+
+func TestSocial_writeJSON(t *testing.T) {
+	tests := []struct {
+		name        string
+		photo       Photo
+		storage     *StorageTest
+		wantSidecar string
+		wantErr     bool
+	}{
+		{
+			name:        "metadata",
+			photo:       &metadataPhotoItem{metadata: map[string]interface{}{"likes": 5}},
+			storage:     &StorageTest{},
+			wantSidecar: "/tmp/photoD/asd.jpg.json",
+		},
+		{
+			name:    "no metadata",
+			photo:   &PhotoItem{},
+			storage: &StorageTest{},
+		},
+		{
+			name:    "unsupported value",
+			photo:   &metadataPhotoItem{metadata: make(chan int)},
+			storage: &StorageTest{},
+			wantErr: true,
+		},
+		{
+			name:        "storage error",
+			photo:       &metadataPhotoItem{metadata: "text"},
+			storage:     &StorageTest{sidecarErr: errors.New("read-only")},
+			wantSidecar: "/tmp/photoD/asd.jpg.json",
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Social{storage: tt.storage}
+			err := s.writeJSON("/tmp/photoD/asd.jpg", tt.photo)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.wantSidecar, tt.storage.sidecar)
+		})
+	}
+}
+
+func TestParseMergePolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		fields  string
+		written []string
+		kept    []string
+		wantErr bool
+	}{
+		{name: "keep", fields: "", kept: []string{FieldDescription, FieldCreated, FieldGPS}},
+		{name: "all", fields: "all", written: []string{FieldDescription, FieldCreated, FieldGPS}},
+		{name: "list", fields: "gps, description", written: []string{FieldDescription, FieldGPS}, kept: []string{FieldCreated}},
+		{name: "unknown", fields: "gps,make", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, err := ParseMergePolicy(tt.fields)
+			assert.Equal(t, tt.wantErr, err != nil)
+			for _, field := range tt.written {
+				assert.True(t, policy.Write(field, true), field)
+			}
+			for _, field := range tt.kept {
+				assert.False(t, policy.Write(field, true), field)
+				assert.True(t, policy.Write(field, false), field)
+			}
+		})
+	}
+}
+
+func TestReport(t *testing.T) {
+	first := newReport("")
+	first.Add(FileReport{Path: "/tmp/photoD/asd.jpg", Changed: []string{FieldGPS}})
+	got, ok := FindReport(first.ID())
+	assert.True(t, ok)
+	assert.Equal(t, []FileReport{{Path: "/tmp/photoD/asd.jpg", Changed: []string{FieldGPS}}}, got.Files())
+
+	for i := 0; i < maxReports; i++ {
+		newReport("")
+	}
+	_, ok = FindReport(first.ID())
+	assert.False(t, ok)
+	assert.Len(t, reports, maxReports)
+}
+
+func TestReport_OwnedBy(t *testing.T) {
+	report := newReport("secrets")
+	assert.True(t, report.OwnedBy("secrets"))
+	assert.False(t, report.OwnedBy("other"))
+	assert.False(t, report.OwnedBy(""))
+}
+
+func TestSocial_savePhotosReport(t *testing.T) {
+	ch := make(chan payload, 1)
+	report := newReport("")
+	storage := &StorageTest{albumdir: "asd", downloadPhoto: "/tmp/photoD/asd.jpg", changed: []string{FieldCreated}}
+	s := &Social{
+		source:  &SourceTest{},
+		storage: storage,
+	}
+	ch <- payload{source: "vk", photo: &PhotoItem{exifInfo: &exifTest{}}, report: report}
+	close(ch)
+	s.savePhotos(ch)
+	assert.Eventually(t, func() bool { return len(report.Files()) == 1 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, []FileReport{{Path: "/tmp/photoD/asd.jpg", Changed: []string{FieldCreated}, DateUnknown: true}}, report.Files())
+	assert.Equal(t, []FileInfo{{Source: "vk"}}, storage.infos)
+}
+
+func TestSocial_savePhotosFileInfo(t *testing.T) {
+	ch := make(chan payload, 1)
+	report := newReport("")
+	storage := &StorageTest{albumdir: "asd", downloadPhoto: "/tmp/photoD/asd.jpg"}
+	s := &Social{
+		source:  &SourceTest{},
+		storage: storage,
+	}
+	created := time.Date(2021, 7, 1, 12, 0, 0, 0, time.UTC)
+	ch <- payload{source: "flickr", photo: &PhotoItem{exifInfo: &exifTest{created: created}}, report: report}
+	close(ch)
+	s.savePhotos(ch)
+	assert.Eventually(t, func() bool { return len(report.Files()) == 1 }, time.Second, 10*time.Millisecond)
+	// the date of the source is known by the storage before the file is stored
+	assert.Len(t, storage.infos, 1)
+	assert.Equal(t, "flickr", storage.infos[0].Source)
+	assert.True(t, created.Equal(storage.infos[0].Created))
+}
+
+func TestLocalTime(t *testing.T) {
+	created := time.Date(2021, 7, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		created    time.Time
+		gps        *GPS
+		wantOffset int
+		wantZero   bool
+	}{
+		{name: "unknown date", created: time.Time{}, gps: &GPS{Latitude: 55.75, Longitude: 37.62}, wantZero: true},
+		{name: "no location", created: created.In(time.FixedZone("server", 3*3600)), wantOffset: 0},
+		{name: "moscow", created: created, gps: &GPS{Latitude: 55.75, Longitude: 37.62}, wantOffset: 3 * 3600},
+		{name: "new york", created: created, gps: &GPS{Latitude: 40.71, Longitude: -74.0}, wantOffset: -4 * 3600},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := LocalTime(tt.created, tt.gps)
+			assert.Equal(t, tt.wantZero, got.IsZero())
+			if tt.wantZero {
+				return
+			}
+			assert.True(t, created.Equal(got))
+			_, offset := got.Zone()
+			assert.Equal(t, tt.wantOffset, offset)
+		})
+	}
+}
+
+func Test_normalizeTime(t *testing.T) {
+	local := time.Date(2021, 7, 1, 15, 0, 0, 0, time.FixedZone("server", 3*3600))
+	info := &exifTest{created: local, gps: &GPS{Latitude: 40.71, Longitude: -74.0}}
+
+	got := normalizeTime(info, false).Created()
+	assert.Equal(t, time.UTC, got.Location())
+	assert.True(t, local.Equal(got))
+
+	got = normalizeTime(info, true).Created()
+	assert.Equal(t, "America/New_York", got.Location().String())
+	assert.True(t, local.Equal(got))
+
+	assert.True(t, normalizeTime(&exifTest{}, true).Created().IsZero())
+}
+
+func TestParseMediaKind(t *testing.T) {
+	tests := []struct {
+		kind    string
+		want    MediaKind
+		wantErr bool
+	}{
+		{kind: "", want: ""},
+		{kind: "all", want: ""},
+		{kind: "image", want: MediaImage},
+		{kind: "video", want: MediaVideo},
+		{kind: "audio", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.kind, func(t *testing.T) {
+			got, err := ParseMediaKind(tt.kind)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestJobOptions_Accepts(t *testing.T) {
+	assert.True(t, JobOptions{}.Accepts(MediaVideo))
+	assert.True(t, JobOptions{Kind: MediaVideo}.Accepts(MediaVideo))
+	assert.False(t, JobOptions{Kind: MediaImage}.Accepts(MediaVideo))
+}
+
+func Test_thumbnailName(t *testing.T) {
+	assert.Equal(t, "123.thumb", thumbnailName("/tmp/album/123.mp4"))
+	assert.Equal(t, "video.thumb", thumbnailName("video"))
+}
+
+// localPhotoItem is a file of a local source with a thumbnail
+type localPhotoItem struct {
+	PhotoItem
+	content   string
+	thumbnail string
+}
+
+func (p *localPhotoItem) Open() (io.ReadCloser, error) {
+	return io.NopCloser(strings.NewReader(p.content)), nil
+}
+
+func (p *localPhotoItem) OpenThumbnail() (io.ReadCloser, error) {
+	if p.thumbnail == "" {
+		return nil, nil
+	}
+	return io.NopCloser(strings.NewReader(p.thumbnail)), nil
+}
+
+// ThumbnailUrl is never downloaded for local files
+func (p *localPhotoItem) ThumbnailUrl() string {
+	return "file:///etc/passwd"
+}
+
+func TestSocial_storePhoto(t *testing.T) {
+	storage := &StorageTest{downloadPhoto: "/tmp/album/1.mp4"}
+	s := &Social{storage: storage}
+	info := FileInfo{Source: "localdir", Created: time.Date(2021, 7, 1, 12, 0, 0, 0, time.UTC)}
+
+	file, err := s.storePhoto(&localPhotoItem{PhotoItem: PhotoItem{url: "file:///photos/1.mp4"}, content: "video"}, "/tmp/album", "1.mp4", info)
+	assert.NoError(t, err)
+	assert.Equal(t, "/tmp/album/1.mp4", file)
+	assert.NoError(t, s.storeThumbnail(&localPhotoItem{content: "video", thumbnail: "jpeg"}, "/tmp/album", file, info))
+	assert.NoError(t, s.storeThumbnail(&localPhotoItem{content: "video"}, "/tmp/album", file, info))
+	assert.Equal(t, []string{"1.mp4: video", "1.thumb: jpeg"}, storage.saved)
+	assert.Empty(t, storage.downloaded)
+
+	_, err = s.storePhoto(&PhotoItem{url: "https://example.com/2.jpg"}, "/tmp/album", "", info)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"https://example.com/2.jpg"}, storage.downloaded)
+	assert.Equal(t, []FileInfo{info, info, info}, storage.infos)
+}
+
+type ownerSourceTest struct {
+	SourceTest
+	owner string
+}
+
+func (source *ownerSourceTest) SetOwner(ownerID string) error {
+	if ownerID == "wrong" {
+		return errors.New("wrong owner")
+	}
+	source.owner = ownerID
+	return nil
+}
+
+func TestSocial_SetOwner(t *testing.T) {
+	s := &Social{source: &SourceTest{}}
+	assert.NoError(t, s.SetOwner(""))
+	assert.Error(t, s.SetOwner("-1"))
+
+	source := &ownerSourceTest{}
+	s = &Social{source: source}
+	assert.NoError(t, s.SetOwner("-1"))
+	assert.Equal(t, "-1", source.owner)
+	assert.Error(t, s.SetOwner("wrong"))
+}
+
+type destinationSourceTest struct {
+	SourceTest
+	root string
+}
+
+func (source *destinationSourceTest) CheckDestination(dir string) error {
+	if dir == source.root {
+		return errors.New("dump dir is the root")
+	}
+	return nil
+}
+
+func TestSocial_CheckDestination(t *testing.T) {
+	s := &Social{source: &destinationSourceTest{root: "/photos"}, storage: &StorageTest{dir: "/photos"}}
+	_, err := s.DownloadAlbum("1", "/photos")
+	assert.Error(t, err)
+	_, err = s.DownloadAllAlbums("/photos")
+	assert.Error(t, err)
+
+	s = &Social{source: &destinationSourceTest{root: "/photos"}, storage: &StorageTest{dir: "/dump"}}
+	dir, err := s.DownloadAlbum("1", "/dump")
+	assert.NoError(t, err)
+	assert.Equal(t, "/dump", dir)
+}
+
+type commentedPhotoItem struct {
+	PhotoItem
+	caption  string
+	comments []Comment
+	err      error
+}
+
+func (p *commentedPhotoItem) Caption() string {
+	return p.caption
+}
+
+func (p *commentedPhotoItem) Comments() ([]Comment, error) {
+	return p.comments, p.err
+}
+
+func Test_withComments(t *testing.T) {
+	date := time.Date(2022, 5, 1, 10, 30, 0, 0, time.UTC)
+	info := &exifTest{description: "Dumped by photoDumper"}
+	tests := []struct {
+		name     string
+		comments PhotoComments
+		want     string
+	}{
+		{name: "nothing", want: "Dumped by photoDumper"},
+		{name: "caption", comments: PhotoComments{Caption: "Sunset"}, want: "Dumped by photoDumper\n\nSunset"},
+		{
+			name:     "caption and comments",
+			comments: PhotoComments{Caption: "Sunset", Comments: []Comment{{Author: "Pavel Durov", Text: "nice", Date: date}, {Author: "Band", Text: "wow", Date: date}}},
+			want:     "Dumped by photoDumper\n\nSunset\n\nComments:\nPavel Durov (2022-05-01 10:30): nice\nBand (2022-05-01 10:30): wow",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, withComments(info, tt.comments).Description())
+		})
+	}
+}
+
+func TestSocial_saveComments(t *testing.T) {
+	storage := &StorageTest{}
+	s := &Social{storage: storage}
+	album := newAlbumComments()
+	info := &exifTest{description: "vk"}
+
+	photo := &commentedPhotoItem{caption: "Sunset", comments: []Comment{{Author: "Pavel", Text: "nice"}}}
+	got := s.saveComments("/photos/album/1.jpg", photo, info, album)
+	assert.Equal(t, "vk\n\nSunset\n\nComments:\nPavel (0001-01-01 00:00): nice", got.Description())
+	assert.Equal(t, "/photos/album/comments.json", storage.sidecar)
+
+	var path string
+	var data []byte
+	err := album.add("/photos/album/2.jpg", PhotoComments{Caption: "Sea"}, func(p string, d []byte) error {
+		path, data = p, d
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "/photos/album/comments.json", path)
+	entries := map[string]PhotoComments{}
+	assert.NoError(t, json.Unmarshal(data, &entries))
+	assert.Equal(t, []string{"Sunset", "Sea"}, []string{entries["1.jpg"].Caption, entries["2.jpg"].Caption})
+
+	storage.sidecar = ""
+	got = s.saveComments("/photos/album/3.jpg", &commentedPhotoItem{}, info, album)
+	assert.Equal(t, "vk", got.Description())
+	assert.Equal(t, "", storage.sidecar)
+
+	got = s.saveComments("/photos/album/4.jpg", &PhotoItem{}, info, album)
+	assert.Equal(t, "vk", got.Description())
+}
+
+func TestAlbumComments_addConcurrent(t *testing.T) {
+	album := newAlbumComments()
+	var last []byte
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			err := album.add(fmt.Sprintf("/photos/album/%d.jpg", i), PhotoComments{Caption: "Sea"}, func(path string, data []byte) error {
+				last = data
+				return nil
+			})
+			assert.NoError(t, err)
+		}(i)
+	}
+	wg.Wait()
+	entries := map[string]PhotoComments{}
+	assert.NoError(t, json.Unmarshal(last, &entries))
+	assert.Len(t, entries, 50)
+}
+
+func TestFolderName(t *testing.T) {
+	tests := []struct {
+		title string
+		want  string
+	}{
+		{title: "Trip", want: "Trip"},
+		{title: "Trip / Day 1", want: "Trip _ Day 1"},
+		{title: "..", want: "example.com_8080"},
+		{title: " . ", want: "example.com_8080"},
+		{title: "../../etc", want: ".._.._etc"},
+		{title: `..\\..`, want: "..__.."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			assert.Equal(t, tt.want, FolderName(tt.title, "example.com_8080"))
+		})
+	}
+	assert.Equal(t, "_", FolderName("..", ""))
+	assert.Equal(t, "gasoid/Trip _ Day 1", AlbumPath("gasoid", "Trip / Day 1"))
+	assert.Equal(t, "_/.._.ssh", AlbumPath("..", "../.ssh"))
+	assert.Equal(t, "Trip", AlbumPath("", "Trip"))
+}
+
+func TestAlbumDir(t *testing.T) {
+	root := filepath.Join("dump", "vk")
+	tests := []struct {
+		albumName string
+		want      string
+	}{
+		{albumName: "Trip", want: filepath.Join(root, "Trip")},
+		{albumName: "gasoid/2021", want: filepath.Join(root, "gasoid", "2021")},
+		{albumName: "", want: root},
+		{albumName: "../../.ssh", want: filepath.Join(root, "_", "_", ".ssh")},
+		{albumName: "/etc", want: filepath.Join(root, "etc")},
+		{albumName: `..\..\etc`, want: filepath.Join(root, ".._.._etc")},
+		{albumName: "a//./b", want: filepath.Join(root, "a", "_", "b")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.albumName, func(t *testing.T) {
+			assert.Equal(t, tt.want, AlbumDir(root, tt.albumName))
+		})
+	}
+}
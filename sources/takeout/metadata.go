@@ -0,0 +1,137 @@
+package takeout
+
+import (
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Gasoid/photoDumper/sources"
+)
+
+const (
+	jsonExt = ".json"
+	// supplementalExt is used by newer exports, e.g. IMG_1.jpg.supplemental-metadata.json
+	supplementalExt = ".supplemental-metadata"
+	// albumMetadataName describes an album, it isn't a sidecar of any file
+	albumMetadataName = "metadata.json"
+	// truncatedLength is the length Takeout cuts names of sidecars to
+	truncatedLength = 51
+)
+
+// duplicateName matches names of files with the same title, e.g. IMG_1(1).jpg,
+// their sidecars are named IMG_1.jpg(1).json
+var duplicateName = regexp.MustCompile(`^(.+)\((\d+)\)(\.[^.()]+)$`)
+
+type timestamp struct {
+	Timestamp string `json:"timestamp"`
+	Formatted string `json:"formatted"`
+}
+
+// Time returns zero time if the timestamp is missing, seconds are stored as a string
+func (t *timestamp) Time() time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	sec, err := strconv.ParseInt(t.Timestamp, 10, 64)
+	if err != nil || sec <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(sec, 0).UTC()
+}
+
+type geoData struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Altitude  float64 `json:"altitude"`
+}
+
+// gps returns nil for zero coordinates, Takeout writes them for photos without location
+func (g *geoData) gps() *sources.GPS {
+	if g == nil || g.Latitude == 0 && g.Longitude == 0 {
+		return nil
+	}
+	location, err := sources.NewGPS(g.Latitude, g.Longitude)
+	if err != nil {
+		log.Println("takeout: geoData", err)
+		return nil
+	}
+	if location != nil && g.Altitude != 0 {
+		location = location.WithAltitude(g.Altitude)
+	}
+	return location
+}
+
+// Metadata is the .json file which Takeout stores next to a media file
+type Metadata struct {
+	Title          string     `json:"title"`
+	Description    string     `json:"description"`
+	PhotoTakenTime *timestamp `json:"photoTakenTime"`
+	CreationTime   *timestamp `json:"creationTime"`
+	GeoData        *geoData   `json:"geoData"`
+	GeoDataExif    *geoData   `json:"geoDataExif"`
+	URL            string     `json:"url"`
+}
+
+// GPS prefers the location edited in Google Photos over the one of the original EXIF
+func (m *Metadata) GPS() *sources.GPS {
+	if gps := m.GeoData.gps(); gps != nil {
+		return gps
+	}
+	return m.GeoDataExif.gps()
+}
+
+// AlbumMetadata is metadata.json of an album folder
+type AlbumMetadata struct {
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Date        *timestamp `json:"date"`
+}
+
+// sidecarName finds the .json of the media file among jsons of its folder, it's empty if there is none.
+// Takeout names sidecars after the title of the photo, so edited copies, duplicates and long names
+// don't match the name of the file exactly
+func sidecarName(name string, jsons map[string]bool) string {
+	names := []string{name}
+	if unedited := strings.Replace(name, "-edited", "", 1); unedited != name {
+		names = append(names, unedited)
+	}
+	for _, n := range names {
+		candidates := []string{n + jsonExt, n + supplementalExt + jsonExt}
+		if m := duplicateName.FindStringSubmatch(n); m != nil {
+			original, suffix := m[1]+m[3], "("+m[2]+")"
+			candidates = append(candidates, original+suffix+jsonExt, original+supplementalExt+suffix+jsonExt)
+		}
+		for _, candidate := range candidates {
+			if jsons[candidate] {
+				return candidate
+			}
+		}
+		if found := truncatedSidecar(n, jsons); found != "" {
+			return found
+		}
+	}
+	return ""
+}
+
+// truncatedSidecar finds a sidecar whose name is cut by Takeout, e.g. IMG_1.jpg.supplemental-me.json
+func truncatedSidecar(name string, jsons map[string]bool) string {
+	found := []string{}
+	for j := range jsons {
+		stem := strings.TrimSuffix(j, jsonExt)
+		if len(j) < truncatedLength || j == albumMetadataName {
+			continue
+		}
+		if strings.HasPrefix(name, stem) || strings.HasPrefix(name+supplementalExt, stem) && len(stem) > len(name) {
+			found = append(found, j)
+		}
+	}
+	if len(found) == 0 {
+		return ""
+	}
+	// the longest name is the most specific one
+	sort.Slice(found, func(i, k int) bool { return len(found[i]) > len(found[k]) })
+	return found[0]
+}
@@ -0,0 +1,66 @@
+package takeout
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_sidecarName(t *testing.T) {
+	jsons := map[string]bool{
+		"IMG_1.jpg.json":                                      true,
+		"IMG_2.jpg.supplemental-metadata.json":                true,
+		"IMG_3.jpg(1).json":                                   true,
+		"IMG_4.jpg.supplemental-metadata(2).json":             true,
+		"IMG_20210703_180405123_HDR.jpg.supplemental-me.json": true,
+		"Screenshot_20210703-180405_Telegram_Messenger_.json": true,
+		"metadata.json":                                       true,
+	}
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "IMG_1.jpg", want: "IMG_1.jpg.json"},
+		{name: "IMG_1-edited.jpg", want: "IMG_1.jpg.json"},
+		{name: "IMG_2.jpg", want: "IMG_2.jpg.supplemental-metadata.json"},
+		{name: "IMG_3(1).jpg", want: "IMG_3.jpg(1).json"},
+		{name: "IMG_4(2).jpg", want: "IMG_4.jpg.supplemental-metadata(2).json"},
+		{name: "IMG_20210703_180405123_HDR.jpg", want: "IMG_20210703_180405123_HDR.jpg.supplemental-me.json"},
+		{name: "Screenshot_20210703-180405_Telegram_Messenger_Desktop.jpg", want: "Screenshot_20210703-180405_Telegram_Messenger_.json"},
+		{name: "IMG_6.jpg", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, sidecarName(tt.name, jsons))
+		})
+	}
+}
+
+func Test_timestamp_Time(t *testing.T) {
+	tests := []struct {
+		name string
+		ts   *timestamp
+		want time.Time
+	}{
+		{name: "nil", ts: nil},
+		{name: "empty", ts: &timestamp{}},
+		{name: "zero", ts: &timestamp{Timestamp: "0"}},
+		{name: "seconds", ts: &timestamp{Timestamp: "1625335445"}, want: time.Date(2021, 7, 3, 18, 4, 5, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.ts.Time())
+		})
+	}
+}
+
+func TestMetadata_GPS(t *testing.T) {
+	assert.Nil(t, (&Metadata{GeoData: &geoData{}, GeoDataExif: &geoData{}}).GPS())
+	gps := (&Metadata{GeoData: &geoData{}, GeoDataExif: &geoData{Latitude: 55.75, Longitude: 37.62, Altitude: 150}}).GPS()
+	if assert.NotNil(t, gps) {
+		assert.Equal(t, 55.75, gps.Latitude)
+		assert.Equal(t, 37.62, gps.Longitude)
+	}
+	assert.Equal(t, -33.85, (&Metadata{GeoData: &geoData{Latitude: -33.85, Longitude: 151.2}}).GPS().Latitude)
+}
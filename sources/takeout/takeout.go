@@ -0,0 +1,356 @@
+package takeout
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Gasoid/photoDumper/sources"
+	"github.com/Gasoid/photoDumper/sources/localdir"
+)
+
+// PhotoItem is a media file of the archive, metadata is taken from its .json sidecar
+type PhotoItem struct {
+	takeout   *Takeout
+	name      string
+	albumName string
+	kind      sources.MediaKind
+	meta      *Metadata
+	raw       json.RawMessage
+}
+
+// Url is a file url of the file, files of zip archives are referred by the fragment of the url of the archive
+func (f *PhotoItem) Url() string {
+	if f.takeout.dir != "" {
+		return (&url.URL{Scheme: "file", Path: filepath.ToSlash(filepath.Join(f.takeout.dir, filepath.FromSlash(f.name)))}).String()
+	}
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(f.takeout.path), Fragment: f.name}).String()
+}
+
+// Open returns the file, files of zip archives are read from the archive as they are stored
+func (f *PhotoItem) Open() (io.ReadCloser, error) {
+	fsys, err := f.takeout.acquire()
+	if err != nil {
+		return nil, err
+	}
+	file, err := fsys.Open(f.name)
+	if err != nil {
+		f.takeout.release()
+		return nil, err
+	}
+	return &archiveFile{File: file, takeout: f.takeout}, nil
+}
+
+// archiveFile releases the archive when it's closed
+type archiveFile struct {
+	fs.File
+	takeout *Takeout
+	once    sync.Once
+}
+
+func (f *archiveFile) Close() error {
+	err := f.File.Close()
+	f.once.Do(f.takeout.release)
+	return err
+}
+
+func (f *PhotoItem) AlbumName() string {
+	return f.albumName
+}
+
+func (f *PhotoItem) Kind() sources.MediaKind {
+	return f.kind
+}
+
+// Filename keeps the name of the file in the archive
+func (f *PhotoItem) Filename() string {
+	return path.Base(f.name)
+}
+
+// Metadata returns the .json sidecar as it is stored in the archive, it's nil if the file has no sidecar
+func (f *PhotoItem) Metadata() interface{} {
+	if f.raw == nil {
+		return nil
+	}
+	return f.raw
+}
+
+// ExifInfo is empty for files without a sidecar, metadata of the file itself is kept by storage
+func (f *PhotoItem) ExifInfo() (sources.ExifInfo, error) {
+	exif := &exifInfo{}
+	if f.meta != nil {
+		exif.description = strings.TrimSpace(f.meta.Description)
+		exif.created = f.meta.PhotoTakenTime.Time()
+		exif.gps = f.meta.GPS()
+	}
+	return exif, nil
+}
+
+type exifInfo struct {
+	description string
+	created     time.Time
+	gps         *sources.GPS
+}
+
+func (e *exifInfo) Description() string {
+	return e.description
+}
+
+func (e *exifInfo) Created() time.Time {
+	return e.created
+}
+
+func (e *exifInfo) GPS() *sources.GPS {
+	return e.gps
+}
+
+// Takeout is a Google Photos export, either a zip archive or an extracted directory
+type Takeout struct {
+	path string
+	// dir is set by New for extracted archives and isn't changed later
+	dir string
+
+	mu      sync.Mutex
+	archive *zip.ReadCloser
+	// refs are fetchers and open files of the archive, it's closed when the last of them is done
+	refs int
+}
+
+// New takes the path of the archive or of the extracted directory as creds
+func New(creds string) sources.Source {
+	p := creds
+	if strings.HasPrefix(p, "~") {
+		if home, err := os.UserHomeDir(); err == nil {
+			p = filepath.Join(home, p[1:])
+		}
+	}
+	t := &Takeout{path: filepath.Clean(p)}
+	if stat, err := os.Stat(t.path); err == nil && stat.IsDir() {
+		t.dir = t.path
+	}
+	return t
+}
+
+// acquire opens the archive, it stays open until every user of it calls release
+func (t *Takeout) acquire() (fs.FS, error) {
+	if t.dir != "" {
+		return os.DirFS(t.dir), nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.archive != nil {
+		t.refs++
+		return t.archive, nil
+	}
+	if _, err := os.Stat(t.path); err != nil {
+		return nil, &sources.AccessError{Text: "takeout is not readable", Err: err}
+	}
+	archive, err := zip.OpenReader(t.path)
+	if err != nil {
+		return nil, &sources.AccessError{Text: "it's neither a zip archive nor a directory", Err: err}
+	}
+	t.archive, t.refs = archive, 1
+	return archive, nil
+}
+
+// release closes the archive when nobody uses it, directories aren't kept open
+func (t *Takeout) release() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.archive == nil {
+		return
+	}
+	t.refs--
+	if t.refs == 0 {
+		if err := t.archive.Close(); err != nil {
+			log.Println("takeout:", err)
+		}
+		t.archive = nil
+	}
+}
+
+// folder is a directory of the archive with its media files and sidecars
+type folder struct {
+	media []string
+	jsons map[string]bool
+}
+
+func readFolder(fsys fs.FS, dir string) (*folder, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	f := &folder{jsons: map[string]bool{}}
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		name := entry.Name()
+		if strings.HasSuffix(strings.ToLower(name), jsonExt) {
+			f.jsons[name] = true
+			continue
+		}
+		if _, ok := localdir.KindOf(name); ok {
+			f.media = append(f.media, name)
+		}
+	}
+	sort.Strings(f.media)
+	return f, nil
+}
+
+// albumMetadata reads metadata.json of the album, folders of years have none
+func albumMetadata(fsys fs.FS, dir string) *AlbumMetadata {
+	data, err := fs.ReadFile(fsys, path.Join(dir, albumMetadataName))
+	if err != nil {
+		return nil
+	}
+	meta := &AlbumMetadata{}
+	if err := json.Unmarshal(data, meta); err != nil {
+		log.Println("takeout: album", dir, err)
+		return nil
+	}
+	return meta
+}
+
+// albumTitle is the title of metadata.json or the name of the folder
+func (t *Takeout) albumTitle(fsys fs.FS, dir string) string {
+	if meta := albumMetadata(fsys, dir); meta != nil && meta.Title != "" {
+		return meta.Title
+	}
+	if dir == "." {
+		return strings.TrimSuffix(filepath.Base(t.path), filepath.Ext(t.path))
+	}
+	return path.Base(dir)
+}
+
+// AllAlbums returns every folder with media files: albums of Google Photos and folders of years, e.g. "Photos from 2021",
+// ID of an album is the path of the folder in the archive
+func (t *Takeout) AllAlbums() ([]map[string]string, error) {
+	fsys, err := t.acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer t.release()
+	albums := []map[string]string{}
+	err = fs.WalkDir(fsys, ".", func(dir string, entry fs.DirEntry, err error) error {
+		if err != nil || !entry.IsDir() {
+			return err
+		}
+		f, err := readFolder(fsys, dir)
+		if err != nil || len(f.media) == 0 {
+			return err
+		}
+		album := map[string]string{
+			"title": t.albumTitle(fsys, dir),
+			"id":    dir,
+			"size":  fmt.Sprint(len(f.media)),
+		}
+		if meta := albumMetadata(fsys, dir); meta != nil {
+			if created := meta.Date.Time(); !created.IsZero() {
+				album["created"] = created.Format(time.RFC3339)
+			}
+		}
+		albums = append(albums, album)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(albums, func(i, j int) bool { return albums[i]["id"] < albums[j]["id"] })
+	return albums, nil
+}
+
+// AlbumPhotos returns media files of the folder paired with their sidecars
+func (t *Takeout) AlbumPhotos(albumID string) (sources.ItemFetcher, error) {
+	if !fs.ValidPath(albumID) {
+		return nil, fmt.Errorf("wrong album %q", albumID)
+	}
+	fsys, err := t.acquire()
+	if err != nil {
+		return nil, err
+	}
+	f, err := readFolder(fsys, albumID)
+	if err != nil {
+		t.release()
+		return nil, fmt.Errorf("can't read album: %w", err)
+	}
+	// titles of metadata.json are folders, the name of the folder replaces titles which aren't safe
+	albumName := sources.FolderName(t.albumTitle(fsys, albumID), path.Base(albumID))
+	return &fetcher{takeout: t, fsys: fsys, dir: albumID, folder: f, albumName: albumName, cur: -1}, nil
+}
+
+// fetcher keeps the archive open until the last file of the album is fetched
+type fetcher struct {
+	takeout   *Takeout
+	fsys      fs.FS
+	dir       string
+	albumName string
+	folder    *folder
+	cur       int
+	once      sync.Once
+}
+
+func (f *fetcher) Next() bool {
+	f.cur++
+	if f.cur < len(f.folder.media) {
+		return true
+	}
+	f.once.Do(f.takeout.release)
+	return false
+}
+
+func (f *fetcher) Item() sources.Photo {
+	name := f.folder.media[f.cur]
+	kind, _ := localdir.KindOf(name)
+	item := &PhotoItem{
+		takeout:   f.takeout,
+		name:      path.Join(f.dir, name),
+		albumName: f.albumName,
+		kind:      kind,
+	}
+	sidecar := sidecarName(name, f.folder.jsons)
+	if sidecar == "" {
+		return item
+	}
+	data, err := fs.ReadFile(f.fsys, path.Join(f.dir, sidecar))
+	if err != nil {
+		log.Println("takeout:", sidecar, err)
+		return item
+	}
+	meta := &Metadata{}
+	if err := json.Unmarshal(data, meta); err != nil {
+		log.Println("takeout:", sidecar, err)
+		return item
+	}
+	item.meta, item.raw = meta, data
+	return item
+}
+
+type service struct{}
+
+func (s *service) Kind() sources.Kind {
+	return sources.KindSource
+}
+
+func (s *service) Key() string {
+	return "takeout"
+}
+
+func (s *service) Constructor() func(creds string) sources.Source {
+	return New
+}
+
+func NewService() sources.ServiceSource {
+	return &service{}
+}
@@ -0,0 +1,202 @@
+package takeout
+
+import (
+	"archive/zip"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Gasoid/photoDumper/sources"
+	"github.com/stretchr/testify/assert"
+)
+
+// export is a small Takeout: a folder of a year and an album with metadata.json
+var export = map[string]string{
+	"Takeout/Google Photos/Photos from 2021/IMG_1.jpg":      "jpeg 1",
+	"Takeout/Google Photos/Photos from 2021/IMG_1.jpg.json": `{"title": "IMG_1.jpg", "description": "Red square", "photoTakenTime": {"timestamp": "1625335445"}, "geoData": {"latitude": 55.75, "longitude": 37.62, "altitude": 150}}`,
+	"Takeout/Google Photos/Photos from 2021/VID_2.mp4":      "video",
+	"Takeout/Google Photos/Summer/IMG_1.jpg":                "jpeg 1",
+	"Takeout/Google Photos/Summer/IMG_1.jpg.json":           `{"title": "IMG_1.jpg", "photoTakenTime": {"timestamp": "1625335445"}, "geoData": {"latitude": 0, "longitude": 0}}`,
+	"Takeout/Google Photos/Summer/metadata.json":            `{"title": "Summer: 2021", "date": {"timestamp": "1625097600"}}`,
+	"Takeout/archive_browser.html":                          "<html></html>",
+}
+
+func writeDir(t *testing.T) string {
+	root := t.TempDir()
+	for name, content := range export {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(p), 0750)
+		if err := os.WriteFile(p, []byte(content), 0640); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func writeZip(t *testing.T) string {
+	p := filepath.Join(t.TempDir(), "takeout-20221019T000000Z-001.zip")
+	f, err := os.Create(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	w := zip.NewWriter(f)
+	for name, content := range export {
+		entry, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entry.Write([]byte(content))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestTakeout(t *testing.T) {
+	tests := []struct {
+		name  string
+		creds func(t *testing.T) string
+	}{
+		{name: "directory", creds: writeDir},
+		{name: "zip", creds: writeZip},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			takeout := New(tt.creds(t))
+			albums, err := takeout.AllAlbums()
+			assert.NoError(t, err)
+			if !assert.Len(t, albums, 2) {
+				return
+			}
+			assert.Equal(t, "Takeout/Google Photos/Photos from 2021", albums[0]["id"])
+			assert.Equal(t, "Photos from 2021", albums[0]["title"])
+			assert.Equal(t, "2", albums[0]["size"])
+			assert.Equal(t, "Summer: 2021", albums[1]["title"])
+			assert.Equal(t, "2021-07-01T00:00:00Z", albums[1]["created"])
+
+			fetcher, err := takeout.AlbumPhotos(albums[0]["id"])
+			assert.NoError(t, err)
+			photos := []*PhotoItem{}
+			for fetcher.Next() {
+				photos = append(photos, fetcher.Item().(*PhotoItem))
+			}
+			if !assert.Len(t, photos, 2) {
+				return
+			}
+
+			photo := photos[0]
+			assert.Equal(t, "IMG_1.jpg", photo.Filename())
+			assert.Equal(t, "Photos from 2021", photo.AlbumName())
+			assert.Equal(t, sources.MediaImage, photo.Kind())
+			u, err := url.Parse(photo.Url())
+			assert.NoError(t, err)
+			assert.Equal(t, "file", u.Scheme)
+			r, err := photo.Open()
+			if assert.NoError(t, err) {
+				data, _ := io.ReadAll(r)
+				assert.Equal(t, "jpeg 1", string(data))
+				assert.NoError(t, r.Close())
+			}
+			// the archive is closed when the album is fetched and files are closed
+			assert.Nil(t, takeout.(*Takeout).archive)
+			info, err := photo.ExifInfo()
+			assert.NoError(t, err)
+			assert.Equal(t, "Red square", info.Description())
+			assert.Equal(t, time.Date(2021, 7, 3, 18, 4, 5, 0, time.UTC), info.Created())
+			if assert.NotNil(t, info.GPS()) {
+				assert.Equal(t, 55.75, info.GPS().Latitude)
+			}
+			assert.NotNil(t, photo.Metadata())
+
+			video := photos[1]
+			assert.Equal(t, sources.MediaVideo, video.Kind())
+			assert.Nil(t, video.Metadata())
+			info, err = video.ExifInfo()
+			assert.NoError(t, err)
+			assert.True(t, info.Created().IsZero())
+			assert.Nil(t, info.GPS())
+		})
+	}
+}
+
+func TestTakeout_archiveRefs(t *testing.T) {
+	takeout := New(writeZip(t)).(*Takeout)
+	fetcher, err := takeout.AlbumPhotos("Takeout/Google Photos/Summer")
+	assert.NoError(t, err)
+	assert.True(t, fetcher.Next())
+	photo := fetcher.Item().(*PhotoItem)
+	r, err := photo.Open()
+	assert.NoError(t, err)
+	assert.False(t, fetcher.Next())
+	assert.False(t, fetcher.Next())
+	assert.NotNil(t, takeout.archive)
+	assert.NoError(t, r.Close())
+	assert.NoError(t, r.Close())
+	assert.Nil(t, takeout.archive)
+
+	// the archive is opened again for files opened after the album is fetched
+	r, err = photo.Open()
+	assert.NoError(t, err)
+	data, _ := io.ReadAll(r)
+	assert.Equal(t, "jpeg 1", string(data))
+	r.Close()
+	assert.Nil(t, takeout.archive)
+}
+
+func TestPhotoItem_UrlBeforeOpen(t *testing.T) {
+	root := writeDir(t)
+	takeout := New(root).(*Takeout)
+	photo := &PhotoItem{takeout: takeout, name: "Takeout/Google Photos/Summer/IMG_1.jpg"}
+	want := (&url.URL{Scheme: "file", Path: filepath.ToSlash(filepath.Join(root, "Takeout", "Google Photos", "Summer", "IMG_1.jpg"))}).String()
+
+	// files of a directory are referred by their own path even before anything is read
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		r, err := photo.Open()
+		if assert.NoError(t, err) {
+			r.Close()
+		}
+	}()
+	assert.Equal(t, want, photo.Url())
+	<-done
+	assert.Equal(t, want, photo.Url())
+}
+
+func TestTakeout_albumName(t *testing.T) {
+	root := t.TempDir()
+	for name, content := range map[string]string{
+		"Trip/IMG_1.jpg":     "jpeg 1",
+		"Trip/metadata.json": `{"title": "../../.ssh"}`,
+		"Dots/IMG_2.jpg":     "jpeg 2",
+		"Dots/metadata.json": `{"title": ".."}`,
+	} {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(p), 0750)
+		os.WriteFile(p, []byte(content), 0640)
+	}
+	for albumID, want := range map[string]string{"Trip": ".._.._.ssh", "Dots": "Dots"} {
+		fetcher, err := New(root).AlbumPhotos(albumID)
+		assert.NoError(t, err)
+		assert.True(t, fetcher.Next())
+		assert.Equal(t, want, fetcher.Item().AlbumName())
+	}
+}
+
+func TestTakeout_errors(t *testing.T) {
+	_, err := New(filepath.Join(t.TempDir(), "missing.zip")).AllAlbums()
+	assert.IsType(t, &sources.AccessError{}, err)
+
+	notZip := filepath.Join(t.TempDir(), "takeout.zip")
+	os.WriteFile(notZip, []byte("not a zip"), 0640)
+	_, err = New(notZip).AllAlbums()
+	assert.IsType(t, &sources.AccessError{}, err)
+
+	_, err = New(writeDir(t)).AlbumPhotos("../etc")
+	assert.Error(t, err)
+}
@@ -0,0 +1,417 @@
+package telegram
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Gasoid/photoDumper/sources"
+)
+
+// botUrl is a variable to point tests to a fake server
+var botUrl = "https://api.telegram.org/"
+
+// updatesLimit is the maximum number of updates returned by getUpdates
+const updatesLimit = 100
+
+type Chat struct {
+	ID       int64  `json:"id"`
+	Type     string `json:"type"`
+	Title    string `json:"title,omitempty"`
+	Username string `json:"username,omitempty"`
+	First    string `json:"first_name,omitempty"`
+	Last     string `json:"last_name,omitempty"`
+}
+
+// Name is the title of a group or a channel, or the name of a user
+func (c *Chat) Name() string {
+	switch {
+	case c.Title != "":
+		return c.Title
+	case c.Username != "":
+		return c.Username
+	case c.First != "" || c.Last != "":
+		return strings.TrimSpace(c.First + " " + c.Last)
+	}
+	return fmt.Sprint(c.ID)
+}
+
+type PhotoSize struct {
+	FileID       string `json:"file_id"`
+	FileUniqueID string `json:"file_unique_id"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+	FileSize     int    `json:"file_size,omitempty"`
+}
+
+type Video struct {
+	FileID       string     `json:"file_id"`
+	FileUniqueID string     `json:"file_unique_id"`
+	MimeType     string     `json:"mime_type,omitempty"`
+	FileName     string     `json:"file_name,omitempty"`
+	Thumb        *PhotoSize `json:"thumb,omitempty"`
+}
+
+type Message struct {
+	MessageID int          `json:"message_id"`
+	Chat      *Chat        `json:"chat"`
+	Date      int64        `json:"date"`
+	Caption   string       `json:"caption,omitempty"`
+	Photo     []*PhotoSize `json:"photo,omitempty"`
+	Video     *Video       `json:"video,omitempty"`
+}
+
+// Update is a new message or a post of a channel, edits are skipped as they repeat files of messages
+type Update struct {
+	UpdateID    int      `json:"update_id"`
+	Message     *Message `json:"message,omitempty"`
+	ChannelPost *Message `json:"channel_post,omitempty"`
+}
+
+// message returns the message or the post of the update
+func (u *Update) message() *Message {
+	for _, m := range []*Message{u.Message, u.ChannelPost} {
+		if m != nil && m.Chat != nil {
+			return m
+		}
+	}
+	return nil
+}
+
+// BotError is an error response of the Bot API
+type BotError struct {
+	Code        int    `json:"error_code"`
+	Description string `json:"description"`
+	RetryAfter  int    `json:"-"`
+}
+
+func (e *BotError) Error() string {
+	return fmt.Sprintf("telegram: %s (code %d)", e.Description, e.Code)
+}
+
+// botError converts errors of the Bot API into sources.AccessError and sources.RateLimitError
+func botError(err error, text string) error {
+	e, ok := err.(*BotError)
+	if !ok {
+		return fmt.Errorf("%s: %w", text, err)
+	}
+	switch e.Code {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return &sources.AccessError{Err: err, Text: "bot token is invalid?"}
+	case http.StatusConflict:
+		return &sources.AccessError{Err: err, Text: "updates of the bot are sent to a webhook"}
+	case http.StatusTooManyRequests:
+		return &sources.RateLimitError{Err: err, Text: "telegram api limit is reached, try again later", RetryAfter: time.Duration(e.RetryAfter) * time.Second}
+	}
+	return fmt.Errorf("%s: %w", text, err)
+}
+
+type BotAPI struct {
+	token string
+}
+
+func NewBotAPI(token string) *BotAPI {
+	return &BotAPI{token: token}
+}
+
+// tokenError hides the token of the bot in the text of err, e.g. in the url of *url.Error
+type tokenError struct {
+	err   error
+	token string
+}
+
+func (e *tokenError) Error() string {
+	return strings.ReplaceAll(e.err.Error(), e.token, "<token>")
+}
+
+func (e *tokenError) Unwrap() error {
+	return e.err
+}
+
+// hideToken wraps errors of requests, urls of the api contain the token
+func (api *BotAPI) hideToken(err error) error {
+	if err == nil || api.token == "" {
+		return err
+	}
+	return &tokenError{err: err, token: api.token}
+}
+
+func (api *BotAPI) call(method string, params url.Values, r interface{}) error {
+	resp, err := http.PostForm(botUrl+"bot"+api.token+"/"+method, params)
+	if err != nil {
+		return api.hideToken(err)
+	}
+	defer resp.Body.Close()
+	envelope := &struct {
+		OK          bool            `json:"ok"`
+		Result      json.RawMessage `json:"result"`
+		ErrorCode   int             `json:"error_code"`
+		Description string          `json:"description"`
+		Parameters  struct {
+			RetryAfter int `json:"retry_after"`
+		} `json:"parameters"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(envelope); err != nil {
+		if resp.StatusCode != http.StatusOK {
+			return &BotError{Code: resp.StatusCode, Description: http.StatusText(resp.StatusCode)}
+		}
+		return fmt.Errorf("telegram: error decoding body; %w", err)
+	}
+	if !envelope.OK {
+		if envelope.ErrorCode == 0 {
+			envelope.ErrorCode = resp.StatusCode
+		}
+		return &BotError{Code: envelope.ErrorCode, Description: envelope.Description, RetryAfter: envelope.Parameters.RetryAfter}
+	}
+	return json.Unmarshal(envelope.Result, r)
+}
+
+// Updates returns up to updatesLimit updates starting from offset, they are kept by telegram for 24 hours.
+// Updates before offset are confirmed and telegram never returns them again, zero offset confirms nothing
+func (api *BotAPI) Updates(offset int) ([]*Update, error) {
+	updates := []*Update{}
+	params := url.Values{"limit": {strconv.Itoa(updatesLimit)}, "timeout": {"0"}}
+	if offset > 0 {
+		params.Set("offset", strconv.Itoa(offset))
+	}
+	err := api.call("getUpdates", params, &updates)
+	return updates, err
+}
+
+// messagesTTL is how long received messages are kept, telegram keeps updates for 24 hours as well
+const messagesTTL = 24 * time.Hour
+
+// maxStoredMessages limits messages kept per bot, the oldest ones are dropped
+const maxStoredMessages = 1000
+
+type storedMessage struct {
+	*Message
+	received time.Time
+}
+
+// updateStore keeps messages with media received by bots, updates are confirmed by paging
+// and telegram never returns them again, so later jobs of the bot read them from memory.
+// Messages are kept for messagesTTL while the server runs, other updates are dropped
+type updateStore struct {
+	mu       sync.Mutex
+	limit    int
+	offsets  map[string]int
+	messages map[string][]*storedMessage
+}
+
+func newUpdateStore(limit int) *updateStore {
+	return &updateStore{limit: limit, offsets: map[string]int{}, messages: map[string][]*storedMessage{}}
+}
+
+var received = newUpdateStore(maxStoredMessages)
+
+// receive pages through new updates of the bot and returns messages with media received so far
+func (s *updateStore) receive(api *BotAPI) ([]*Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for {
+		page, err := api.Updates(s.offsets[api.token])
+		if err != nil {
+			return nil, err
+		}
+		for _, update := range page {
+			s.offsets[api.token] = update.UpdateID + 1
+			if m := update.message(); m != nil && (len(m.Photo) > 0 || m.Video != nil) {
+				s.messages[api.token] = append(s.messages[api.token], &storedMessage{Message: m, received: now})
+			}
+		}
+		if len(page) < updatesLimit {
+			break
+		}
+	}
+	s.evict(api.token, now)
+	list := make([]*Message, 0, len(s.messages[api.token]))
+	for _, m := range s.messages[api.token] {
+		list = append(list, m.Message)
+	}
+	return list, nil
+}
+
+// evict drops messages received before messagesTTL and the oldest messages over the limit
+func (s *updateStore) evict(token string, now time.Time) {
+	list := s.messages[token]
+	for len(list) > 0 && now.Sub(list[0].received) > messagesTTL {
+		list = list[1:]
+	}
+	if len(list) > s.limit {
+		list = list[len(list)-s.limit:]
+	}
+	if len(list) == 0 {
+		delete(s.messages, token)
+		return
+	}
+	s.messages[token] = append([]*storedMessage{}, list...)
+}
+
+// fileUrl returns a download url of the file, bots can download files up to 20 MB.
+// The url contains the token, so it's never exposed
+func (api *BotAPI) fileUrl(fileID string) (string, error) {
+	file := &struct {
+		FilePath string `json:"file_path"`
+	}{}
+	if err := api.call("getFile", url.Values{"file_id": {fileID}}, file); err != nil {
+		return "", err
+	}
+	if file.FilePath == "" {
+		return "", fmt.Errorf("telegram: file %s is too big for bots", fileID)
+	}
+	return botUrl + "file/bot" + api.token + "/" + file.FilePath, nil
+}
+
+// Download returns the content of the file
+func (api *BotAPI) Download(fileID string) (io.ReadCloser, error) {
+	fileUrl, err := api.fileUrl(fileID)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.Get(fileUrl)
+	if err != nil {
+		return nil, api.hideToken(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("telegram: file %s is unavailable, code is %d", fileID, resp.StatusCode)
+	}
+	return resp.Body, nil
+}
+
+// BotItem is a file of a message received by the bot, storages copy the content downloaded by Open
+// as the url of the file contains the token
+type BotItem struct {
+	*PhotoItem
+	api    *BotAPI
+	fileID string
+}
+
+func (f *BotItem) Open() (io.ReadCloser, error) {
+	return f.api.Download(f.fileID)
+}
+
+// Bot dumps photos and videos of chats where the bot is a member, creds is the token of the bot.
+// Updates of the bot are consumed: they are confirmed while they are paged, so other programs
+// polling the bot never receive them, messages with media are kept in memory for messagesTTL
+type Bot struct {
+	api *BotAPI
+}
+
+func NewBot(creds string) sources.Source {
+	return &Bot{api: NewBotAPI(creds)}
+}
+
+// chats groups messages with media by chat
+func (b *Bot) chats() (map[int64][]*Message, map[int64]*Chat, error) {
+	list, err := received.receive(b.api)
+	if err != nil {
+		return nil, nil, botError(err, "can't get updates")
+	}
+	messages := map[int64][]*Message{}
+	chats := map[int64]*Chat{}
+	for _, m := range list {
+		messages[m.Chat.ID] = append(messages[m.Chat.ID], m)
+		chats[m.Chat.ID] = m.Chat
+	}
+	return messages, chats, nil
+}
+
+// AllAlbums returns chats with photos or videos, only updates of the last 24 hours are available to bots
+func (b *Bot) AllAlbums() ([]map[string]string, error) {
+	messages, chats, err := b.chats()
+	if err != nil {
+		return nil, err
+	}
+	albums := make([]map[string]string, 0, len(chats))
+	for id, chat := range chats {
+		albums = append(albums, map[string]string{
+			"title":   chat.Name(),
+			"id":      strconv.FormatInt(id, 10),
+			"created": time.Unix(messages[id][0].Date, 0).UTC().Format(time.RFC3339),
+			"size":    fmt.Sprint(len(messages[id])),
+		})
+	}
+	sort.Slice(albums, func(i, j int) bool { return albums[i]["title"] < albums[j]["title"] })
+	return albums, nil
+}
+
+// AlbumPhotos returns photos and videos of the chat
+func (b *Bot) AlbumPhotos(albumID string) (sources.ItemFetcher, error) {
+	chatID, err := strconv.ParseInt(albumID, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("wrong chat %q", albumID)
+	}
+	messages, chats, err := b.chats()
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := chats[chatID]; !ok {
+		return nil, errors.New("no such a chat")
+	}
+	// names of chats are folders, the ID replaces names which aren't safe
+	albumName := sources.FolderName(chats[chatID].Name(), albumID)
+	list := []sources.Photo{}
+	for _, m := range messages[chatID] {
+		list = append(list, b.item(m, albumName))
+	}
+	return newItems(list), nil
+}
+
+// item picks the largest size of a photo
+func (b *Bot) item(m *Message, albumName string) *BotItem {
+	item := &BotItem{
+		PhotoItem: &PhotoItem{
+			albumName: albumName,
+			created:   time.Unix(m.Date, 0).UTC(),
+			caption:   m.Caption,
+			message:   m,
+			kind:      sources.MediaImage,
+		},
+		api: b.api,
+	}
+	if m.Video != nil {
+		item.kind = sources.MediaVideo
+		item.fileID, item.filename = m.Video.FileID, m.Video.FileUniqueID
+	} else {
+		largest := m.Photo[0]
+		for _, size := range m.Photo {
+			if size.Width*size.Height > largest.Width*largest.Height {
+				largest = size
+			}
+		}
+		item.fileID, item.filename = largest.FileID, largest.FileUniqueID
+	}
+	// the url identifies the file without the token
+	item.url = (&url.URL{Scheme: "tg", Opaque: item.fileID}).String()
+	return item
+}
+
+type botService struct{}
+
+func (s *botService) Kind() sources.Kind {
+	return sources.KindSource
+}
+
+func (s *botService) Key() string {
+	return "telegram"
+}
+
+func (s *botService) Constructor() func(creds string) sources.Source {
+	return NewBot
+}
+
+// NewService is a source of chats where the bot is a member, it consumes updates of the bot
+func NewService() sources.ServiceSource {
+	return &botService{}
+}
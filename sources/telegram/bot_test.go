@@ -0,0 +1,247 @@
+package telegram
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Gasoid/photoDumper/sources"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeBot serves results of methods of the bot with the token "123:abc"
+func fakeBot(t *testing.T, methods map[string]func(r *http.Request) interface{}) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/file/bot123:abc/") {
+			w.Write([]byte("content of " + strings.TrimPrefix(r.URL.Path, "/file/bot123:abc/")))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		prefix := "/bot123:abc/"
+		if !strings.HasPrefix(r.URL.Path, prefix) {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]interface{}{"ok": false, "error_code": 401, "description": "Unauthorized"})
+			return
+		}
+		handler, ok := methods[strings.TrimPrefix(r.URL.Path, prefix)]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]interface{}{"ok": false, "error_code": 404, "description": "Not Found"})
+			return
+		}
+		r.ParseForm()
+		json.NewEncoder(w).Encode(map[string]interface{}{"ok": true, "result": handler(r)})
+	}))
+	old := botUrl
+	botUrl = server.URL + "/"
+	t.Cleanup(func() {
+		botUrl = old
+		received = newUpdateStore(maxStoredMessages)
+		server.Close()
+	})
+}
+
+// fromOffset returns updates which are not confirmed by the offset of the request, as telegram does
+func fromOffset(r *http.Request, list []map[string]interface{}) []map[string]interface{} {
+	offset, _ := strconv.Atoi(r.Form.Get("offset"))
+	result := []map[string]interface{}{}
+	for _, update := range list {
+		if update["update_id"].(int) >= offset && len(result) < updatesLimit {
+			result = append(result, update)
+		}
+	}
+	return result
+}
+
+var updates = []map[string]interface{}{
+	{"update_id": 1, "channel_post": map[string]interface{}{
+		"message_id": 10, "date": 1625335445, "caption": "Red square",
+		"chat": map[string]interface{}{"id": -1001, "type": "channel", "title": "Trips"},
+		"photo": []map[string]interface{}{
+			{"file_id": "small", "file_unique_id": "s", "width": 90, "height": 60},
+			{"file_id": "large", "file_unique_id": "AQADlarge", "width": 1280, "height": 853},
+		},
+	}},
+	{"update_id": 2, "message": map[string]interface{}{
+		"message_id": 11, "date": 1625335500, "text": "no media",
+		"chat": map[string]interface{}{"id": 42, "type": "private", "first_name": "Anna"},
+	}},
+	{"update_id": 3, "message": map[string]interface{}{
+		"message_id": 12, "date": 1625335600,
+		"chat":  map[string]interface{}{"id": 42, "type": "private", "first_name": "Anna"},
+		"video": map[string]interface{}{"file_id": "video", "file_unique_id": "AQADvideo", "mime_type": "video/mp4"},
+	}},
+}
+
+func TestBot(t *testing.T) {
+	offsets := []string{}
+	fakeBot(t, map[string]func(r *http.Request) interface{}{
+		"getUpdates": func(r *http.Request) interface{} {
+			offsets = append(offsets, r.Form.Get("offset"))
+			return fromOffset(r, updates)
+		},
+		"getFile": func(r *http.Request) interface{} {
+			return map[string]string{"file_id": r.Form.Get("file_id"), "file_path": "photos/" + r.Form.Get("file_id") + ".jpg"}
+		},
+	})
+	bot := NewBot("123:abc")
+
+	albums, err := bot.AllAlbums()
+	assert.NoError(t, err)
+	assert.Equal(t, []map[string]string{
+		{"id": "42", "title": "Anna", "size": "1", "created": "2021-07-03T18:06:40Z"},
+		{"id": "-1001", "title": "Trips", "size": "1", "created": "2021-07-03T18:04:05Z"},
+	}, albums)
+
+	fetcher, err := bot.AlbumPhotos("-1001")
+	assert.NoError(t, err)
+	assert.True(t, fetcher.Next())
+	photo := fetcher.Item().(*BotItem)
+	// the url of the file contains the token, the file is downloaded by Open
+	assert.Equal(t, "tg:large", photo.Url())
+	r, err := photo.Open()
+	if assert.NoError(t, err) {
+		data, _ := io.ReadAll(r)
+		r.Close()
+		assert.Equal(t, "content of photos/large.jpg", string(data))
+	}
+	assert.Equal(t, "AQADlarge", photo.Filename())
+	assert.Equal(t, "Trips", photo.AlbumName())
+	info, err := photo.ExifInfo()
+	assert.NoError(t, err)
+	assert.Equal(t, "Red square", info.Description())
+	assert.Equal(t, time.Date(2021, 7, 3, 18, 4, 5, 0, time.UTC), info.Created())
+	assert.False(t, fetcher.Next())
+
+	fetcher, err = bot.AlbumPhotos("42")
+	assert.NoError(t, err)
+	assert.True(t, fetcher.Next())
+	assert.Equal(t, sources.MediaVideo, fetcher.Item().Kind())
+	// the first request confirms nothing, later ones ask for new updates only
+	assert.Equal(t, []string{"", "4", "4"}, offsets)
+
+	_, err = bot.AlbumPhotos("7")
+	assert.EqualError(t, err, "no such a chat")
+
+	_, err = NewBot("wrong").AllAlbums()
+	assert.IsType(t, &sources.AccessError{}, err)
+}
+
+func TestBot_updatesPaging(t *testing.T) {
+	list := []map[string]interface{}{}
+	for i := 1; i <= 250; i++ {
+		list = append(list, map[string]interface{}{"update_id": i, "channel_post": map[string]interface{}{
+			"message_id": i, "date": 1625335445,
+			"chat":  map[string]interface{}{"id": -1001, "type": "channel", "title": "Trips"},
+			"photo": []map[string]interface{}{{"file_id": fmt.Sprint(i), "file_unique_id": fmt.Sprint(i), "width": 90, "height": 60}},
+		}})
+	}
+	offsets := []string{}
+	fakeBot(t, map[string]func(r *http.Request) interface{}{
+		"getUpdates": func(r *http.Request) interface{} {
+			offsets = append(offsets, r.Form.Get("offset"))
+			return fromOffset(r, list)
+		},
+	})
+	albums, err := NewBot("123:abc").AllAlbums()
+	assert.NoError(t, err)
+	assert.Equal(t, "250", albums[0]["size"])
+	assert.Equal(t, []string{"", "101", "201"}, offsets)
+
+	// confirmed updates are kept for later jobs
+	albums, err = NewBot("123:abc").AllAlbums()
+	assert.NoError(t, err)
+	assert.Equal(t, "250", albums[0]["size"])
+	assert.Equal(t, []string{"", "101", "201", "251"}, offsets)
+}
+
+func TestBot_storedMessages(t *testing.T) {
+	list := []map[string]interface{}{}
+	fakeBot(t, map[string]func(r *http.Request) interface{}{
+		"getUpdates": func(r *http.Request) interface{} {
+			return fromOffset(r, list)
+		},
+	})
+	// posts with photos alternate with messages without media
+	for i := 1; i <= 8; i++ {
+		update := map[string]interface{}{"update_id": i, "message": updates[1]["message"]}
+		if i%2 == 1 {
+			update = map[string]interface{}{"update_id": i, "channel_post": updates[0]["channel_post"]}
+		}
+		list = append(list, update)
+	}
+	store := newUpdateStore(3)
+	api := NewBotAPI("123:abc")
+
+	// updates without media aren't kept, the oldest messages over the limit are dropped
+	messages, err := store.receive(api)
+	assert.NoError(t, err)
+	assert.Len(t, messages, 3)
+	assert.Equal(t, 9, store.offsets["123:abc"])
+
+	for _, m := range store.messages["123:abc"] {
+		m.received = m.received.Add(-messagesTTL - time.Minute)
+	}
+	messages, err = store.receive(api)
+	assert.NoError(t, err)
+	assert.Empty(t, messages)
+	assert.NotContains(t, store.messages, "123:abc")
+}
+
+func TestBotAPI_hideToken(t *testing.T) {
+	fakeBot(t, map[string]func(r *http.Request) interface{}{})
+	botUrl = "http://127.0.0.1:0/"
+	api := NewBotAPI("123:abc")
+	_, err := api.Updates(0)
+	if assert.Error(t, err) {
+		assert.NotContains(t, err.Error(), "123:abc")
+		assert.Contains(t, err.Error(), "bot<token>/getUpdates")
+	}
+	_, err = api.Download("large")
+	if assert.Error(t, err) {
+		assert.NotContains(t, err.Error(), "123:abc")
+	}
+}
+
+func Test_botError(t *testing.T) {
+	err := botError(&BotError{Code: http.StatusTooManyRequests, Description: "Too Many Requests: retry after 5", RetryAfter: 5}, "text")
+	if assert.IsType(t, &sources.RateLimitError{}, err) {
+		assert.Equal(t, 5*time.Second, err.(*sources.RateLimitError).RetryAfter)
+	}
+	assert.IsType(t, &sources.AccessError{}, botError(&BotError{Code: http.StatusConflict}, "text"))
+	var botErr *BotError
+	assert.True(t, errors.As(botError(&BotError{Code: http.StatusBadRequest}, "text"), &botErr))
+}
+
+func TestBot_chatName(t *testing.T) {
+	fakeBot(t, map[string]func(r *http.Request) interface{}{
+		"getUpdates": func(r *http.Request) interface{} {
+			return fromOffset(r, []map[string]interface{}{
+				{"update_id": 1, "channel_post": map[string]interface{}{
+					"message_id": 10, "date": 1625335445,
+					"chat":  map[string]interface{}{"id": -1002, "type": "channel", "title": "../../.ssh"},
+					"photo": []map[string]interface{}{{"file_id": "large", "file_unique_id": "AQADlarge", "width": 1280, "height": 853}},
+				}},
+				{"update_id": 2, "channel_post": map[string]interface{}{
+					"message_id": 11, "date": 1625335445,
+					"chat":  map[string]interface{}{"id": -1003, "type": "channel", "title": ".."},
+					"photo": []map[string]interface{}{{"file_id": "large", "file_unique_id": "AQADlarge", "width": 1280, "height": 853}},
+				}},
+			})
+		},
+	})
+	bot := NewBot("123:abc")
+	for chatID, want := range map[string]string{"-1002": ".._.._.ssh", "-1003": "-1003"} {
+		fetcher, err := bot.AlbumPhotos(chatID)
+		assert.NoError(t, err)
+		assert.True(t, fetcher.Next())
+		assert.Equal(t, want, fetcher.Item().AlbumName())
+	}
+}
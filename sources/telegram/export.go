@@ -0,0 +1,286 @@
+package telegram
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Gasoid/photoDumper/sources"
+)
+
+const (
+	resultName = "result.json"
+	// dateLayout is the local time of the exporting computer, newer exports have date_unixtime
+	dateLayout = "2006-01-02T15:04:05"
+	// notIncluded starts paths of files which were skipped by export settings
+	notIncluded = "(File not included"
+)
+
+// text is a plain string or a list of strings and formatted entities
+type text string
+
+func (t *text) UnmarshalJSON(data []byte) error {
+	var plain string
+	if err := json.Unmarshal(data, &plain); err == nil {
+		*t = text(plain)
+		return nil
+	}
+	parts := []json.RawMessage{}
+	if err := json.Unmarshal(data, &parts); err != nil {
+		return err
+	}
+	b := strings.Builder{}
+	for _, part := range parts {
+		entity := &struct {
+			Text string `json:"text"`
+		}{}
+		if err := json.Unmarshal(part, &plain); err == nil {
+			b.WriteString(plain)
+		} else if err := json.Unmarshal(part, entity); err == nil {
+			b.WriteString(entity.Text)
+		}
+	}
+	*t = text(b.String())
+	return nil
+}
+
+// ExportMessage is a message of a chat exported by Telegram Desktop
+type ExportMessage struct {
+	ID           int    `json:"id"`
+	Type         string `json:"type"`
+	Date         string `json:"date"`
+	DateUnixtime string `json:"date_unixtime,omitempty"`
+	From         string `json:"from,omitempty"`
+	Photo        string `json:"photo,omitempty"`
+	File         string `json:"file,omitempty"`
+	Thumbnail    string `json:"thumbnail,omitempty"`
+	MediaType    string `json:"media_type,omitempty"`
+	MimeType     string `json:"mime_type,omitempty"`
+	Text         text   `json:"text"`
+}
+
+// created prefers the unix time, dates of older exports are in the local zone of the computer
+func (m *ExportMessage) created() time.Time {
+	if sec, err := strconv.ParseInt(m.DateUnixtime, 10, 64); err == nil && sec > 0 {
+		return time.Unix(sec, 0).UTC()
+	}
+	created, err := time.ParseInLocation(dateLayout, m.Date, time.Local)
+	if err != nil {
+		return time.Time{}
+	}
+	return created
+}
+
+// media returns the path of the photo or the video and its kind, ok is false for other messages
+func (m *ExportMessage) media() (string, sources.MediaKind, bool) {
+	if m.Type != "message" {
+		return "", "", false
+	}
+	if m.Photo != "" && !strings.HasPrefix(m.Photo, notIncluded) {
+		return m.Photo, sources.MediaImage, true
+	}
+	if m.File == "" || strings.HasPrefix(m.File, notIncluded) {
+		return "", "", false
+	}
+	switch {
+	case m.MediaType == "video_file" || strings.HasPrefix(m.MimeType, "video/"):
+		return m.File, sources.MediaVideo, true
+	case strings.HasPrefix(m.MimeType, "image/"):
+		return m.File, sources.MediaImage, true
+	}
+	return "", "", false
+}
+
+type ExportChat struct {
+	ID       int64            `json:"id"`
+	Name     string           `json:"name"`
+	Type     string           `json:"type"`
+	Messages []*ExportMessage `json:"messages"`
+}
+
+func (c *ExportChat) title() string {
+	if c.Name != "" {
+		return c.Name
+	}
+	if c.Type == "saved_messages" {
+		return "Saved Messages"
+	}
+	return fmt.Sprint(c.ID)
+}
+
+// export is result.json of a single chat or of the whole account
+type export struct {
+	ExportChat
+	Chats *struct {
+		List []*ExportChat `json:"list"`
+	} `json:"chats"`
+	LeftChats *struct {
+		List []*ExportChat `json:"list"`
+	} `json:"left_chats"`
+}
+
+func (e *export) chats() []*ExportChat {
+	if e.Chats == nil {
+		return []*ExportChat{&e.ExportChat}
+	}
+	chats := e.Chats.List
+	if e.LeftChats != nil {
+		chats = append(chats, e.LeftChats.List...)
+	}
+	return chats
+}
+
+// Export is a chat or all chats exported by Telegram Desktop in JSON format, creds is the directory of the export
+type Export struct {
+	dir string
+}
+
+func NewExport(creds string) sources.Source {
+	dir := creds
+	if strings.HasPrefix(dir, "~") {
+		if home, err := os.UserHomeDir(); err == nil {
+			dir = filepath.Join(home, dir[1:])
+		}
+	}
+	if filepath.Base(dir) == resultName {
+		dir = filepath.Dir(dir)
+	}
+	return &Export{dir: filepath.Clean(dir)}
+}
+
+func (e *Export) read() ([]*ExportChat, error) {
+	data, err := os.ReadFile(filepath.Join(e.dir, resultName))
+	if err != nil {
+		return nil, &sources.AccessError{Text: "result.json of the export is not readable", Err: err}
+	}
+	result := &export{}
+	if err := json.Unmarshal(data, result); err != nil {
+		return nil, fmt.Errorf("telegram: wrong export; %w", err)
+	}
+	return result.chats(), nil
+}
+
+// ExportItem is a file of the export, storages copy it from the disk
+type ExportItem struct {
+	*PhotoItem
+	path      string
+	thumbnail string
+}
+
+func (f *ExportItem) Open() (io.ReadCloser, error) {
+	return os.Open(f.path)
+}
+
+// OpenThumbnail returns the thumbnail of a video, it's nil for photos and videos without thumbnails
+func (f *ExportItem) OpenThumbnail() (io.ReadCloser, error) {
+	if f.thumbnail == "" {
+		return nil, nil
+	}
+	return os.Open(f.thumbnail)
+}
+
+// items returns media of the chat, files which are missing in the export are skipped
+func (e *Export) items(chat *ExportChat) []*ExportItem {
+	list := []*ExportItem{}
+	for _, m := range chat.Messages {
+		name, kind, ok := m.media()
+		if !ok {
+			continue
+		}
+		path := filepath.Join(e.dir, filepath.FromSlash(name))
+		if _, err := os.Stat(path); err != nil {
+			log.Println("telegram: message", m.ID, err)
+			continue
+		}
+		item := &ExportItem{
+			PhotoItem: &PhotoItem{
+				url:       fileUrl(path),
+				filename:  filepath.Base(path),
+				albumName: sources.FolderName(chat.title(), fmt.Sprint(chat.ID)),
+				kind:      kind,
+				created:   m.created(),
+				caption:   string(m.Text),
+				message:   m,
+			},
+			path: path,
+		}
+		if kind == sources.MediaVideo && m.Thumbnail != "" {
+			item.thumbnail = filepath.Join(e.dir, filepath.FromSlash(m.Thumbnail))
+		}
+		list = append(list, item)
+	}
+	return list
+}
+
+func fileUrl(path string) string {
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+}
+
+// AllAlbums returns chats with photos or videos
+func (e *Export) AllAlbums() ([]map[string]string, error) {
+	chats, err := e.read()
+	if err != nil {
+		return nil, err
+	}
+	albums := []map[string]string{}
+	for _, chat := range chats {
+		list := e.items(chat)
+		if len(list) == 0 {
+			continue
+		}
+		albums = append(albums, map[string]string{
+			"title":   chat.title(),
+			"id":      strconv.FormatInt(chat.ID, 10),
+			"created": list[0].created.UTC().Format(time.RFC3339),
+			"size":    fmt.Sprint(len(list)),
+		})
+	}
+	sort.Slice(albums, func(i, j int) bool { return albums[i]["title"] < albums[j]["title"] })
+	return albums, nil
+}
+
+// AlbumPhotos returns photos and videos of the chat
+func (e *Export) AlbumPhotos(albumID string) (sources.ItemFetcher, error) {
+	chats, err := e.read()
+	if err != nil {
+		return nil, err
+	}
+	for _, chat := range chats {
+		if strconv.FormatInt(chat.ID, 10) == albumID {
+			list := []sources.Photo{}
+			for _, item := range e.items(chat) {
+				list = append(list, item)
+			}
+			return newItems(list), nil
+		}
+	}
+	return nil, errors.New("no such a chat")
+}
+
+type exportService struct{}
+
+func (s *exportService) Kind() sources.Kind {
+	return sources.KindSource
+}
+
+func (s *exportService) Key() string {
+	return "telegram_export"
+}
+
+func (s *exportService) Constructor() func(creds string) sources.Source {
+	return NewExport
+}
+
+// NewExportService is a source of chats exported by Telegram Desktop
+func NewExportService() sources.ServiceSource {
+	return &exportService{}
+}
@@ -0,0 +1,128 @@
+package telegram
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Gasoid/photoDumper/sources"
+	"github.com/stretchr/testify/assert"
+)
+
+const result = `{
+ "about": "Here is the data you requested.",
+ "chats": {
+  "about": "This page lists all chats from this export.",
+  "list": [
+   {
+    "name": "Trips",
+    "type": "public_channel",
+    "id": 1001,
+    "messages": [
+     {"id": 1, "type": "service", "date": "2021-07-03T18:00:00", "action": "create_channel", "text": ""},
+     {"id": 2, "type": "message", "date": "2021-07-03T21:04:05", "date_unixtime": "1625335445", "photo": "chats/chat_01/photos/photo_1@03-07-2021_21-04-05.jpg", "width": 1280, "height": 853,
+      "text": ["Red ", {"type": "bold", "text": "square"}]},
+     {"id": 3, "type": "message", "date": "2021-07-03T21:05:00", "file": "chats/chat_01/video_files/IMG_1.MP4", "thumbnail": "chats/chat_01/video_files/IMG_1.MP4_thumb.jpg",
+      "media_type": "video_file", "mime_type": "video/mp4", "text": ""},
+     {"id": 4, "type": "message", "date": "2021-07-03T21:06:00", "photo": "(File not included. Change data exporting settings to download.)", "text": "skipped"},
+     {"id": 5, "type": "message", "date": "2021-07-03T21:07:00", "file": "chats/chat_01/files/notes.pdf", "mime_type": "application/pdf", "text": ""}
+    ]
+   },
+   {"name": "Anna", "type": "personal_chat", "id": 42, "messages": [{"id": 1, "type": "message", "date": "2021-07-03T21:04:05", "text": "hi"}]}
+  ]
+ }
+}`
+
+func writeExport(t *testing.T) string {
+	dir := t.TempDir()
+	files := map[string]string{
+		"result.json": result,
+		"chats/chat_01/photos/photo_1@03-07-2021_21-04-05.jpg": "jpeg",
+		"chats/chat_01/video_files/IMG_1.MP4":                  "video",
+		"chats/chat_01/video_files/IMG_1.MP4_thumb.jpg":        "thumb",
+		"chats/chat_01/files/notes.pdf":                        "pdf",
+	}
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(p), 0750)
+		if err := os.WriteFile(p, []byte(content), 0640); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestExport(t *testing.T) {
+	dir := writeExport(t)
+	export := NewExport(filepath.Join(dir, "result.json"))
+
+	albums, err := export.AllAlbums()
+	assert.NoError(t, err)
+	assert.Equal(t, []map[string]string{{"id": "1001", "title": "Trips", "size": "2", "created": "2021-07-03T18:04:05Z"}}, albums)
+
+	fetcher, err := export.AlbumPhotos("1001")
+	assert.NoError(t, err)
+	photos := []*ExportItem{}
+	for fetcher.Next() {
+		photos = append(photos, fetcher.Item().(*ExportItem))
+	}
+	if !assert.Len(t, photos, 2) {
+		return
+	}
+	photo := photos[0]
+	assert.Equal(t, "file://"+filepath.ToSlash(filepath.Join(dir, "chats", "chat_01", "photos", "photo_1@03-07-2021_21-04-05.jpg")), photo.Url())
+	assert.Equal(t, "photo_1@03-07-2021_21-04-05.jpg", photo.Filename())
+	assert.Equal(t, "Trips", photo.AlbumName())
+	info, err := photo.ExifInfo()
+	assert.NoError(t, err)
+	assert.Equal(t, "Red square", info.Description())
+	assert.Equal(t, time.Date(2021, 7, 3, 18, 4, 5, 0, time.UTC), info.Created())
+
+	video := photos[1]
+	assert.Equal(t, sources.MediaVideo, video.Kind())
+	r, err := video.OpenThumbnail()
+	if assert.NoError(t, err) && assert.NotNil(t, r) {
+		r.Close()
+	}
+	r, err = photo.OpenThumbnail()
+	assert.NoError(t, err)
+	assert.Nil(t, r)
+	info, _ = video.ExifInfo()
+	assert.Equal(t, time.Date(2021, 7, 3, 21, 5, 0, 0, time.Local), info.Created())
+
+	_, err = export.AlbumPhotos("42")
+	assert.NoError(t, err)
+	_, err = export.AlbumPhotos("7")
+	assert.Error(t, err)
+	_, err = NewExport(t.TempDir()).AllAlbums()
+	assert.IsType(t, &sources.AccessError{}, err)
+}
+
+func TestExport_singleChat(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "photos"), 0750)
+	os.WriteFile(filepath.Join(dir, "photos", "photo_1.jpg"), []byte("jpeg"), 0640)
+	os.WriteFile(filepath.Join(dir, "result.json"), []byte(`{"name": "", "type": "saved_messages", "id": 7, "messages": [
+		{"id": 1, "type": "message", "date": "2021-07-03T21:04:05", "date_unixtime": "1625335445", "photo": "photos/photo_1.jpg", "text": "note"}]}`), 0640)
+
+	albums, err := NewExport(dir).AllAlbums()
+	assert.NoError(t, err)
+	if assert.Len(t, albums, 1) {
+		assert.Equal(t, "Saved Messages", albums[0]["title"])
+		assert.Equal(t, "7", albums[0]["id"])
+	}
+}
+
+func TestExport_chatName(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "photos"), 0750)
+	os.WriteFile(filepath.Join(dir, "photos", "photo_1.jpg"), []byte("jpeg"), 0640)
+	os.WriteFile(filepath.Join(dir, "result.json"), []byte(`{"name": "../../.ssh", "type": "private_group", "id": 7, "messages": [
+		{"id": 1, "type": "message", "date": "2021-07-03T21:04:05", "date_unixtime": "1625335445", "photo": "photos/photo_1.jpg"}]}`), 0640)
+
+	fetcher, err := NewExport(dir).AlbumPhotos("7")
+	assert.NoError(t, err)
+	assert.True(t, fetcher.Next())
+	assert.Equal(t, ".._.._.ssh", fetcher.Item().AlbumName())
+}
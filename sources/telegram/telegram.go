@@ -0,0 +1,83 @@
+// Package telegram dumps photos and videos of chats and channels,
+// either from a Telegram Desktop export or from updates received by a bot
+package telegram
+
+import (
+	"strings"
+	"time"
+
+	"github.com/Gasoid/photoDumper/sources"
+)
+
+// PhotoItem is a photo or a video of a message, storages copy the content of ExportItem and BotItem
+type PhotoItem struct {
+	url       string
+	filename  string
+	albumName string
+	kind      sources.MediaKind
+	created   time.Time
+	caption   string
+	message   interface{}
+}
+
+func (f *PhotoItem) Url() string {
+	return f.url
+}
+
+func (f *PhotoItem) AlbumName() string {
+	return f.albumName
+}
+
+func (f *PhotoItem) Kind() sources.MediaKind {
+	return f.kind
+}
+
+func (f *PhotoItem) Filename() string {
+	return f.filename
+}
+
+// Metadata returns the message as it is stored in the export or received by the bot
+func (f *PhotoItem) Metadata() interface{} {
+	return f.message
+}
+
+// ExifInfo uses the date of the message and its caption
+func (f *PhotoItem) ExifInfo() (sources.ExifInfo, error) {
+	return &exifInfo{description: strings.TrimSpace(f.caption), created: f.created}, nil
+}
+
+type exifInfo struct {
+	description string
+	created     time.Time
+}
+
+func (e *exifInfo) Description() string {
+	return e.description
+}
+
+func (e *exifInfo) Created() time.Time {
+	return e.created
+}
+
+func (e *exifInfo) GPS() *sources.GPS {
+	return nil
+}
+
+// items is a fetcher of items prepared in advance, chats are read at once
+type items struct {
+	list []sources.Photo
+	cur  int
+}
+
+func newItems(list []sources.Photo) *items {
+	return &items{list: list, cur: -1}
+}
+
+func (i *items) Next() bool {
+	i.cur++
+	return i.cur < len(i.list)
+}
+
+func (i *items) Item() sources.Photo {
+	return i.list[i.cur]
+}
@@ -0,0 +1,62 @@
+package sources
+
+import (
+	"time"
+
+	"github.com/bradfitz/latlong"
+)
+
+// LocalTime converts created into the time zone of the location,
+// the zone is looked up in the tz boundary dataset embedded into latlong.
+// created is returned in UTC if the location or its zone is unknown.
+func LocalTime(created time.Time, gps *GPS) time.Time {
+	if created.IsZero() {
+		return created
+	}
+	created = created.UTC()
+	location := Zone(gps)
+	if location == nil {
+		return created
+	}
+	return created.In(location)
+}
+
+// Zone returns the time zone of the location, it's nil if the location or its zone is unknown.
+// Sources which know only the wall clock of a photo parse it in this zone.
+func Zone(gps *GPS) *time.Location {
+	if gps == nil {
+		return nil
+	}
+	name := latlong.LookupZoneName(gps.Latitude, gps.Longitude)
+	if name == "" {
+		return nil
+	}
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return nil
+	}
+	return location
+}
+
+// zonedExif overrides capture time of an ExifInfo
+type zonedExif struct {
+	ExifInfo
+	created time.Time
+}
+
+func (z *zonedExif) Created() time.Time {
+	return z.created
+}
+
+// normalizeTime makes capture time of info UTC or local to its location if inferZone is set,
+// unknown (zero) time stays unknown
+func normalizeTime(info ExifInfo, inferZone bool) ExifInfo {
+	created := info.Created()
+	if created.IsZero() {
+		return info
+	}
+	if inferZone {
+		return &zonedExif{ExifInfo: info, created: LocalTime(created, info.GPS())}
+	}
+	return &zonedExif{ExifInfo: info, created: created.UTC()}
+}
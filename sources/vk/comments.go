@@ -0,0 +1,62 @@
+package vk
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Gasoid/photoDumper/sources"
+	"github.com/SevereCloud/vksdk/v2/api"
+)
+
+const maxCommentsCount = 100
+
+// Caption is the text of the photo
+func (f *PhotoItem) Caption() string {
+	return f.photo.Text
+}
+
+// Comments requests all comments of the photo, authors are named by their profiles
+func (f *PhotoItem) Comments() ([]sources.Comment, error) {
+	// photos.get with extended=1 returns the number of comments, photos without them aren't requested
+	if f.vkAPI == nil || f.photo.Comments.Count == 0 {
+		return nil, nil
+	}
+	comments := []sources.Comment{}
+	for offset := 0; ; offset += maxCommentsCount {
+		resp, err := f.vkAPI.PhotosGetCommentsExtended(api.Params{
+			"owner_id": f.photo.OwnerID,
+			"photo_id": f.photo.ID,
+			"count":    maxCommentsCount,
+			"offset":   offset,
+			"sort":     "asc",
+		})
+		if err != nil {
+			return comments, makeError(err, "GetComments failed")
+		}
+		authors := map[int]string{}
+		for _, user := range resp.Profiles {
+			authors[user.ID] = strings.TrimSpace(user.FirstName + " " + user.LastName)
+		}
+		for _, group := range resp.Groups {
+			authors[-group.ID] = group.Name
+		}
+		for _, item := range resp.Items {
+			if bool(item.Deleted) || item.Text == "" {
+				continue
+			}
+			author, ok := authors[item.FromID]
+			if !ok {
+				author = fmt.Sprintf("id%d", item.FromID)
+			}
+			comments = append(comments, sources.Comment{
+				Author: author,
+				Text:   item.Text,
+				Date:   time.Unix(int64(item.Date), 0).UTC(),
+			})
+		}
+		if len(resp.Items) == 0 || offset+len(resp.Items) >= resp.Count {
+			return comments, nil
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package vk
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Gasoid/photoDumper/sources"
+	"github.com/SevereCloud/vksdk/v2/api"
+	"github.com/SevereCloud/vksdk/v2/object"
+)
+
+const (
+	maxConversationsCount = 200
+	maxAttachmentsCount   = 200
+	// messagesFolder keeps conversations apart from photo albums
+	messagesFolder = "messages"
+)
+
+// Messages is a source of photos attached to messages, every conversation is an album
+type Messages struct {
+	vkAPI *api.VK
+}
+
+func NewMessages(creds string) sources.Source {
+	return &Messages{vkAPI: api.NewVK(creds)}
+}
+
+// conversationTitle returns title of a chat or name of the user or the community of a dialog
+func conversationTitle(conversation object.MessagesConversation, extended object.ExtendedResponse) string {
+	peer := conversation.Peer
+	switch peer.Type {
+	case "chat":
+		if conversation.ChatSettings.Title != "" {
+			return conversation.ChatSettings.Title
+		}
+	case "user":
+		for _, user := range extended.Profiles {
+			if user.ID == peer.ID {
+				return strings.TrimSpace(user.FirstName + " " + user.LastName)
+			}
+		}
+	case "group":
+		for _, group := range extended.Groups {
+			if -group.ID == peer.ID {
+				return group.Name
+			}
+		}
+	}
+	return fmt.Sprintf("peer%d", peer.ID)
+}
+
+// AllAlbums lists conversations, ID of an album is peer_id of the conversation
+func (m *Messages) AllAlbums() ([]map[string]string, error) {
+	albums := []map[string]string{}
+	for offset := 0; ; offset += maxConversationsCount {
+		resp, err := m.vkAPI.MessagesGetConversations(api.Params{"count": maxConversationsCount, "offset": offset, "extended": 1})
+		if err != nil {
+			return nil, makeError(err, "GetConversations failed")
+		}
+		for _, item := range resp.Items {
+			albums = append(albums, map[string]string{
+				"title":   conversationTitle(item.Conversation, resp.ExtendedResponse),
+				"id":      fmt.Sprint(item.Conversation.Peer.ID),
+				"created": time.Unix(int64(item.LastMessage.Date), 0).UTC().Format(time.RFC3339),
+				"thumb":   item.Conversation.ChatSettings.Photo.Photo200,
+				"kind":    string(sources.MediaImage),
+			})
+		}
+		if len(resp.Items) == 0 || offset+len(resp.Items) >= resp.Count {
+			return albums, nil
+		}
+	}
+}
+
+// AlbumPhotos returns photos attached to messages of the conversation
+func (m *Messages) AlbumPhotos(albumID string) (sources.ItemFetcher, error) {
+	peerID, err := strconv.Atoi(albumID)
+	if err != nil {
+		return nil, fmt.Errorf("album ID must be peer_id of a conversation: %w", err)
+	}
+	resp, err := m.vkAPI.MessagesGetConversationsByIDExtended(api.Params{"peer_ids": peerID})
+	if err != nil {
+		return nil, makeError(err, "GetConversation failed")
+	}
+	if len(resp.Items) == 0 {
+		return nil, errors.New("no such a conversation")
+	}
+	// titles of conversations are folders, peer_id replaces titles which aren't safe
+	title := sources.FolderName(conversationTitle(resp.Items[0], resp.ExtendedResponse), albumID)
+	mf := &messagesFetcher{vkAPI: m.vkAPI, peerID: peerID, albumName: sources.AlbumPath(messagesFolder, title)}
+	if err := mf.fetch(); err != nil {
+		return nil, err
+	}
+	return mf, nil
+}
+
+// messagesFetcher requests pages of attachments on demand, pages are linked by next_from
+type messagesFetcher struct {
+	vkAPI     *api.VK
+	peerID    int
+	albumName string
+	items     []object.MessagesHistoryAttachment
+	nextFrom  string
+	done      bool
+	cur       object.MessagesHistoryAttachment
+}
+
+func (mf *messagesFetcher) fetch() error {
+	params := api.Params{"peer_id": mf.peerID, "media_type": "photo", "count": maxAttachmentsCount, "photo_sizes": 1}
+	if mf.nextFrom != "" {
+		params["start_from"] = mf.nextFrom
+	}
+	resp, err := mf.vkAPI.MessagesGetHistoryAttachments(params)
+	if err != nil {
+		return makeError(err, "GetHistoryAttachments failed")
+	}
+	mf.items = resp.Items
+	mf.nextFrom = resp.NextFrom
+	mf.done = resp.NextFrom == "" || len(resp.Items) == 0
+	return nil
+}
+
+func (mf *messagesFetcher) Next() bool {
+	for len(mf.items) == 0 {
+		if mf.done {
+			return false
+		}
+		if err := mf.fetch(); err != nil {
+			log.Println("vk: attachments", err)
+			return false
+		}
+	}
+	mf.cur = mf.items[0]
+	mf.items = mf.items[1:]
+	return true
+}
+
+func (mf *messagesFetcher) Item() sources.Photo {
+	// comments of photos in messages are unavailable
+	return newPhotoItem(fullPhoto(mf.cur.Attachment.Photo), mf.albumName, nil)
+}
+
+// fullPhoto converts a photo of an attachment
+func fullPhoto(photo object.PhotosPhoto) object.PhotosPhotoFull {
+	return object.PhotosPhotoFull{
+		AccessKey: photo.AccessKey,
+		AlbumID:   photo.AlbumID,
+		Date:      photo.Date,
+		Height:    photo.Height,
+		ID:        photo.ID,
+		Images:    photo.Images,
+		Lat:       photo.Lat,
+		Long:      photo.Long,
+		OwnerID:   photo.OwnerID,
+		PostID:    photo.PostID,
+		Text:      photo.Text,
+		UserID:    photo.UserID,
+		Width:     photo.Width,
+		Sizes:     photo.Sizes,
+	}
+}
+
+type messagesService struct{}
+
+func (s *messagesService) Kind() sources.Kind {
+	return sources.KindSource
+}
+
+func (s *messagesService) Key() string {
+	return "vk_messages"
+}
+
+func (s *messagesService) Constructor() func(creds string) sources.Source {
+	return NewMessages
+}
+
+// NewMessagesService registers photos of vk conversations as a separate source
+func NewMessagesService() sources.ServiceSource {
+	return &messagesService{}
+}
@@ -0,0 +1,82 @@
+package vk
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/SevereCloud/vksdk/v2/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func fakeMessages(t *testing.T, methods map[string]func(form url.Values) interface{}) *Messages {
+	return &Messages{vkAPI: fakeVK(t, methods).vkAPI}
+}
+
+var conversations = []map[string]interface{}{
+	{"conversation": map[string]interface{}{"peer": map[string]interface{}{"id": 2000000001, "type": "chat"}, "chat_settings": map[string]interface{}{"title": "Trip 2022"}}, "last_message": map[string]interface{}{"date": 1650000000}},
+	{"conversation": map[string]interface{}{"peer": map[string]interface{}{"id": 1, "type": "user"}}, "last_message": map[string]interface{}{"date": 1650000000}},
+	{"conversation": map[string]interface{}{"peer": map[string]interface{}{"id": -42, "type": "group"}}, "last_message": map[string]interface{}{"date": 1650000000}},
+	{"conversation": map[string]interface{}{"peer": map[string]interface{}{"id": 5, "type": "user"}}, "last_message": map[string]interface{}{"date": 1650000000}},
+}
+
+func TestMessages_AllAlbums(t *testing.T) {
+	m := fakeMessages(t, map[string]func(url.Values) interface{}{
+		"messages.getConversations": func(form url.Values) interface{} {
+			assert.Equal(t, "1", form.Get("extended"))
+			return map[string]interface{}{
+				"count":    len(conversations),
+				"items":    conversations,
+				"profiles": []map[string]interface{}{{"id": 1, "first_name": "Pavel", "last_name": "Durov"}},
+				"groups":   []map[string]interface{}{{"id": 42, "name": "Band"}},
+			}
+		},
+	})
+	albums, err := m.AllAlbums()
+	assert.NoError(t, err)
+	type album struct{ id, title string }
+	got := []album{}
+	for _, a := range albums {
+		got = append(got, album{a["id"], a["title"]})
+	}
+	assert.Equal(t, []album{{"2000000001", "Trip 2022"}, {"1", "Pavel Durov"}, {"-42", "Band"}, {"5", "peer5"}}, got)
+}
+
+func TestMessages_AlbumPhotos(t *testing.T) {
+	startFrom := []string{}
+	m := fakeMessages(t, map[string]func(url.Values) interface{}{
+		"messages.getConversationsById": func(form url.Values) interface{} {
+			assert.Equal(t, "2000000001", form.Get("peer_ids"))
+			return map[string]interface{}{"count": 1, "items": []interface{}{conversations[0]["conversation"]}}
+		},
+		"messages.getHistoryAttachments": func(form url.Values) interface{} {
+			assert.Equal(t, "photo", form.Get("media_type"))
+			startFrom = append(startFrom, form.Get("start_from"))
+			if form.Get("start_from") == "" {
+				return map[string]interface{}{"next_from": "2/1", "items": []map[string]interface{}{
+					{"message_id": 10, "attachment": map[string]interface{}{"type": "photo", "photo": photo(1, "https://vk.example.com/1.jpg")}},
+				}}
+			}
+			return map[string]interface{}{"next_from": "", "items": []map[string]interface{}{
+				{"message_id": 11, "attachment": map[string]interface{}{"type": "photo", "photo": photo(2, "https://vk.example.com/2.jpg")}},
+			}}
+		},
+	})
+	cur, err := m.AlbumPhotos("2000000001")
+	assert.NoError(t, err)
+	urls := []string{}
+	for cur.Next() {
+		assert.Equal(t, "messages/Trip 2022", cur.Item().AlbumName())
+		urls = append(urls, cur.Item().Url())
+	}
+	assert.Equal(t, []string{"https://vk.example.com/1.jpg", "https://vk.example.com/2.jpg"}, urls)
+	assert.Equal(t, []string{"", "2/1"}, startFrom)
+
+	_, err = m.AlbumPhotos("chat")
+	assert.Error(t, err)
+}
+
+func TestNewMessages(t *testing.T) {
+	m := NewMessagesService().Constructor()("token").(*Messages)
+	assert.IsType(t, &api.VK{}, m.vkAPI)
+	assert.Equal(t, "vk_messages", NewMessagesService().Key())
+}
@@ -0,0 +1,65 @@
+package vk
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/Gasoid/photoDumper/sources"
+	"github.com/SevereCloud/vksdk/v2/api"
+)
+
+// SetOwner switches albums to another user or a community (negative ID), empty owner is the token owner
+func (v *Vk) SetOwner(owner string) error {
+	if owner == "" {
+		v.ownerID = 0
+		return nil
+	}
+	id, err := strconv.Atoi(owner)
+	if err != nil || id == 0 {
+		return fmt.Errorf("owner_id must be ID of a user or negative ID of a community, got %q", owner)
+	}
+	v.ownerID = id
+	v.ownerFolder = ""
+	return nil
+}
+
+// params adds owner_id to params of a request if the owner is set
+func (v *Vk) params(params api.Params) api.Params {
+	if v.ownerID != 0 {
+		params["owner_id"] = v.ownerID
+	}
+	return params
+}
+
+// albumFolder returns the folder of an album, albums of other owners are stored in a folder named by the owner,
+// both are safe folder names
+func (v *Vk) albumFolder(title string) string {
+	if v.ownerID == 0 {
+		return sources.AlbumPath(title)
+	}
+	if v.ownerFolder == "" {
+		v.ownerFolder = v.ownerName()
+	}
+	return sources.AlbumPath(v.ownerFolder, title)
+}
+
+// ownerName returns screen name of the owner, e.g. durov or club1, ID is used if it's unavailable
+func (v *Vk) ownerName() string {
+	if v.ownerID < 0 {
+		name := fmt.Sprintf("club%d", -v.ownerID)
+		groups, err := v.vkAPI.GroupsGetByID(api.Params{"group_id": -v.ownerID})
+		if err != nil || len(groups) == 0 || groups[0].ScreenName == "" {
+			log.Println("vk: owner name", err)
+			return name
+		}
+		return groups[0].ScreenName
+	}
+	name := fmt.Sprintf("id%d", v.ownerID)
+	users, err := v.vkAPI.UsersGet(api.Params{"user_ids": v.ownerID, "fields": "screen_name"})
+	if err != nil || len(users) == 0 || users[0].ScreenName == "" {
+		log.Println("vk: owner name", err)
+		return name
+	}
+	return users[0].ScreenName
+}
@@ -0,0 +1,77 @@
+package vk
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/Gasoid/photoDumper/sources"
+	"github.com/SevereCloud/vksdk/v2/api"
+)
+
+// taggedAlbumID is "photos with me", they are listed by photos.getUserPhotos
+const taggedAlbumID = "tagged"
+
+// systemAlbums are albums with negative IDs, photos.get accepts their names instead of IDs
+var systemAlbums = map[int]string{
+	-6:  "profile",
+	-7:  "wall",
+	-15: "saved",
+}
+
+// albumParam returns album_id of photos.get
+func albumParam(albumID string) string {
+	id, err := strconv.Atoi(albumID)
+	if err != nil {
+		return albumID
+	}
+	if name, ok := systemAlbums[id]; ok {
+		return name
+	}
+	return albumID
+}
+
+// taggedAlbum returns photos where the user is tagged, communities have no such photos
+func (v *Vk) taggedAlbum() (map[string]string, error) {
+	if v.ownerID < 0 {
+		return nil, nil
+	}
+	resp, err := v.vkAPI.PhotosGetUserPhotosExtended(v.userParams(api.Params{"count": 1, "sort": 0}))
+	if err != nil {
+		return nil, makeError(err, "GetUserPhotos failed")
+	}
+	if resp.Count == 0 {
+		return nil, nil
+	}
+	album := map[string]string{
+		"title": "Photos with me",
+		"id":    taggedAlbumID,
+		"size":  fmt.Sprint(resp.Count),
+		"kind":  string(sources.MediaImage),
+	}
+	if len(resp.Items) > 0 {
+		album["thumb"] = resp.Items[0].MaxSize().URL
+		album["created"] = time.Unix(int64(resp.Items[0].Date), 0).UTC().Format(time.RFC3339)
+	}
+	return album, nil
+}
+
+// userParams adds user_id to params if the owner is set, photos.getUserPhotos doesn't accept owner_id
+func (v *Vk) userParams(params api.Params) api.Params {
+	if v.ownerID > 0 {
+		params["user_id"] = v.ownerID
+	}
+	return params
+}
+
+// taggedPhotos returns a fetcher of photos where the user is tagged
+func (v *Vk) taggedPhotos() (sources.ItemFetcher, error) {
+	page := func(offset int) (api.PhotosGetExtendedResponse, error) {
+		resp, err := v.vkAPI.PhotosGetUserPhotosExtended(v.userParams(api.Params{"count": maxCount, "offset": offset, "sort": 0}))
+		if err != nil {
+			return api.PhotosGetExtendedResponse{}, makeError(err, "DownloadAlbum failed")
+		}
+		return api.PhotosGetExtendedResponse(resp), nil
+	}
+	return newPhotoFetcher(v.vkAPI, page, v.albumFolder("Photos with me"))
+}
@@ -0,0 +1,194 @@
+package vk
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/Gasoid/photoDumper/sources"
+	"github.com/SevereCloud/vksdk/v2/api"
+	"github.com/SevereCloud/vksdk/v2/object"
+)
+
+const (
+	// video albums are listed next to photo albums, their IDs have this prefix
+	videoAlbumPrefix = "videos_"
+	maxVideoCount    = 200
+)
+
+// VideoItem is a video of a vk video album
+type VideoItem struct {
+	url       string
+	thumbnail string
+	created   time.Time
+	albumName string
+	video     object.VideoVideo
+}
+
+func (v *VideoItem) Url() string {
+	return v.url
+}
+
+func (v *VideoItem) AlbumName() string {
+	return v.albumName
+}
+
+func (v *VideoItem) Kind() sources.MediaKind {
+	return sources.MediaVideo
+}
+
+// Filename is used since urls of vk videos have no meaningful basename
+func (v *VideoItem) Filename() string {
+	return fmt.Sprintf("video%d_%d", v.video.OwnerID, v.video.ID)
+}
+
+func (v *VideoItem) ThumbnailUrl() string {
+	return v.thumbnail
+}
+
+// Metadata returns the video object as it is received from vk api
+func (v *VideoItem) Metadata() interface{} {
+	return v.video
+}
+
+func (v *VideoItem) ExifInfo() (sources.ExifInfo, error) {
+	description := fmt.Sprintf("Dumped by photoDumper. Source is vk. Album name: %s", v.albumName)
+	if v.video.Title != "" {
+		description += ". Title: " + v.video.Title
+	}
+	return &exifInfo{description: description, created: v.created}, nil
+}
+
+// videoUrl returns the best quality mp4 file, external videos (e.g. youtube) can't be downloaded
+func videoUrl(video object.VideoVideo) string {
+	files := video.Files
+	for _, url := range []string{files.Mp4_2160, files.Mp4_1440, files.Mp4_1080, files.Mp4_720, files.Mp4_480, files.Mp4_360, files.Mp4_240} {
+		if url != "" {
+			return url
+		}
+	}
+	return ""
+}
+
+// videoThumbnail returns the widest preview image of the video
+func videoThumbnail(video object.VideoVideo) string {
+	var url string
+	var width float64
+	for _, image := range video.Image {
+		if image.Width > width {
+			url, width = image.URL, image.Width
+		}
+	}
+	if url != "" {
+		return url
+	}
+	for _, url := range []string{video.Photo1280, video.Photo800, video.Photo640, video.Photo320, video.Photo130} {
+		if url != "" {
+			return url
+		}
+	}
+	return ""
+}
+
+// videoAlbums lists video albums including system ones (uploaded, added)
+func (v *Vk) videoAlbums() ([]map[string]string, error) {
+	resp, err := v.vkAPI.VideoGetAlbumsExtended(v.params(api.Params{"need_system": 1, "count": 100}))
+	if err != nil {
+		return nil, makeError(err, "GetVideoAlbums failed")
+	}
+	albums := make([]map[string]string, 0, len(resp.Items))
+	for _, album := range resp.Items {
+		var thumb string
+		for _, image := range album.Image {
+			thumb = image.URL
+		}
+		albums = append(albums, map[string]string{
+			"thumb":   thumb,
+			"title":   album.Title,
+			"id":      videoAlbumPrefix + fmt.Sprint(album.ID),
+			"created": time.Unix(int64(album.UpdatedTime), 0).UTC().Format(time.RFC3339),
+			"size":    fmt.Sprint(album.Count),
+			"kind":    string(sources.MediaVideo),
+		})
+	}
+	return albums, nil
+}
+
+// albumVideos returns a fetcher of videos of the album, albumID has videoAlbumPrefix
+func (v *Vk) albumVideos(albumID string) (sources.ItemFetcher, error) {
+	id := strings.TrimPrefix(albumID, videoAlbumPrefix)
+	album, err := v.vkAPI.VideoGetAlbumByID(v.params(api.Params{"album_id": id}))
+	if err != nil {
+		return nil, makeError(err, "GetVideoAlbum failed")
+	}
+	title := album.Title
+	if title == "" {
+		title = albumID
+	}
+	return &videoFetcher{
+		vkAPI:     v.vkAPI,
+		params:    v.params(api.Params{"album_id": id, "count": maxVideoCount}),
+		albumName: v.albumFolder(title),
+	}, nil
+}
+
+// videoFetcher requests pages of videos on demand
+type videoFetcher struct {
+	vkAPI     *api.VK
+	params    api.Params
+	albumName string
+	items     []object.VideoVideo
+	offset    int
+	count     int
+	fetched   bool
+	cur       object.VideoVideo
+}
+
+func (vf *videoFetcher) fetch() bool {
+	if vf.fetched && vf.offset >= vf.count {
+		return false
+	}
+	params := api.Params{"offset": vf.offset}
+	for k, v := range vf.params {
+		params[k] = v
+	}
+	resp, err := vf.vkAPI.VideoGet(params)
+	if err != nil {
+		log.Println("vk: VideoGet", err)
+		return false
+	}
+	vf.fetched = true
+	vf.count = resp.Count
+	vf.offset += len(resp.Items)
+	vf.items = resp.Items
+	return len(resp.Items) > 0
+}
+
+func (vf *videoFetcher) Next() bool {
+	for {
+		if len(vf.items) == 0 && !vf.fetch() {
+			return false
+		}
+		vf.cur = vf.items[0]
+		vf.items = vf.items[1:]
+		if videoUrl(vf.cur) != "" {
+			return true
+		}
+		log.Printf("vk: video%d_%d has no downloadable file", vf.cur.OwnerID, vf.cur.ID)
+	}
+}
+
+func (vf *videoFetcher) Item() sources.Photo {
+	var created time.Time
+	if vf.cur.Date > 0 {
+		created = time.Unix(int64(vf.cur.Date), 0).UTC()
+	}
+	return &VideoItem{
+		url:       videoUrl(vf.cur),
+		thumbnail: videoThumbnail(vf.cur),
+		created:   created,
+		albumName: vf.albumName,
+		video:     vf.cur,
+	}
+}
@@ -18,16 +18,21 @@ const (
 
 type Vk struct {
 	vkAPI *api.VK
+	// ownerID is a user or a negative ID of a community, zero is the token owner
+	ownerID     int
+	ownerFolder string
 }
 
-// PhotoItem is a struct that contains a directory, a URL, a creation time, an album name, and a
-// longitude and latitude.
+// PhotoItem is a struct that contains a URL, a creation time, an album name and a location,
+// gps is nil if the photo has no location.
 type PhotoItem struct {
 	url       string
 	created   time.Time
 	albumName string
-	longitude,
-	latitude float64
+	gps       *sources.GPS
+	photo     object.PhotosPhotoFull
+	// vkAPI requests comments of the photo
+	vkAPI *api.VK
 }
 
 func (f *PhotoItem) Url() string {
@@ -38,12 +43,21 @@ func (f *PhotoItem) AlbumName() string {
 	return f.albumName
 }
 
+func (f *PhotoItem) Kind() sources.MediaKind {
+	return sources.MediaImage
+}
+
+// Metadata returns the photo object as it is received from vk api
+func (f *PhotoItem) Metadata() interface{} {
+	return f.photo
+}
+
 // It's setting EXIF data for the downloaded file.
 func (f *PhotoItem) ExifInfo() (sources.ExifInfo, error) {
 	exif := &exifInfo{
 		description: fmt.Sprintf("Dumped by photoDumper. Source is vk. Album name: %s", f.albumName),
 		created:     f.created,
-		gps:         []float64{f.latitude, f.longitude},
+		gps:         f.gps,
 	}
 	return exif, nil
 }
@@ -51,7 +65,7 @@ func (f *PhotoItem) ExifInfo() (sources.ExifInfo, error) {
 type exifInfo struct {
 	description string
 	created     time.Time
-	gps         []float64
+	gps         *sources.GPS
 }
 
 func (e *exifInfo) Description() string {
@@ -62,7 +76,7 @@ func (e *exifInfo) Created() time.Time {
 	return e.created
 }
 
-func (e *exifInfo) GPS() []float64 {
+func (e *exifInfo) GPS() *sources.GPS {
 	return e.gps
 }
 
@@ -71,78 +85,134 @@ func New(creds string) sources.Source {
 	return &Vk{vkAPI: api.NewVK(creds)}
 }
 
-// Getting albums from vk api
+// Getting albums from vk api, system albums (wall, profile, saved) and photos with the user are included
 func (v *Vk) AllAlbums() ([]map[string]string, error) {
-	resp, err := v.vkAPI.PhotosGetAlbums(api.Params{"need_covers": 1})
+	resp, err := v.vkAPI.PhotosGetAlbums(v.params(api.Params{"need_covers": 1, "need_system": 1}))
 	if err != nil {
 		return nil, makeError(err, "GetAlbums failed")
 	}
-	albums := make([]map[string]string, resp.Count)
-	for i, album := range resp.Items {
-		if album.ID < 0 {
+	albums := make([]map[string]string, 0, len(resp.Items))
+	for _, album := range resp.Items {
+		if _, ok := systemAlbums[album.ID]; album.ID < 0 && !ok {
 			continue
 		}
-		created := time.Unix(int64(album.Created), 0)
-		albums[i] = map[string]string{
+		created := time.Unix(int64(album.Created), 0).UTC()
+		albums = append(albums, map[string]string{
 			"thumb":   album.ThumbSrc,
 			"title":   album.Title,
 			"id":      fmt.Sprint(album.ID),
 			"created": created.Format(time.RFC3339),
 			"size":    fmt.Sprint(album.Size),
+			"kind":    string(sources.MediaImage),
 			// "count": album.,
-		}
+		})
+	}
+	tagged, err := v.taggedAlbum()
+	if err != nil {
+		log.Println("AllAlbums:", err)
+	} else if tagged != nil {
+		albums = append(albums, tagged)
+	}
+	videoAlbums, err := v.videoAlbums()
+	if err != nil {
+		// token may have no access to videos, photos are still available
+		log.Println("AllAlbums:", err)
+		return albums, nil
 	}
-	return albums, nil
+	return append(albums, videoAlbums...), nil
 }
 
+// photosPage requests maxCount photos starting at offset
+type photosPage func(offset int) (api.PhotosGetExtendedResponse, error)
+
+// photoFetcher requests pages of photos on demand, so downloading starts after the first page
 type photoFetcher struct {
-	nextPhoto int
-	items     []object.PhotosPhoto
-	cur       int
+	vkAPI     *api.VK
+	page      photosPage
 	albumName string
+	items     []object.PhotosPhotoFull
+	offset    int
+	count     int
+	cur       object.PhotosPhotoFull
+	err       error
+}
+
+// newPhotoFetcher requests the first page, so access errors are returned before downloading starts
+func newPhotoFetcher(vkAPI *api.VK, page photosPage, albumName string) (*photoFetcher, error) {
+	pf := &photoFetcher{vkAPI: vkAPI, page: page, albumName: albumName}
+	if err := pf.fetch(); err != nil {
+		return nil, err
+	}
+	return pf, nil
+}
+
+func (pf *photoFetcher) fetch() error {
+	resp, err := pf.page(pf.offset)
+	if err != nil {
+		return err
+	}
+	pf.count = resp.Count
+	pf.offset += len(resp.Items)
+	pf.items = resp.Items
+	return nil
 }
 
 func (pf *photoFetcher) Next() bool {
-	pf.cur = pf.nextPhoto
-	if pf.cur == len(pf.items) {
-		return false
+	if len(pf.items) == 0 {
+		if pf.offset >= pf.count || pf.err != nil {
+			return false
+		}
+		if pf.err = pf.fetch(); pf.err != nil {
+			log.Println("vk: photos", pf.err)
+			return false
+		}
+		if len(pf.items) == 0 {
+			return false
+		}
 	}
-	pf.nextPhoto++
+	pf.cur = pf.items[0]
+	pf.items = pf.items[1:]
 	return true
 }
 
 // Downloading photos from a VK album.
 func (v *Vk) AlbumPhotos(albumID string) (sources.ItemFetcher, error) {
+	if strings.HasPrefix(albumID, videoAlbumPrefix) {
+		return v.albumVideos(albumID)
+	}
+	if albumID == taggedAlbumID {
+		return v.taggedPhotos()
+	}
 	params := api.Params{"album_ids": albumID}
 	if strings.Contains(albumID, "-") {
 		params["need_system"] = 1
 	}
-	albumResp, err := v.vkAPI.PhotosGetAlbums(params)
+	albumResp, err := v.vkAPI.PhotosGetAlbums(v.params(params))
 	if err != nil {
 		return nil, makeError(err, "DownloadAlbum failed")
 	}
-	var resp api.PhotosGetResponse
-	items := make([]object.PhotosPhoto, 0, albumResp.Count)
-	for offset := 1; offset <= albumResp.Count; offset += maxCount {
-		resp, err = v.vkAPI.PhotosGet(api.Params{"album_id": albumID, "count": maxCount, "photo_sizes": 1, "offset": offset})
-		if err != nil {
-			log.Println("DownloadAlbum:", err)
-			return nil, makeError(err, "DownloadAlbum failed")
-		}
-		items = append(items, resp.Items...)
-	}
-	if albumResp.Count < 1 {
+	if albumResp.Count < 1 || len(albumResp.Items) < 1 {
 		return nil, errors.New("no such an album")
 	}
 	if albumResp.Items[0].Title == "" {
 		return nil, errors.New("album title is empty")
 	}
-
-	return &photoFetcher{items: items, albumName: albumResp.Items[0].Title}, nil
+	page := func(offset int) (api.PhotosGetExtendedResponse, error) {
+		resp, err := v.vkAPI.PhotosGetExtended(v.params(api.Params{"album_id": albumParam(albumID), "count": maxCount, "photo_sizes": 1, "offset": offset}))
+		if err != nil {
+			return resp, makeError(err, "DownloadAlbum failed")
+		}
+		return resp, nil
+	}
+	return newPhotoFetcher(v.vkAPI, page, v.albumFolder(albumResp.Items[0].Title))
 }
 
 func (pf *photoFetcher) Item() sources.Photo {
-	photo := pf.items[pf.cur]
+	return newPhotoItem(pf.cur, pf.albumName, pf.vkAPI)
+}
+
+// newPhotoItem picks the biggest size of the photo, vkAPI is used to request comments
+func newPhotoItem(photo object.PhotosPhotoFull, albumName string, vkAPI *api.VK) *PhotoItem {
 	var url string
 	if photo.MaxSize().URL == "" {
 		for _, s := range photo.Sizes {
@@ -154,13 +224,21 @@ func (pf *photoFetcher) Item() sources.Photo {
 		url = photo.MaxSize().URL
 	}
 
-	created := time.Unix(int64(photo.Date), 0)
+	var created time.Time
+	if photo.Date > 0 {
+		created = time.Unix(int64(photo.Date), 0).UTC()
+	}
+	gps, err := sources.NewGPS(photo.Lat, photo.Long)
+	if err != nil {
+		log.Println("photo", photo.ID, err)
+	}
 	return &PhotoItem{
 		url:       url,
 		created:   created,
-		albumName: pf.albumName,
-		latitude:  photo.Lat,
-		longitude: photo.Long,
+		albumName: albumName,
+		gps:       gps,
+		photo:     photo,
+		vkAPI:     vkAPI,
 	}
 }
 
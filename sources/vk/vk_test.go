@@ -0,0 +1,286 @@
+package vk
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/Gasoid/photoDumper/sources"
+	"github.com/SevereCloud/vksdk/v2/api"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeVK serves responses of api methods, handlers get form values of the request
+func fakeVK(t *testing.T, methods map[string]func(form url.Values) interface{}) *Vk {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		w.Header().Set("Content-Type", "application/json")
+		handler, ok := methods[strings.TrimPrefix(r.URL.Path, "/")]
+		if !ok {
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error": map[string]interface{}{"error_code": 15, "error_msg": "Access denied"},
+			})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"response": handler(r.Form)})
+	}))
+	t.Cleanup(server.Close)
+	vkAPI := api.NewVK("token")
+	vkAPI.MethodURL = server.URL + "/"
+	vkAPI.Limit = 0
+	return &Vk{vkAPI: vkAPI}
+}
+
+func photo(id int, url string) map[string]interface{} {
+	return map[string]interface{}{
+		"id": id, "album_id": 1, "owner_id": -42, "date": 1650000000,
+		"sizes": []map[string]interface{}{{"type": "z", "url": url, "width": 1080, "height": 720}},
+	}
+}
+
+func TestVk_SetOwner(t *testing.T) {
+	v := &Vk{}
+	assert.NoError(t, v.SetOwner("-42"))
+	assert.Equal(t, -42, v.ownerID)
+	assert.NoError(t, v.SetOwner(""))
+	assert.Equal(t, 0, v.ownerID)
+	assert.Error(t, v.SetOwner("durov"))
+	assert.Error(t, v.SetOwner("0"))
+}
+
+func TestVk_AllAlbumsOwner(t *testing.T) {
+	var ownerID string
+	v := fakeVK(t, map[string]func(url.Values) interface{}{
+		"photos.getAlbums": func(form url.Values) interface{} {
+			ownerID = form.Get("owner_id")
+			return map[string]interface{}{"count": 1, "items": []map[string]interface{}{
+				{"id": 1, "owner_id": -42, "title": "Concert", "size": 2, "created": 1650000000},
+			}}
+		},
+	})
+	assert.NoError(t, v.SetOwner("-42"))
+	albums, err := v.AllAlbums()
+	assert.NoError(t, err)
+	assert.Equal(t, "-42", ownerID)
+	assert.Equal(t, "Concert", albums[0]["title"])
+}
+
+func TestVk_AlbumPhotosOwnerFolder(t *testing.T) {
+	tests := []struct {
+		name   string
+		owner  string
+		title  string
+		folder string
+	}{
+		{name: "token owner", owner: "", folder: "Concert"},
+		{name: "community", owner: "-42", folder: "band/Concert"},
+		{name: "user", owner: "1", folder: "durov/Concert"},
+		{name: "unknown user", owner: "2", folder: "id2/Concert"},
+		{name: "nested title", owner: "", title: "Tour/Berlin", folder: "Tour_Berlin"},
+		{name: "title out of the folder", owner: "-42", title: "../../.ssh", folder: "band/.._.._.ssh"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.title == "" {
+				tt.title = "Concert"
+			}
+			owners := []string{}
+			v := fakeVK(t, map[string]func(url.Values) interface{}{
+				"photos.getAlbums": func(form url.Values) interface{} {
+					owners = append(owners, form.Get("owner_id"))
+					return map[string]interface{}{"count": 1, "items": []map[string]interface{}{{"id": 1, "title": tt.title, "size": 1}}}
+				},
+				"photos.get": func(form url.Values) interface{} {
+					owners = append(owners, form.Get("owner_id"))
+					return map[string]interface{}{"count": 1, "items": []interface{}{photo(1, "https://vk.example.com/1.jpg")}}
+				},
+				"groups.getById": func(form url.Values) interface{} {
+					return []map[string]interface{}{{"id": 42, "name": "Band", "screen_name": "band"}}
+				},
+				"users.get": func(form url.Values) interface{} {
+					if form.Get("user_ids") != "1" {
+						return []interface{}{}
+					}
+					return []map[string]interface{}{{"id": 1, "first_name": "Pavel", "screen_name": "durov"}}
+				},
+			})
+			assert.NoError(t, v.SetOwner(tt.owner))
+			cur, err := v.AlbumPhotos("1")
+			assert.NoError(t, err)
+			assert.True(t, cur.Next())
+			assert.Equal(t, tt.folder, cur.Item().AlbumName())
+			for _, owner := range owners {
+				assert.Equal(t, tt.owner, owner)
+			}
+		})
+	}
+}
+
+func TestVk_AllAlbumsSystem(t *testing.T) {
+	var needSystem string
+	v := fakeVK(t, map[string]func(url.Values) interface{}{
+		"photos.getAlbums": func(form url.Values) interface{} {
+			needSystem = form.Get("need_system")
+			return map[string]interface{}{"count": 4, "items": []map[string]interface{}{
+				{"id": -6, "title": "Profile photos", "size": 3},
+				{"id": -7, "title": "Wall photos", "size": 5},
+				{"id": -9000, "title": "Photos with me", "size": 1},
+				{"id": 1, "title": "Concert", "size": 2},
+			}}
+		},
+		"photos.getUserPhotos": func(form url.Values) interface{} {
+			return map[string]interface{}{"count": 7, "items": []interface{}{photo(1, "https://vk.example.com/1.jpg")}}
+		},
+	})
+	albums, err := v.AllAlbums()
+	assert.NoError(t, err)
+	assert.Equal(t, "1", needSystem)
+	ids := []string{}
+	for _, album := range albums {
+		ids = append(ids, album["id"])
+	}
+	assert.Equal(t, []string{"-6", "-7", "1", "tagged"}, ids)
+	assert.Equal(t, "7", albums[3]["size"])
+	assert.Equal(t, "https://vk.example.com/1.jpg", albums[3]["thumb"])
+}
+
+func TestVk_AlbumPhotosSystem(t *testing.T) {
+	var albumID string
+	v := fakeVK(t, map[string]func(url.Values) interface{}{
+		"photos.getAlbums": func(form url.Values) interface{} {
+			return map[string]interface{}{"count": 1, "items": []map[string]interface{}{{"id": -7, "title": "Wall photos", "size": 1}}}
+		},
+		"photos.get": func(form url.Values) interface{} {
+			albumID = form.Get("album_id")
+			return map[string]interface{}{"count": 1, "items": []interface{}{photo(1, "https://vk.example.com/1.jpg")}}
+		},
+	})
+	cur, err := v.AlbumPhotos("-7")
+	assert.NoError(t, err)
+	assert.Equal(t, "wall", albumID)
+	assert.True(t, cur.Next())
+	assert.Equal(t, "Wall photos", cur.Item().AlbumName())
+}
+
+func TestVk_AlbumPhotosTagged(t *testing.T) {
+	offsets := []string{}
+	v := fakeVK(t, map[string]func(url.Values) interface{}{
+		"photos.getUserPhotos": func(form url.Values) interface{} {
+			offsets = append(offsets, form.Get("offset"))
+			if form.Get("offset") != "0" {
+				return map[string]interface{}{"count": 1001, "items": []interface{}{photo(1001, "https://vk.example.com/1001.jpg")}}
+			}
+			items := []interface{}{}
+			for i := 0; i < maxCount; i++ {
+				items = append(items, photo(i, "https://vk.example.com/1.jpg"))
+			}
+			return map[string]interface{}{"count": 1001, "items": items}
+		},
+	})
+	cur, err := v.AlbumPhotos("tagged")
+	assert.NoError(t, err)
+	count := 0
+	for cur.Next() {
+		assert.Equal(t, "Photos with me", cur.Item().AlbumName())
+		count++
+	}
+	assert.Equal(t, 1001, count)
+	assert.Equal(t, []string{"0", "1000"}, offsets)
+}
+
+func TestVk_AlbumPhotosPaging(t *testing.T) {
+	offsets := []string{}
+	v := fakeVK(t, map[string]func(url.Values) interface{}{
+		"photos.getAlbums": func(form url.Values) interface{} {
+			return map[string]interface{}{"count": 1, "items": []map[string]interface{}{{"id": 1, "title": "Concert", "size": 1001}}}
+		},
+		"photos.get": func(form url.Values) interface{} {
+			offsets = append(offsets, form.Get("offset"))
+			assert.Equal(t, "1000", form.Get("count"))
+			if form.Get("offset") == "1000" {
+				return map[string]interface{}{"count": 1001, "items": []interface{}{photo(1000, "https://vk.example.com/1000.jpg")}}
+			}
+			items := []interface{}{}
+			for i := 0; i < maxCount; i++ {
+				items = append(items, photo(i, fmt.Sprintf("https://vk.example.com/%d.jpg", i)))
+			}
+			return map[string]interface{}{"count": 1001, "items": items}
+		},
+	})
+	cur, err := v.AlbumPhotos("1")
+	assert.NoError(t, err)
+	// the next page is requested when the first one is downloaded
+	assert.Equal(t, []string{"0"}, offsets)
+	urls := []string{}
+	for cur.Next() {
+		urls = append(urls, cur.Item().Url())
+	}
+	assert.Equal(t, []string{"0", "1000"}, offsets)
+	assert.Len(t, urls, 1001)
+	assert.Equal(t, "https://vk.example.com/0.jpg", urls[0])
+	assert.Equal(t, "https://vk.example.com/1000.jpg", urls[1000])
+}
+
+func TestVk_AlbumPhotosAccessError(t *testing.T) {
+	v := fakeVK(t, map[string]func(url.Values) interface{}{
+		"photos.getAlbums": func(form url.Values) interface{} {
+			return map[string]interface{}{"count": 1, "items": []map[string]interface{}{{"id": 1, "title": "Concert", "size": 1}}}
+		},
+	})
+	_, err := v.AlbumPhotos("1")
+	var e *sources.AccessError
+	assert.True(t, errors.As(err, &e))
+}
+
+func TestPhotoItem_Comments(t *testing.T) {
+	offsets := []string{}
+	v := fakeVK(t, map[string]func(url.Values) interface{}{
+		"photos.getComments": func(form url.Values) interface{} {
+			assert.Equal(t, "-42", form.Get("owner_id"))
+			assert.Equal(t, "7", form.Get("photo_id"))
+			offsets = append(offsets, form.Get("offset"))
+			items := []map[string]interface{}{}
+			if form.Get("offset") == "0" {
+				for i := 0; i < maxCommentsCount; i++ {
+					items = append(items, map[string]interface{}{"id": i, "from_id": 1, "date": 1650000000, "text": "nice"})
+				}
+			} else {
+				items = append(items,
+					map[string]interface{}{"id": 100, "from_id": -42, "date": 1650000000, "text": "thanks"},
+					map[string]interface{}{"id": 101, "from_id": 5, "date": 1650000000, "text": "who?"},
+					map[string]interface{}{"id": 102, "from_id": 1, "date": 1650000000, "deleted": 1},
+				)
+			}
+			return map[string]interface{}{
+				"count":    maxCommentsCount + 3,
+				"items":    items,
+				"profiles": []map[string]interface{}{{"id": 1, "first_name": "Pavel", "last_name": "Durov"}},
+				"groups":   []map[string]interface{}{{"id": 42, "name": "Band"}},
+			}
+		},
+	})
+	item := &PhotoItem{vkAPI: v.vkAPI}
+	item.photo.ID = 7
+	item.photo.OwnerID = -42
+	item.photo.Text = "Sunset"
+	// photos without comments aren't requested
+	comments, err := item.Comments()
+	assert.NoError(t, err)
+	assert.Empty(t, offsets)
+	assert.Empty(t, comments)
+
+	item.photo.Comments.Count = maxCommentsCount + 3
+	comments, err = item.Comments()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"0", "100"}, offsets)
+	assert.Equal(t, "Sunset", item.Caption())
+	assert.Len(t, comments, maxCommentsCount+2)
+	assert.Equal(t, "Pavel Durov", comments[0].Author)
+	assert.Equal(t, "Band", comments[maxCommentsCount].Author)
+	assert.Equal(t, "id5", comments[maxCommentsCount+1].Author)
+}
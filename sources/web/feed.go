@@ -0,0 +1,180 @@
+package web
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"time"
+
+	"github.com/Gasoid/photoDumper/sources"
+	"golang.org/x/net/html"
+)
+
+const (
+	mediaNS   = "http://search.yahoo.com/mrss/"
+	contentNS = "http://purl.org/rss/1.0/modules/content/"
+	atomNS    = "http://www.w3.org/2005/Atom"
+)
+
+// dateLayouts are formats of dates of feeds, RSS uses RFC 822 with variations, Atom uses RFC 3339
+var dateLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"2 Jan 2006 15:04:05 -0700",
+	time.RFC3339,
+}
+
+func parseDate(value string) time.Time {
+	value = strings.TrimSpace(value)
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+type mediaContent struct {
+	Url    string `xml:"url,attr"`
+	Type   string `xml:"type,attr"`
+	Medium string `xml:"medium,attr"`
+}
+
+type rssItem struct {
+	Title       string         `xml:"title"`
+	Link        string         `xml:"link"`
+	PubDate     string         `xml:"pubDate"`
+	Description string         `xml:"description"`
+	Encoded     string         `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+	Enclosures  []mediaContent `xml:"enclosure"`
+	Media       []mediaContent `xml:"http://search.yahoo.com/mrss/ content"`
+	Groups      []struct {
+		Media []mediaContent `xml:"http://search.yahoo.com/mrss/ content"`
+	} `xml:"http://search.yahoo.com/mrss/ group"`
+}
+
+type rss struct {
+	Channel struct {
+		Title string    `xml:"title"`
+		Items []rssItem `xml:"item"`
+	} `xml:"channel"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type atomEntry struct {
+	Title     string         `xml:"title"`
+	Published string         `xml:"published"`
+	Updated   string         `xml:"updated"`
+	Links     []atomLink     `xml:"link"`
+	Content   string         `xml:"content"`
+	Summary   string         `xml:"summary"`
+	Media     []mediaContent `xml:"http://search.yahoo.com/mrss/ content"`
+}
+
+type atomFeed struct {
+	Title   string      `xml:"title"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+// entry is an item of a feed with its media
+type entry struct {
+	title   string
+	link    string
+	created time.Time
+	media   []mediaContent
+	html    string
+}
+
+// rootElement returns the name of the first element, it's html for html pages
+func rootElement(data []byte) xml.Name {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.Strict = false
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return xml.Name{}
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			return start.Name
+		}
+	}
+}
+
+// parseFeed returns the title and entries of an RSS or Atom feed, ok is false for other documents
+func parseFeed(data []byte) (string, []*entry, bool) {
+	root := rootElement(data)
+	switch {
+	case root.Local == "rss":
+		feed := &rss{}
+		if err := xml.Unmarshal(data, feed); err != nil {
+			return "", nil, false
+		}
+		entries := []*entry{}
+		for _, item := range feed.Channel.Items {
+			e := &entry{title: item.Title, link: item.Link, created: parseDate(item.PubDate), html: item.Encoded + item.Description}
+			e.media = append(e.media, item.Enclosures...)
+			e.media = append(e.media, item.Media...)
+			for _, group := range item.Groups {
+				e.media = append(e.media, group.Media...)
+			}
+			entries = append(entries, e)
+		}
+		return feed.Channel.Title, entries, true
+	case root.Local == "feed" && root.Space == atomNS:
+		feed := &atomFeed{}
+		if err := xml.Unmarshal(data, feed); err != nil {
+			return "", nil, false
+		}
+		entries := []*entry{}
+		for _, item := range feed.Entries {
+			published := item.Published
+			if published == "" {
+				published = item.Updated
+			}
+			e := &entry{title: item.Title, created: parseDate(published), html: item.Content + item.Summary, media: item.Media}
+			for _, link := range item.Links {
+				switch link.Rel {
+				case "enclosure":
+					e.media = append(e.media, mediaContent{Url: link.Href, Type: link.Type})
+				case "", "alternate":
+					e.link = link.Href
+				}
+			}
+			entries = append(entries, e)
+		}
+		return feed.Title, entries, true
+	}
+	return "", nil, false
+}
+
+// kind returns the kind of a media content, other media (e.g. audio of podcasts) are skipped
+func (m mediaContent) kind() (sources.MediaKind, bool) {
+	switch {
+	case m.Medium == "image" || strings.HasPrefix(m.Type, "image/"):
+		return sources.MediaImage, true
+	case m.Medium == "video" || strings.HasPrefix(m.Type, "video/"):
+		return sources.MediaVideo, true
+	case m.Medium == "" && m.Type == "":
+		return kindOfUrl(m.Url)
+	}
+	return "", false
+}
+
+// images returns sources of images of an html fragment
+func images(fragment string) []*html.Node {
+	if strings.TrimSpace(fragment) == "" {
+		return nil
+	}
+	doc, err := html.Parse(strings.NewReader(fragment))
+	if err != nil {
+		return nil
+	}
+	return imgSelector.find(doc)
+}
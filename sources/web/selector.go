@@ -0,0 +1,192 @@
+package web
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// selector is a subset of CSS selectors: type, *, #id, .class, [attr] and [attr=value]
+// joined by descendant (space) and child (>) combinators, groups are separated by commas
+type selector [][]*step
+
+type attrMatch struct {
+	name     string
+	value    string
+	hasValue bool
+}
+
+// step is a compound selector, child means that the element is a child of the previous step
+type step struct {
+	tag     string
+	id      string
+	classes []string
+	attrs   []attrMatch
+	child   bool
+}
+
+func parseSelector(s string) (selector, error) {
+	result := selector{}
+	for _, group := range strings.Split(s, ",") {
+		steps := []*step{}
+		child := false
+		for _, field := range strings.Fields(strings.ReplaceAll(group, ">", " > ")) {
+			if field == ">" {
+				if len(steps) == 0 || child {
+					return nil, fmt.Errorf("wrong selector %q", s)
+				}
+				child = true
+				continue
+			}
+			st, err := parseStep(field)
+			if err != nil {
+				return nil, err
+			}
+			st.child, child = child, false
+			steps = append(steps, st)
+		}
+		if len(steps) == 0 || child {
+			return nil, fmt.Errorf("wrong selector %q", s)
+		}
+		result = append(result, steps)
+	}
+	return result, nil
+}
+
+func isIdent(c byte) bool {
+	return c == '-' || c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// ident reads a name at the start of s
+func ident(s string) (string, string) {
+	i := 0
+	for i < len(s) && isIdent(s[i]) {
+		i++
+	}
+	return s[:i], s[i:]
+}
+
+func parseStep(s string) (*step, error) {
+	st := &step{}
+	if strings.HasPrefix(s, "*") {
+		s = s[1:]
+	} else {
+		st.tag, s = ident(s)
+		st.tag = strings.ToLower(st.tag)
+	}
+	for s != "" {
+		var name string
+		switch s[0] {
+		case '#':
+			name, s = ident(s[1:])
+			st.id = name
+		case '.':
+			name, s = ident(s[1:])
+			st.classes = append(st.classes, name)
+		case '[':
+			end := strings.IndexByte(s, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("wrong selector %q", s)
+			}
+			attr := attrMatch{}
+			attr.name, attr.value, attr.hasValue = strings.Cut(s[1:end], "=")
+			attr.name = strings.ToLower(strings.TrimSpace(attr.name))
+			attr.value = strings.Trim(strings.TrimSpace(attr.value), `"'`)
+			st.attrs = append(st.attrs, attr)
+			s, name = s[end+1:], attr.name
+		default:
+			return nil, fmt.Errorf("wrong selector %q", s)
+		}
+		if name == "" {
+			return nil, fmt.Errorf("wrong selector %q", s)
+		}
+	}
+	return st, nil
+}
+
+func attr(n *html.Node, name string) (string, bool) {
+	for _, a := range n.Attr {
+		if a.Key == name {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
+
+func (st *step) matches(n *html.Node) bool {
+	if n.Type != html.ElementNode || st.tag != "" && n.Data != st.tag {
+		return false
+	}
+	if st.id != "" {
+		if id, _ := attr(n, "id"); id != st.id {
+			return false
+		}
+	}
+	if len(st.classes) > 0 {
+		class, _ := attr(n, "class")
+		classes := strings.Fields(class)
+		for _, c := range st.classes {
+			found := false
+			for _, have := range classes {
+				found = found || have == c
+			}
+			if !found {
+				return false
+			}
+		}
+	}
+	for _, a := range st.attrs {
+		value, ok := attr(n, a.name)
+		if !ok || a.hasValue && value != a.value {
+			return false
+		}
+	}
+	return true
+}
+
+// matchSteps matches steps from the last one, which is the element itself, to its ancestors
+func matchSteps(n *html.Node, steps []*step) bool {
+	last := steps[len(steps)-1]
+	if !last.matches(n) {
+		return false
+	}
+	if len(steps) == 1 {
+		return true
+	}
+	rest := steps[:len(steps)-1]
+	if last.child {
+		return n.Parent != nil && matchSteps(n.Parent, rest)
+	}
+	for p := n.Parent; p != nil; p = p.Parent {
+		if matchSteps(p, rest) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s selector) match(n *html.Node) bool {
+	for _, steps := range s {
+		if matchSteps(n, steps) {
+			return true
+		}
+	}
+	return false
+}
+
+// find returns matching elements in document order
+func (s selector) find(root *html.Node) []*html.Node {
+	found := []*html.Node{}
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if s.match(n) {
+			found = append(found, n)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(root)
+	return found
+}
@@ -0,0 +1,57 @@
+package web
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/html"
+)
+
+const galleryPage = `<html><head><title>Trip</title></head><body>
+<div id="header"><img src="/logo.png" alt="logo"></div>
+<div class="gallery main">
+  <figure><img src="1.jpg" alt="one"><time datetime="2022-05-01T10:00:00Z">May</time></figure>
+  <figure><a href="2-full.jpg"><img src="2.jpg" alt="two"></a></figure>
+  <span><img data-kind="photo" src="3.jpg"></span>
+</div>
+</body></html>`
+
+func TestParseSelector(t *testing.T) {
+	doc, err := html.Parse(strings.NewReader(galleryPage))
+	assert.NoError(t, err)
+	tests := []struct {
+		selector string
+		want     []string
+		wantErr  bool
+	}{
+		{selector: "img", want: []string{"/logo.png", "1.jpg", "2.jpg", "3.jpg"}},
+		{selector: ".gallery img", want: []string{"1.jpg", "2.jpg", "3.jpg"}},
+		{selector: "div.gallery.main > figure > img", want: []string{"1.jpg"}},
+		{selector: "#header img, span > img", want: []string{"/logo.png", "3.jpg"}},
+		{selector: "img[alt]", want: []string{"/logo.png", "1.jpg", "2.jpg"}},
+		{selector: "img[data-kind=photo]", want: []string{"3.jpg"}},
+		{selector: `img[alt="two"]`, want: []string{"2.jpg"}},
+		{selector: "* > a > img", want: []string{"2.jpg"}},
+		{selector: ".gallery video", want: []string{}},
+		{selector: "", wantErr: true},
+		{selector: "img[alt", wantErr: true},
+		{selector: "> img", wantErr: true},
+		{selector: "img:first-child", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.selector, func(t *testing.T) {
+			s, err := parseSelector(tt.selector)
+			assert.Equal(t, tt.wantErr, err != nil)
+			if tt.wantErr {
+				return
+			}
+			got := []string{}
+			for _, n := range s.find(doc) {
+				src, _ := attr(n, "src")
+				got = append(got, src)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
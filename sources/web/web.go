@@ -0,0 +1,355 @@
+// Package web dumps images of an RSS or Atom feed or of an html page with a gallery
+package web
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Gasoid/photoDumper/sources"
+	"github.com/Gasoid/photoDumper/sources/localdir"
+	"golang.org/x/net/html"
+)
+
+const (
+	// albumID is the only album of the source, it's the feed or the page itself
+	albumID = "page"
+	// defaultSelector picks all images of a page
+	defaultSelector = "img"
+	// maxPageSize limits pages and feeds which are read into memory
+	maxPageSize = 32 << 20
+)
+
+var (
+	imgSelector, _   = parseSelector(defaultSelector)
+	timeSelector, _  = parseSelector("time[datetime]")
+	titleSelector, _ = parseSelector("head > title")
+)
+
+// kindOfUrl detects the kind by the extension of the url, urls without known extensions are images
+func kindOfUrl(rawUrl string) (sources.MediaKind, bool) {
+	u, err := url.Parse(rawUrl)
+	if err != nil || rawUrl == "" {
+		return "", false
+	}
+	if kind, ok := localdir.KindOf(u.Path); ok {
+		return kind, true
+	}
+	return sources.MediaImage, true
+}
+
+// PhotoItem is an image or a video linked by the page or the feed
+type PhotoItem struct {
+	url         string
+	albumName   string
+	kind        sources.MediaKind
+	description string
+	created     time.Time
+	link        string
+}
+
+func (f *PhotoItem) Url() string {
+	return f.url
+}
+
+func (f *PhotoItem) AlbumName() string {
+	return f.albumName
+}
+
+func (f *PhotoItem) Kind() sources.MediaKind {
+	return f.kind
+}
+
+// Metadata is the link to the post of a feed or to the page
+func (f *PhotoItem) Metadata() interface{} {
+	return map[string]string{"url": f.url, "link": f.link, "title": f.description}
+}
+
+// ExifInfo uses the title of the post or alt text of the image, dates are known for feeds only
+func (f *PhotoItem) ExifInfo() (sources.ExifInfo, error) {
+	return &exifInfo{description: strings.TrimSpace(f.description), created: f.created}, nil
+}
+
+type exifInfo struct {
+	description string
+	created     time.Time
+}
+
+func (e *exifInfo) Description() string {
+	return e.description
+}
+
+func (e *exifInfo) Created() time.Time {
+	return e.created
+}
+
+func (e *exifInfo) GPS() *sources.GPS {
+	return nil
+}
+
+// Web is a feed or a page, creds is the url, a css selector of images of a page follows #, e.g. https://example.com/gallery#.gallery img
+type Web struct {
+	pageUrl  string
+	selector string
+}
+
+func New(creds string) sources.Source {
+	pageUrl, selector, _ := strings.Cut(strings.TrimSpace(creds), "#")
+	if strings.TrimSpace(selector) == "" {
+		selector = defaultSelector
+	}
+	return &Web{pageUrl: pageUrl, selector: selector}
+}
+
+// page is the title and media of the feed or the page
+type page struct {
+	title string
+	items []*PhotoItem
+}
+
+func (w *Web) load() (*page, error) {
+	base, err := url.Parse(w.pageUrl)
+	if err != nil || base.Scheme != "http" && base.Scheme != "https" {
+		return nil, &sources.AccessError{Text: "it's not a url of a page or a feed", Err: fmt.Errorf("wrong url %q", w.pageUrl)}
+	}
+	selector, err := parseSelector(w.selector)
+	if err != nil {
+		return nil, &sources.AccessError{Text: "css selector is wrong", Err: err}
+	}
+	resp, err := http.Get(w.pageUrl)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound:
+		return nil, &sources.AccessError{Text: "page is not available", Err: fmt.Errorf("%q: %s", w.pageUrl, resp.Status)}
+	case http.StatusTooManyRequests:
+		return nil, &sources.RateLimitError{Text: "too many requests, try again later", Err: errors.New(resp.Status), RetryAfter: time.Hour}
+	default:
+		return nil, fmt.Errorf("%q is unavailable, code is %d", w.pageUrl, resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxPageSize))
+	if err != nil {
+		return nil, err
+	}
+	p := &page{}
+	if title, entries, ok := parseFeed(data); ok {
+		p.title = title
+		p.items = feedItems(base, entries)
+	} else {
+		doc, err := html.Parse(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("can't parse page: %w", err)
+		}
+		p.title = documentTitle(doc)
+		p.items = pageItems(base, selector.find(doc))
+	}
+	if strings.TrimSpace(p.title) == "" {
+		p.title = base.Host + strings.TrimSuffix(base.Path, "/")
+	}
+	// titles which are "." or ".." are replaced by the host of the page
+	albumName := sources.FolderName(p.title, strings.ReplaceAll(base.Host, ":", "_"))
+	for _, item := range p.items {
+		item.albumName = albumName
+	}
+	return p, nil
+}
+
+// resolve makes the url absolute, urls which are not http are skipped
+func resolve(base *url.URL, ref string) string {
+	if strings.TrimSpace(ref) == "" {
+		return ""
+	}
+	u, err := base.Parse(strings.TrimSpace(ref))
+	if err != nil || u.Scheme != "http" && u.Scheme != "https" {
+		return ""
+	}
+	return u.String()
+}
+
+// feedItems returns media of entries: enclosures, media:content and images of the content
+func feedItems(base *url.URL, entries []*entry) []*PhotoItem {
+	items := []*PhotoItem{}
+	seen := map[string]bool{}
+	add := func(e *entry, ref string, kind sources.MediaKind) {
+		u := resolve(base, ref)
+		if u == "" || seen[u] {
+			return
+		}
+		seen[u] = true
+		items = append(items, &PhotoItem{url: u, kind: kind, description: e.title, created: e.created, link: e.link})
+	}
+	for _, e := range entries {
+		for _, m := range e.media {
+			if kind, ok := m.kind(); ok {
+				add(e, m.Url, kind)
+			}
+		}
+		for _, img := range images(e.html) {
+			if src := imageSource(img); src != "" {
+				add(e, src, sources.MediaImage)
+			}
+		}
+	}
+	return items
+}
+
+// pageItems returns media of elements matched by the selector: images, links and images inside of other elements
+func pageItems(base *url.URL, nodes []*html.Node) []*PhotoItem {
+	items := []*PhotoItem{}
+	seen := map[string]bool{}
+	for _, n := range nodes {
+		var ref, alt string
+		switch n.Data {
+		case "a":
+			ref, _ = attr(n, "href")
+			if img := imgSelector.find(n); len(img) > 0 {
+				alt, _ = attr(img[0], "alt")
+			}
+		case "img":
+			ref = imageSource(n)
+			alt, _ = attr(n, "alt")
+		case "video", "source":
+			ref, _ = attr(n, "src")
+		default:
+			if img := imgSelector.find(n); len(img) > 0 {
+				ref = imageSource(img[0])
+				alt, _ = attr(img[0], "alt")
+			}
+		}
+		u := resolve(base, ref)
+		if u == "" || seen[u] {
+			continue
+		}
+		kind, ok := kindOfUrl(u)
+		if !ok {
+			continue
+		}
+		seen[u] = true
+		items = append(items, &PhotoItem{url: u, kind: kind, description: alt, created: pageTime(n), link: base.String()})
+	}
+	return items
+}
+
+// imageSource prefers the biggest image of srcset and sources of lazy loading
+func imageSource(img *html.Node) string {
+	if srcset, ok := attr(img, "srcset"); ok {
+		if src := largestSrc(srcset); src != "" {
+			return src
+		}
+	}
+	for _, name := range []string{"data-src", "data-original", "src"} {
+		if src, ok := attr(img, name); ok && src != "" && !strings.HasPrefix(src, "data:") {
+			return src
+		}
+	}
+	return ""
+}
+
+// largestSrc picks the candidate of srcset with the biggest width (e.g. 1024w) or density (e.g. 2x)
+func largestSrc(srcset string) string {
+	best, bestSize := "", 0.0
+	for _, candidate := range strings.Split(srcset, ",") {
+		fields := strings.Fields(candidate)
+		if len(fields) == 0 {
+			continue
+		}
+		size := 1.0
+		if len(fields) > 1 {
+			fmt.Sscanf(strings.TrimRight(fields[1], "wx"), "%g", &size)
+		}
+		if size > bestSize {
+			best, bestSize = fields[0], size
+		}
+	}
+	return best
+}
+
+// pageTime looks for a <time datetime> in the element or in its closest ancestors, e.g. a figure or an article,
+// ancestors with other images (e.g. the gallery itself) are not searched
+func pageTime(n *html.Node) time.Time {
+	for level := 0; n != nil && level < 3 && len(imgSelector.find(n)) <= 1; level, n = level+1, n.Parent {
+		for _, t := range timeSelector.find(n) {
+			value, _ := attr(t, "datetime")
+			if created := parseDate(value); !created.IsZero() {
+				return created
+			}
+			if created, err := time.Parse("2006-01-02", value); err == nil {
+				return created
+			}
+		}
+	}
+	return time.Time{}
+}
+
+func documentTitle(doc *html.Node) string {
+	for _, n := range titleSelector.find(doc) {
+		if n.FirstChild != nil {
+			return strings.TrimSpace(n.FirstChild.Data)
+		}
+	}
+	return ""
+}
+
+// AllAlbums returns the feed or the page as a single album
+func (w *Web) AllAlbums() ([]map[string]string, error) {
+	p, err := w.load()
+	if err != nil {
+		return nil, err
+	}
+	album := map[string]string{"title": p.title, "id": albumID, "size": fmt.Sprint(len(p.items))}
+	if len(p.items) > 0 && p.items[0].kind == sources.MediaImage {
+		album["thumb"] = p.items[0].url
+	}
+	return []map[string]string{album}, nil
+}
+
+func (w *Web) AlbumPhotos(id string) (sources.ItemFetcher, error) {
+	if id != albumID {
+		return nil, fmt.Errorf("wrong album %q", id)
+	}
+	p, err := w.load()
+	if err != nil {
+		return nil, err
+	}
+	return &fetcher{items: p.items, cur: -1}, nil
+}
+
+type fetcher struct {
+	items []*PhotoItem
+	cur   int
+}
+
+func (f *fetcher) Next() bool {
+	f.cur++
+	return f.cur < len(f.items)
+}
+
+func (f *fetcher) Item() sources.Photo {
+	return f.items[f.cur]
+}
+
+type service struct{}
+
+func (s *service) Kind() sources.Kind {
+	return sources.KindSource
+}
+
+func (s *service) Key() string {
+	return "web"
+}
+
+func (s *service) Constructor() func(creds string) sources.Source {
+	return New
+}
+
+func NewService() sources.ServiceSource {
+	return &service{}
+}
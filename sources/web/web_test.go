@@ -0,0 +1,173 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Gasoid/photoDumper/sources"
+	"github.com/stretchr/testify/assert"
+)
+
+const rssPage = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:media="http://search.yahoo.com/mrss/" xmlns:content="http://purl.org/rss/1.0/modules/content/">
+<channel>
+  <title>Photo blog</title>
+  <item>
+    <title>Sunset</title>
+    <link>https://blog.example.com/sunset</link>
+    <pubDate>Sun, 01 May 2022 10:00:00 +0300</pubDate>
+    <enclosure url="https://cdn.example.com/sunset.jpg" type="image/jpeg" length="1"/>
+    <enclosure url="https://cdn.example.com/podcast.mp3" type="audio/mpeg" length="1"/>
+    <content:encoded><![CDATA[<p>look <img src="/images/sunset-2.jpg"> and <img src="https://cdn.example.com/sunset.jpg"></p>]]></content:encoded>
+  </item>
+  <item>
+    <title>Sea</title>
+    <pubDate>Mon, 2 May 2022 10:00:00 GMT</pubDate>
+    <media:group>
+      <media:content url="https://cdn.example.com/sea.mp4" medium="video"/>
+    </media:group>
+  </item>
+</channel>
+</rss>`
+
+const atomPage = `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+  <title>Atom gallery</title>
+  <entry>
+    <title>Forest</title>
+    <link href="https://blog.example.com/forest"/>
+    <link rel="enclosure" href="https://cdn.example.com/forest.png" type="image/png"/>
+    <updated>2022-05-03T10:00:00Z</updated>
+    <content type="html">&lt;img src="forest-2.jpg"&gt;</content>
+  </entry>
+</feed>`
+
+// fakeWeb serves pages by path of the request
+func fakeWeb(t *testing.T, pages map[string]string) string {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, ok := pages[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(page))
+	}))
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+type item struct {
+	url, album, description string
+	kind                    sources.MediaKind
+	created                 time.Time
+}
+
+func items(t *testing.T, source sources.Source) []item {
+	cur, err := source.AlbumPhotos(albumID)
+	assert.NoError(t, err)
+	got := []item{}
+	for cur.Next() {
+		photo := cur.Item()
+		info, err := photo.ExifInfo()
+		assert.NoError(t, err)
+		got = append(got, item{photo.Url(), photo.AlbumName(), info.Description(), photo.Kind(), info.Created().UTC()})
+	}
+	return got
+}
+
+func TestWeb_RSS(t *testing.T) {
+	host := fakeWeb(t, map[string]string{"/feed.xml": rssPage})
+	source := New(host + "/feed.xml")
+	albums, err := source.AllAlbums()
+	assert.NoError(t, err)
+	assert.Equal(t, []map[string]string{
+		{"id": albumID, "title": "Photo blog", "size": "3", "thumb": "https://cdn.example.com/sunset.jpg"},
+	}, albums)
+	assert.Equal(t, []item{
+		{"https://cdn.example.com/sunset.jpg", "Photo blog", "Sunset", sources.MediaImage, time.Date(2022, 5, 1, 7, 0, 0, 0, time.UTC)},
+		{host + "/images/sunset-2.jpg", "Photo blog", "Sunset", sources.MediaImage, time.Date(2022, 5, 1, 7, 0, 0, 0, time.UTC)},
+		{"https://cdn.example.com/sea.mp4", "Photo blog", "Sea", sources.MediaVideo, time.Date(2022, 5, 2, 10, 0, 0, 0, time.UTC)},
+	}, items(t, source))
+}
+
+func TestWeb_Atom(t *testing.T) {
+	host := fakeWeb(t, map[string]string{"/blog/atom": atomPage})
+	assert.Equal(t, []item{
+		{"https://cdn.example.com/forest.png", "Atom gallery", "Forest", sources.MediaImage, time.Date(2022, 5, 3, 10, 0, 0, 0, time.UTC)},
+		{host + "/blog/forest-2.jpg", "Atom gallery", "Forest", sources.MediaImage, time.Date(2022, 5, 3, 10, 0, 0, 0, time.UTC)},
+	}, items(t, New(host+"/blog/atom")))
+}
+
+func TestWeb_HTML(t *testing.T) {
+	host := fakeWeb(t, map[string]string{"/trip/": galleryPage + `<video src="clip.mp4"></video>`})
+	tests := []struct {
+		name  string
+		creds string
+		want  []item
+	}{
+		{
+			name:  "default selector",
+			creds: host + "/trip/",
+			want: []item{
+				{host + "/logo.png", "Trip", "logo", sources.MediaImage, time.Time{}},
+				{host + "/trip/1.jpg", "Trip", "one", sources.MediaImage, time.Date(2022, 5, 1, 10, 0, 0, 0, time.UTC)},
+				{host + "/trip/2.jpg", "Trip", "two", sources.MediaImage, time.Time{}},
+				{host + "/trip/3.jpg", "Trip", "", sources.MediaImage, time.Time{}},
+			},
+		},
+		{
+			name:  "links and videos",
+			creds: host + "/trip/#.gallery a, figure, video",
+			want: []item{
+				{host + "/trip/1.jpg", "Trip", "one", sources.MediaImage, time.Date(2022, 5, 1, 10, 0, 0, 0, time.UTC)},
+				{host + "/trip/2.jpg", "Trip", "two", sources.MediaImage, time.Time{}},
+				{host + "/trip/2-full.jpg", "Trip", "two", sources.MediaImage, time.Time{}},
+				{host + "/trip/clip.mp4", "Trip", "", sources.MediaVideo, time.Time{}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, items(t, New(tt.creds)))
+		})
+	}
+}
+
+func TestWeb_Errors(t *testing.T) {
+	host := fakeWeb(t, map[string]string{})
+	tests := []struct {
+		name  string
+		creds string
+	}{
+		{name: "not found", creds: host + "/missing"},
+		{name: "not a url", creds: "gallery"},
+		{name: "wrong selector", creds: host + "/#img["},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.creds).AllAlbums()
+			var accessErr *sources.AccessError
+			assert.True(t, errors.As(err, &accessErr))
+		})
+	}
+	_, err := New(host + "/").AlbumPhotos("1")
+	assert.Error(t, err)
+}
+
+func TestWeb_HTMLTitle(t *testing.T) {
+	host := fakeWeb(t, map[string]string{"/": `<html><head><title>..</title></head><body><img src="1.jpg"></body></html>`})
+	cur, err := New(host + "/").AlbumPhotos(albumID)
+	assert.NoError(t, err)
+	assert.True(t, cur.Next())
+	assert.Equal(t, strings.ReplaceAll(strings.TrimPrefix(host, "http://"), ":", "_"), cur.Item().AlbumName())
+}
+
+func Test_largestSrc(t *testing.T) {
+	assert.Equal(t, "big.jpg", largestSrc("small.jpg 320w, big.jpg 1024w, mid.jpg 640w"))
+	assert.Equal(t, "2x.jpg", largestSrc("1x.jpg, 2x.jpg 2x"))
+	assert.Equal(t, "", largestSrc(""))
+}
@@ -0,0 +1,79 @@
+package sources
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"math"
+	"time"
+)
+
+const (
+	xmpHeader = `<?xpacket begin="` + "\ufeff" + `" id="W5M0MpCehiHzreSzNTczkc9d"?>
+<x:xmpmeta xmlns:x="adobe:ns:meta/">
+ <rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+  <rdf:Description rdf:about=""
+    xmlns:dc="http://purl.org/dc/elements/1.1/"
+    xmlns:xmp="http://ns.adobe.com/xap/1.0/"
+    xmlns:photoshop="http://ns.adobe.com/photoshop/1.0/"
+    xmlns:exif="http://ns.adobe.com/exif/1.0/">
+`
+	xmpFooter = `  </rdf:Description>
+ </rdf:RDF>
+</x:xmpmeta>
+<?xpacket end="w"?>
+`
+)
+
+// XMP renders info as an XMP packet which can be stored as a sidecar file
+func XMP(info ExifInfo) ([]byte, error) {
+	if info == nil {
+		return nil, fmt.Errorf("xmp: exif is empty")
+	}
+	b := bytes.NewBufferString(xmpHeader)
+	if description := info.Description(); description != "" {
+		b.WriteString("   <dc:description>\n    <rdf:Alt>\n     <rdf:li xml:lang=\"x-default\">")
+		if err := xml.EscapeText(b, []byte(description)); err != nil {
+			return nil, err
+		}
+		b.WriteString("</rdf:li>\n    </rdf:Alt>\n   </dc:description>\n")
+	}
+	if created := info.Created(); !created.IsZero() {
+		date := created.Format(time.RFC3339)
+		fmt.Fprintf(b, "   <xmp:CreateDate>%s</xmp:CreateDate>\n", date)
+		fmt.Fprintf(b, "   <photoshop:DateCreated>%s</photoshop:DateCreated>\n", date)
+		fmt.Fprintf(b, "   <exif:DateTimeOriginal>%s</exif:DateTimeOriginal>\n", date)
+	}
+	if gps := info.GPS(); gps != nil {
+		if err := gps.Validate(); err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(b, "   <exif:GPSLatitude>%s</exif:GPSLatitude>\n", xmpCoordinate(gps.Latitude, "N", "S"))
+		fmt.Fprintf(b, "   <exif:GPSLongitude>%s</exif:GPSLongitude>\n", xmpCoordinate(gps.Longitude, "E", "W"))
+		if gps.Altitude != nil {
+			ref := 0
+			if *gps.Altitude < 0 {
+				ref = 1
+			}
+			fmt.Fprintf(b, "   <exif:GPSAltitudeRef>%d</exif:GPSAltitudeRef>\n", ref)
+			fmt.Fprintf(b, "   <exif:GPSAltitude>%d/100</exif:GPSAltitude>\n", int64(math.Round(math.Abs(*gps.Altitude)*100)))
+		}
+		if gps.Accuracy != nil {
+			fmt.Fprintf(b, "   <exif:GPSHPositioningError>%d/100</exif:GPSHPositioningError>\n", int64(math.Round(*gps.Accuracy*100)))
+		}
+	}
+	b.WriteString(xmpFooter)
+	return b.Bytes(), nil
+}
+
+// xmpCoordinate formats a coordinate as "DDD,MM.mmmmmmK" like XMP spec requires
+func xmpCoordinate(value float64, positive, negative string) string {
+	ref := positive
+	if value < 0 {
+		ref = negative
+		value = -value
+	}
+	degrees := math.Floor(value)
+	minutes := (value - degrees) * 60
+	return fmt.Sprintf("%d,%.6f%s", int(degrees), minutes, ref)
+}
@@ -0,0 +1,184 @@
+package immich
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// deviceID identifies uploads of photoDumper, immich dedupes assets by device and device asset id
+const deviceID = "photoDumper"
+
+// Error is a response of immich with an error status
+type Error struct {
+	StatusCode int    `json:"statusCode"`
+	Message    string `json:"-"`
+	Kind       string `json:"error"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("immich: %s (status %d)", e.Message, e.StatusCode)
+}
+
+// decodeError reads an error of immich, message is a string or a list of validation errors
+func decodeError(resp *http.Response) error {
+	e := &Error{StatusCode: resp.StatusCode}
+	body := struct {
+		Message json.RawMessage `json:"message"`
+		Error   string          `json:"error"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err == nil {
+		e.Kind = body.Error
+		var messages []string
+		if json.Unmarshal(body.Message, &e.Message) != nil && json.Unmarshal(body.Message, &messages) == nil {
+			e.Message = strings.Join(messages, ", ")
+		}
+	}
+	if e.Message == "" {
+		e.Message = http.StatusText(resp.StatusCode)
+	}
+	return e
+}
+
+type Album struct {
+	ID   string `json:"id"`
+	Name string `json:"albumName"`
+}
+
+type ExifInfo struct {
+	Description      string     `json:"description"`
+	DateTimeOriginal *time.Time `json:"dateTimeOriginal"`
+	Latitude         *float64   `json:"latitude"`
+	Longitude        *float64   `json:"longitude"`
+}
+
+type Asset struct {
+	ID       string    `json:"id"`
+	ExifInfo *ExifInfo `json:"exifInfo"`
+}
+
+// AssetUpdate has fields of an asset to change, omitted fields are kept
+type AssetUpdate struct {
+	Description      *string  `json:"description,omitempty"`
+	DateTimeOriginal string   `json:"dateTimeOriginal,omitempty"`
+	Latitude         *float64 `json:"latitude,omitempty"`
+	Longitude        *float64 `json:"longitude,omitempty"`
+}
+
+// Upload is a file uploaded into immich
+type Upload struct {
+	// DeviceAssetID is an id of the file on the device, the same file is uploaded once
+	DeviceAssetID string
+	Filename      string
+	Created       time.Time
+	Modified      time.Time
+	Data          io.Reader
+}
+
+// API is a client of the immich server, see https://immich.app/docs/api
+type API struct {
+	serverUrl string
+	apiKey    string
+	client    *http.Client
+}
+
+func NewAPI(serverUrl, apiKey string) *API {
+	return &API{serverUrl: strings.TrimSuffix(serverUrl, "/"), apiKey: apiKey, client: http.DefaultClient}
+}
+
+func (a *API) do(method, path, contentType string, body io.Reader, v interface{}) error {
+	req, err := http.NewRequest(method, a.serverUrl+"/api"+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("x-api-key", a.apiKey)
+	req.Header.Set("Accept", "application/json")
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		return decodeError(resp)
+	}
+	if v == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func (a *API) doJSON(method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	return a.do(method, path, "application/json", body, out)
+}
+
+// Me checks the api key
+func (a *API) Me() error {
+	return a.do(http.MethodGet, "/users/me", "", nil, nil)
+}
+
+func (a *API) Albums() ([]*Album, error) {
+	albums := []*Album{}
+	return albums, a.doJSON(http.MethodGet, "/albums", nil, &albums)
+}
+
+func (a *API) CreateAlbum(name string) (*Album, error) {
+	album := &Album{}
+	return album, a.doJSON(http.MethodPost, "/albums", map[string]string{"albumName": name}, album)
+}
+
+// AddToAlbum adds assets to the album, assets which are already in the album are skipped by immich
+func (a *API) AddToAlbum(albumID string, assetIDs ...string) error {
+	results := []struct {
+		ID      string `json:"id"`
+		Success bool   `json:"success"`
+		Error   string `json:"error"`
+	}{}
+	err := a.doJSON(http.MethodPut, "/albums/"+url.PathEscape(albumID)+"/assets", map[string][]string{"ids": assetIDs}, &results)
+	if err != nil {
+		return err
+	}
+	for _, result := range results {
+		if !result.Success && result.Error != "duplicate" {
+			return fmt.Errorf("immich: asset %s can't be added to the album: %s", result.ID, result.Error)
+		}
+	}
+	return nil
+}
+
+// Upload sends the file as multipart/form-data, id of an existing asset is returned for duplicates
+func (a *API) Upload(upload *Upload) (string, error) {
+	body, contentType := multipartBody(upload)
+	asset := &struct {
+		ID     string `json:"id"`
+		Status string `json:"status"`
+	}{}
+	if err := a.do(http.MethodPost, "/assets", contentType, body, asset); err != nil {
+		return "", err
+	}
+	return asset.ID, nil
+}
+
+func (a *API) Asset(id string) (*Asset, error) {
+	asset := &Asset{}
+	return asset, a.doJSON(http.MethodGet, "/assets/"+url.PathEscape(id), nil, asset)
+}
+
+func (a *API) UpdateAsset(id string, update *AssetUpdate) error {
+	return a.doJSON(http.MethodPut, "/assets/"+url.PathEscape(id), update, nil)
+}
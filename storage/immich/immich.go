@@ -0,0 +1,233 @@
+// Package immich uploads photos into a self-hosted immich server, albums of sources become immich albums
+package immich
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Gasoid/photoDumper/sources"
+	local "github.com/Gasoid/photoDumper/storage/localfs"
+)
+
+// thumbnailSuffix is a name of thumbnails of videos, immich generates thumbnails itself
+const thumbnailSuffix = ".thumb"
+
+var client = local.NewClient()
+
+// Storage uploads files into immich, paths of files are kept in the local directory of the job,
+// sidecars (comments, json) are written there as immich has no place for them
+type Storage struct {
+	api   *API
+	local *local.SimpleStorage
+
+	mu     sync.Mutex
+	albums map[string]string
+	// assets are ids of uploaded assets by paths returned by DownloadPhoto
+	assets map[string]string
+	// albumDirs are ids of immich albums by dirs returned by CreateAlbumDir
+	albumDirs map[string]string
+}
+
+func New(serverUrl, apiKey string) *Storage {
+	return &Storage{
+		api:       NewAPI(serverUrl, apiKey),
+		local:     &local.SimpleStorage{},
+		assets:    map[string]string{},
+		albumDirs: map[string]string{},
+	}
+}
+
+// Prepare checks the api key, the local directory keeps sidecars
+func (s *Storage) Prepare(dir string) (string, error) {
+	if err := s.api.Me(); err != nil {
+		return "", fmt.Errorf("immich is unavailable: %w", err)
+	}
+	return s.local.Prepare(dir)
+}
+
+func (s *Storage) FilePath(dir, filename string) string {
+	return filepath.Join(dir, filename)
+}
+
+// CreateAlbumDir finds the album by name or creates it, albums are created once for concurrent downloads,
+// the directory of sidecars can't point outside of rootDir
+func (s *Storage) CreateAlbumDir(rootDir, albumName string) (string, error) {
+	dir := sources.AlbumDir(rootDir, albumName)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.albumDirs[dir]; ok {
+		return dir, nil
+	}
+	if s.albums == nil {
+		albums, err := s.api.Albums()
+		if err != nil {
+			return "", fmt.Errorf("createAlbumDir: %w", err)
+		}
+		s.albums = map[string]string{}
+		for _, album := range albums {
+			s.albums[album.Name] = album.ID
+		}
+	}
+	id, ok := s.albums[albumName]
+	if !ok {
+		album, err := s.api.CreateAlbum(albumName)
+		if err != nil {
+			return "", fmt.Errorf("createAlbumDir: %w", err)
+		}
+		id = album.ID
+		s.albums[albumName] = id
+	}
+	s.albumDirs[dir] = id
+	return dir, nil
+}
+
+// DownloadPhoto uploads the file into immich and adds it to the album of dir,
+// the file date is the date of the source, Last-Modified of the response if the source doesn't know it
+func (s *Storage) DownloadPhoto(url, dir, name string, info sources.FileInfo) (string, error) {
+	if strings.HasSuffix(name, thumbnailSuffix) {
+		return "", nil
+	}
+	resp, err := local.Get(client, url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%q is unavailable, code is %d", url, resp.StatusCode)
+	}
+	name, err = local.FileName(url, name, resp.Header.Get("Content-Type"))
+	if err != nil {
+		return "", err
+	}
+	modified, err := http.ParseTime(resp.Header.Get("Last-Modified"))
+	if err != nil {
+		modified = time.Now()
+	}
+	return s.upload(resp.Body, dir, name, info, modified)
+}
+
+// SavePhoto uploads a file of a local source into immich and adds it to the album of dir
+func (s *Storage) SavePhoto(r io.Reader, dir, name string, info sources.FileInfo) (string, error) {
+	if strings.HasSuffix(name, thumbnailSuffix) {
+		return "", nil
+	}
+	name, r, err := local.SniffName(r, name)
+	if err != nil {
+		return "", err
+	}
+	return s.upload(r, dir, name, info, time.Now())
+}
+
+// upload sends the file, modified is used as the file date if the source doesn't know the date
+func (s *Storage) upload(r io.Reader, dir, name string, info sources.FileInfo, modified time.Time) (string, error) {
+	filepath := s.FilePath(dir, name)
+	created := modified
+	if !info.Created.IsZero() {
+		created, modified = info.Created, info.Created
+	}
+	assetID, err := s.api.Upload(&Upload{
+		DeviceAssetID: deviceAssetID(info.Source, filepath),
+		Filename:      name,
+		Created:       created,
+		Modified:      modified,
+		Data:          r,
+	})
+	if err != nil {
+		return "", fmt.Errorf("upload %q: %w", name, err)
+	}
+	s.mu.Lock()
+	s.assets[filepath] = assetID
+	albumID, ok := s.albumDirs[dir]
+	s.mu.Unlock()
+	if ok {
+		if err := s.api.AddToAlbum(albumID, assetID); err != nil {
+			return "", err
+		}
+	}
+	return filepath, nil
+}
+
+// deviceAssetID is the source and the path of the file, so sources with the same album and file names don't collide,
+// files with the same content are stored once by immich anyway
+func deviceAssetID(source, file string) string {
+	return source + ":" + filepath.ToSlash(file)
+}
+
+// SetExif updates description, date and location of the asset, fields extracted by immich
+// from the file itself are kept unless policy allows to override them
+func (s *Storage) SetExif(filepath string, info sources.ExifInfo, policy sources.MergePolicy) ([]string, error) {
+	if info == nil {
+		return nil, errors.New("exif is empty")
+	}
+	s.mu.Lock()
+	assetID, ok := s.assets[filepath]
+	s.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("%q has not been uploaded", filepath)
+	}
+	asset, err := s.api.Asset(assetID)
+	if err != nil {
+		return nil, err
+	}
+	existing := asset.ExifInfo
+	if existing == nil {
+		existing = &ExifInfo{}
+	}
+	update := &AssetUpdate{}
+	changed := []string{}
+	if description := info.Description(); description != "" && policy.Write(sources.FieldDescription, existing.Description != "") {
+		update.Description = &description
+		changed = append(changed, sources.FieldDescription)
+	}
+	if created := info.Created(); !created.IsZero() && policy.Write(sources.FieldCreated, existing.DateTimeOriginal != nil) {
+		update.DateTimeOriginal = created.Format(time.RFC3339)
+		changed = append(changed, sources.FieldCreated)
+	}
+	if gps := info.GPS(); gps != nil && policy.Write(sources.FieldGPS, existing.Latitude != nil && existing.Longitude != nil) {
+		update.Latitude, update.Longitude = &gps.Latitude, &gps.Longitude
+		changed = append(changed, sources.FieldGPS)
+	}
+	if len(changed) == 0 {
+		return changed, nil
+	}
+	return changed, s.api.UpdateAsset(assetID, update)
+}
+
+// WriteSidecar writes the file into the local directory of the job
+func (s *Storage) WriteSidecar(file string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0750); err != nil {
+		return err
+	}
+	return s.local.WriteSidecar(file, data)
+}
+
+type service struct {
+	serverUrl string
+	apiKey    string
+}
+
+func (s *service) Kind() sources.Kind {
+	return sources.KindStorage
+}
+
+func (s *service) Key() string {
+	return "immich"
+}
+
+func (s *service) Constructor() func() sources.Storage {
+	return func() sources.Storage {
+		return New(s.serverUrl, s.apiKey)
+	}
+}
+
+// NewService uploads into the immich server, the api key is created in account settings of immich
+func NewService(serverUrl, apiKey string) sources.ServiceStorage {
+	return &service{serverUrl: serverUrl, apiKey: apiKey}
+}
@@ -0,0 +1,297 @@
+package immich
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Gasoid/photoDumper/sources"
+	"github.com/stretchr/testify/assert"
+)
+
+const apiKey = "secret"
+
+type upload struct {
+	fields map[string]string
+	name   string
+	data   string
+}
+
+// fakeImmich keeps albums and assets of the server in memory
+type fakeImmich struct {
+	mu          sync.Mutex
+	albums      []*Album
+	albumAssets map[string][]string
+	uploads     []upload
+	assets      map[string]*Asset
+	updates     map[string]map[string]interface{}
+}
+
+func newFakeImmich(t *testing.T) (*fakeImmich, string) {
+	f := &fakeImmich{albumAssets: map[string][]string{}, assets: map[string]*Asset{}, updates: map[string]map[string]interface{}{}}
+	server := httptest.NewServer(f)
+	t.Cleanup(server.Close)
+	return f, server.URL
+}
+
+func (f *fakeImmich) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.Header.Get("x-api-key") != apiKey {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]interface{}{"message": "Invalid API key", "error": "Unauthorized", "statusCode": 401})
+		return
+	}
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/"), "/")
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/api/users/me":
+		json.NewEncoder(w).Encode(map[string]string{"id": "user"})
+	case r.Method == http.MethodGet && r.URL.Path == "/api/albums":
+		json.NewEncoder(w).Encode(f.albums)
+	case r.Method == http.MethodPost && r.URL.Path == "/api/albums":
+		album := &Album{}
+		json.NewDecoder(r.Body).Decode(album)
+		album.ID = fmt.Sprintf("album-%d", len(f.albums)+1)
+		f.albums = append(f.albums, album)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(album)
+	case r.Method == http.MethodPut && len(parts) == 3 && parts[0] == "albums" && parts[2] == "assets":
+		body := map[string][]string{}
+		json.NewDecoder(r.Body).Decode(&body)
+		f.albumAssets[parts[1]] = append(f.albumAssets[parts[1]], body["ids"]...)
+		results := []map[string]interface{}{}
+		for _, id := range body["ids"] {
+			results = append(results, map[string]interface{}{"id": id, "success": true})
+		}
+		json.NewEncoder(w).Encode(results)
+	case r.Method == http.MethodPost && r.URL.Path == "/api/assets":
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{"message": []string{"assetData must be a file"}, "error": "Bad Request", "statusCode": 400})
+			return
+		}
+		u := upload{fields: map[string]string{}}
+		for key, values := range r.MultipartForm.Value {
+			u.fields[key] = values[0]
+		}
+		file, header, _ := r.FormFile("assetData")
+		data, _ := io.ReadAll(file)
+		u.name, u.data = header.Filename, string(data)
+		f.uploads = append(f.uploads, u)
+		id := fmt.Sprintf("asset-%d", len(f.uploads))
+		f.assets[id] = &Asset{ID: id, ExifInfo: &ExifInfo{}}
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]string{"id": id, "status": "created"})
+	case r.Method == http.MethodGet && len(parts) == 2 && parts[0] == "assets":
+		json.NewEncoder(w).Encode(f.assets[parts[1]])
+	case r.Method == http.MethodPut && len(parts) == 2 && parts[0] == "assets":
+		update := map[string]interface{}{}
+		json.NewDecoder(r.Body).Decode(&update)
+		f.updates[parts[1]] = update
+		json.NewEncoder(w).Encode(f.assets[parts[1]])
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{"message": "Cannot " + r.Method + " " + r.URL.Path, "error": "Not Found", "statusCode": 404})
+	}
+}
+
+// fakeFiles serves files of sources
+func fakeFiles(t *testing.T) string {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/1.jpg":
+			w.Header().Set("Last-Modified", "Sun, 01 May 2022 10:00:00 GMT")
+			w.Write([]byte("jpeg"))
+		case "/video":
+			w.Header().Set("Content-Type", "video/mp4")
+			w.Write([]byte("mp4"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+func TestStorage_Prepare(t *testing.T) {
+	_, serverUrl := newFakeImmich(t)
+	dir := filepath.Join(t.TempDir(), "dump")
+	got, err := New(serverUrl, apiKey).Prepare(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, dir, got)
+	assert.DirExists(t, dir)
+
+	_, err = New(serverUrl, "wrong").Prepare(dir)
+	var immichErr *Error
+	assert.True(t, errors.As(err, &immichErr))
+	assert.Equal(t, "immich is unavailable: immich: Invalid API key (status 401)", err.Error())
+}
+
+func TestStorage_CreateAlbumDir(t *testing.T) {
+	fake, serverUrl := newFakeImmich(t)
+	fake.albums = []*Album{{ID: "existing", Name: "gasoid/2021"}}
+	s := New(serverUrl, apiKey)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			dir, err := s.CreateAlbumDir("/dump", "gasoid/2022")
+			assert.NoError(t, err)
+			assert.Equal(t, filepath.Join("/dump", "gasoid/2022"), dir)
+		}()
+	}
+	wg.Wait()
+	_, err := s.CreateAlbumDir("/dump", "gasoid/2021")
+	assert.NoError(t, err)
+	assert.Equal(t, []*Album{{ID: "existing", Name: "gasoid/2021"}, {ID: "album-2", Name: "gasoid/2022"}}, fake.albums)
+	assert.Equal(t, map[string]string{filepath.Join("/dump", "gasoid/2022"): "album-2", filepath.Join("/dump", "gasoid/2021"): "existing"}, s.albumDirs)
+
+	// sidecars of albums can't be written outside of the dump dir
+	dir, err := s.CreateAlbumDir("/dump", "../../.ssh")
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join("/dump", "_", "_", ".ssh"), dir)
+}
+
+func TestStorage_DownloadPhoto(t *testing.T) {
+	fake, serverUrl := newFakeImmich(t)
+	files := fakeFiles(t)
+	s := New(serverUrl, apiKey)
+	dir, err := s.CreateAlbumDir("/dump", "trip")
+	assert.NoError(t, err)
+
+	path, err := s.DownloadPhoto(files+"/1.jpg", dir, "", sources.FileInfo{Source: "vk"})
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "1.jpg"), path)
+	path, err = s.DownloadPhoto(files+"/video", dir, "clip", sources.FileInfo{Source: "vk"})
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "clip.mp4"), path)
+	// thumbnails of videos aren't uploaded
+	path, err = s.DownloadPhoto(files+"/1.jpg", dir, "clip.thumb", sources.FileInfo{Source: "vk"})
+	assert.NoError(t, err)
+	assert.Equal(t, "", path)
+	_, err = s.DownloadPhoto(files+"/missing.jpg", dir, "", sources.FileInfo{Source: "vk"})
+	assert.Error(t, err)
+
+	assert.Len(t, fake.uploads, 2)
+	assert.Equal(t, upload{
+		fields: map[string]string{
+			"deviceAssetId":  "vk:" + filepath.ToSlash(filepath.Join(dir, "1.jpg")),
+			"deviceId":       deviceID,
+			"fileCreatedAt":  "2022-05-01T10:00:00Z",
+			"fileModifiedAt": "2022-05-01T10:00:00Z",
+			"filename":       "1.jpg",
+		},
+		name: "1.jpg",
+		data: "jpeg",
+	}, fake.uploads[0])
+	assert.Equal(t, "clip.mp4", fake.uploads[1].name)
+	assert.Equal(t, "mp4", fake.uploads[1].data)
+	assert.Equal(t, map[string][]string{"album-1": {"asset-1", "asset-2"}}, fake.albumAssets)
+
+	_, err = s.DownloadPhoto("file:///etc/hostname", dir, "", sources.FileInfo{Source: "vk"})
+	assert.Error(t, err)
+	// the date of the source is the file date, the same path of another source is another asset
+	created := time.Date(2019, 8, 3, 17, 30, 0, 0, time.FixedZone("MSK", 3*60*60))
+	path, err = s.SavePhoto(strings.NewReader("\xff\xd8\xff\xe0 local"), dir, "1.jpg", sources.FileInfo{Source: "localdir", Created: created})
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "1.jpg"), path)
+	assert.Len(t, fake.uploads, 3)
+	assert.Equal(t, map[string]string{
+		"deviceAssetId":  "localdir:" + filepath.ToSlash(filepath.Join(dir, "1.jpg")),
+		"deviceId":       deviceID,
+		"fileCreatedAt":  "2019-08-03T17:30:00+03:00",
+		"fileModifiedAt": "2019-08-03T17:30:00+03:00",
+		"filename":       "1.jpg",
+	}, fake.uploads[2].fields)
+	path, err = s.SavePhoto(strings.NewReader("\xff\xd8\xff\xe0 local"), dir, "IMG_2", sources.FileInfo{Source: "localdir"})
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "IMG_2.jpg"), path)
+	assert.Len(t, fake.uploads, 4)
+	assert.Equal(t, "IMG_2.jpg", fake.uploads[3].name)
+}
+
+type exifInfo struct {
+	description string
+	created     time.Time
+	gps         *sources.GPS
+}
+
+func (e *exifInfo) Description() string { return e.description }
+func (e *exifInfo) Created() time.Time  { return e.created }
+func (e *exifInfo) GPS() *sources.GPS   { return e.gps }
+
+func TestStorage_SetExif(t *testing.T) {
+	fake, serverUrl := newFakeImmich(t)
+	files := fakeFiles(t)
+	s := New(serverUrl, apiKey)
+	dir, err := s.CreateAlbumDir("/dump", "trip")
+	assert.NoError(t, err)
+	path, err := s.DownloadPhoto(files+"/1.jpg", dir, "", sources.FileInfo{Source: "vk"})
+	assert.NoError(t, err)
+
+	gps, err := sources.NewGPS(55.75, 37.62)
+	assert.NoError(t, err)
+	created := time.Date(2022, 5, 1, 13, 0, 0, 0, time.FixedZone("MSK", 3*60*60))
+	info := &exifInfo{description: "Sunset", created: created, gps: gps}
+
+	// the camera date and the location are extracted by immich from the file
+	latitude, longitude := 1.0, 2.0
+	fake.assets["asset-1"].ExifInfo = &ExifInfo{DateTimeOriginal: &created, Latitude: &latitude, Longitude: &longitude}
+	changed, err := s.SetExif(path, info, sources.MergePolicy{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{sources.FieldDescription}, changed)
+	assert.Equal(t, map[string]interface{}{"description": "Sunset"}, fake.updates["asset-1"])
+
+	policy, err := sources.ParseMergePolicy("all")
+	assert.NoError(t, err)
+	changed, err = s.SetExif(path, info, policy)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{sources.FieldDescription, sources.FieldCreated, sources.FieldGPS}, changed)
+	assert.Equal(t, map[string]interface{}{
+		"description":      "Sunset",
+		"dateTimeOriginal": "2022-05-01T13:00:00+03:00",
+		"latitude":         55.75,
+		"longitude":        37.62,
+	}, fake.updates["asset-1"])
+
+	_, err = s.SetExif(filepath.Join(dir, "missing.jpg"), info, policy)
+	assert.Error(t, err)
+}
+
+func TestStorage_WriteSidecar(t *testing.T) {
+	_, serverUrl := newFakeImmich(t)
+	file := filepath.Join(t.TempDir(), "trip", "1.jpg.json")
+	assert.NoError(t, New(serverUrl, apiKey).WriteSidecar(file, []byte("{}")))
+	data, err := os.ReadFile(file)
+	assert.NoError(t, err)
+	assert.Equal(t, "{}", string(data))
+}
+
+func Test_decodeError(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		message string
+	}{
+		{name: "message", status: http.StatusUnauthorized, body: `{"message":"Invalid API key","error":"Unauthorized","statusCode":401}`, message: "immich: Invalid API key (status 401)"},
+		{name: "validation", status: http.StatusBadRequest, body: `{"message":["deviceId should not be empty","assetData must be a file"],"error":"Bad Request","statusCode":400}`, message: "immich: deviceId should not be empty, assetData must be a file (status 400)"},
+		{name: "no body", status: http.StatusBadGateway, body: ``, message: "immich: Bad Gateway (status 502)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{StatusCode: tt.status, Body: io.NopCloser(strings.NewReader(tt.body))}
+			assert.Equal(t, tt.message, decodeError(resp).Error())
+		})
+	}
+}
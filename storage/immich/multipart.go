@@ -0,0 +1,40 @@
+package immich
+
+import (
+	"io"
+	"mime/multipart"
+	"time"
+)
+
+// multipartBody streams the upload, files aren't kept in memory
+func multipartBody(upload *Upload) (io.Reader, string) {
+	r, w := io.Pipe()
+	form := multipart.NewWriter(w)
+	go func() {
+		w.CloseWithError(writeForm(form, upload))
+	}()
+	return r, form.FormDataContentType()
+}
+
+func writeForm(form *multipart.Writer, upload *Upload) error {
+	fields := [][2]string{
+		{"deviceAssetId", upload.DeviceAssetID},
+		{"deviceId", deviceID},
+		{"fileCreatedAt", upload.Created.Format(time.RFC3339)},
+		{"fileModifiedAt", upload.Modified.Format(time.RFC3339)},
+		{"filename", upload.Filename},
+	}
+	for _, field := range fields {
+		if err := form.WriteField(field[0], field[1]); err != nil {
+			return err
+		}
+	}
+	part, err := form.CreateFormFile("assetData", upload.Filename)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(part, upload.Data); err != nil {
+		return err
+	}
+	return form.Close()
+}
@@ -0,0 +1,162 @@
+package localfs
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/Gasoid/photoDumper/sources"
+	goexif "github.com/dsoprea/go-exif/v2"
+	exifcommon "github.com/dsoprea/go-exif/v2/common"
+)
+
+const (
+	// GPSHPositioningError is not a part of the tag index of go-exif
+	gpsHPositioningErrorTag uint16 = 0x001f
+	secondsDenominator             = 10000
+
+	// OffsetTime and OffsetTimeOriginal are not a part of the tag index of go-exif
+	offsetTimeTag         uint16 = 0x9010
+	offsetTimeOriginalTag uint16 = 0x9011
+
+	// tags pointing to child IFDs of IFD0
+	exifIfdTag uint16 = 0x8769
+	gpsIfdTag  uint16 = 0x8825
+)
+
+// hasTag reports whether IFD0 (childTag is 0) or its child IFD already contains the tag
+func hasTag(rootIb *goexif.IfdBuilder, childTag uint16, name string) bool {
+	ib := rootIb
+	if childTag != 0 {
+		var err error
+		ib, err = rootIb.ChildWithTagId(childTag)
+		if err != nil {
+			return false
+		}
+	}
+	_, err := ib.FindTagWithName(name)
+	return err == nil
+}
+
+// exifByteOrder returns byte order of existing EXIF data of the file,
+// tags written as raw bytes must use the same byte order as the rest of IFD
+func exifByteOrder(path string) binary.ByteOrder {
+	raw, err := goexif.SearchFileAndExtractExif(path)
+	if err != nil {
+		return exifcommon.EncodeDefaultByteOrder
+	}
+	header, err := goexif.ParseExifHeader(raw)
+	if err != nil {
+		return exifcommon.EncodeDefaultByteOrder
+	}
+	return header.ByteOrder
+}
+
+// gpsRationals converts an absolute coordinate into degrees, minutes and seconds
+func gpsRationals(value float64) []exifcommon.Rational {
+	value = math.Abs(value)
+	degrees := math.Floor(value)
+	minutes := math.Floor((value - degrees) * 60)
+	seconds := ((value-degrees)*60 - minutes) * 60
+	return []exifcommon.Rational{
+		{Numerator: uint32(degrees), Denominator: 1},
+		{Numerator: uint32(minutes), Denominator: 1},
+		{Numerator: uint32(math.Round(seconds * secondsDenominator)), Denominator: secondsDenominator},
+	}
+}
+
+// setGPS writes location of the photo into GPS IFD including N/S and E/W references,
+// altitude and accuracy are written only if they are known
+func setGPS(rootIb *goexif.IfdBuilder, gps *sources.GPS, byteOrder binary.ByteOrder) error {
+	if err := gps.Validate(); err != nil {
+		return err
+	}
+	gpsIb, err := goexif.GetOrCreateIbFromRootIb(rootIb, "IFD/GPSInfo")
+	if err != nil {
+		return err
+	}
+	latRef, longRef := "N", "E"
+	if gps.Latitude < 0 {
+		latRef = "S"
+	}
+	if gps.Longitude < 0 {
+		longRef = "W"
+	}
+	type tag struct {
+		name  string
+		value interface{}
+	}
+	tags := []tag{
+		{"GPSVersionID", []byte{2, 3, 0, 0}},
+		{"GPSLatitudeRef", latRef},
+		{"GPSLatitude", gpsRationals(gps.Latitude)},
+		{"GPSLongitudeRef", longRef},
+		{"GPSLongitude", gpsRationals(gps.Longitude)},
+	}
+	if gps.Altitude != nil {
+		var ref byte
+		if *gps.Altitude < 0 {
+			ref = 1
+		}
+		altitude := []exifcommon.Rational{{Numerator: uint32(math.Round(math.Abs(*gps.Altitude) * 100)), Denominator: 100}}
+		tags = append(tags, tag{"GPSAltitudeRef", []byte{ref}}, tag{"GPSAltitude", altitude})
+	}
+	for _, t := range tags {
+		if err := gpsIb.SetStandardWithName(t.name, t.value); err != nil {
+			return err
+		}
+	}
+	if gps.Accuracy == nil {
+		return nil
+	}
+	accuracy := []exifcommon.Rational{{Numerator: uint32(math.Round(*gps.Accuracy * 100)), Denominator: 100}}
+	encoded, err := exifcommon.NewValueEncoder(byteOrder).Encode(accuracy)
+	if err != nil {
+		return err
+	}
+	bt := goexif.NewBuilderTag(
+		gpsIb.IfdIdentity().UnindexedString(),
+		gpsHPositioningErrorTag,
+		exifcommon.TypeRational,
+		goexif.NewIfdBuilderTagValueFromBytes(encoded.Encoded),
+		byteOrder,
+	)
+	return gpsIb.Set(bt)
+}
+
+// exifTimestamp formats wall clock of t, unlike goexif.ExifFullTimestampString it doesn't convert t to UTC
+func exifTimestamp(t time.Time) string {
+	return fmt.Sprintf("%04d:%02d:%02d %02d:%02d:%02d", t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second())
+}
+
+// setCaptureTime writes DateTime and DateTimeOriginal as wall clock of created
+// and its UTC offset into OffsetTime and OffsetTimeOriginal
+func setCaptureTime(rootIb *goexif.IfdBuilder, created time.Time) error {
+	timestamp := exifTimestamp(created)
+	if err := rootIb.SetStandardWithName("DateTime", timestamp); err != nil {
+		return err
+	}
+	exifIb, err := goexif.GetOrCreateIbFromRootIb(rootIb, "IFD/Exif")
+	if err != nil {
+		return err
+	}
+	if err := exifIb.SetStandardWithName("DateTimeOriginal", timestamp); err != nil {
+		return err
+	}
+	// ASCII values are the same in any byte order
+	offset := append([]byte(created.Format("-07:00")), 0)
+	for _, tag := range []uint16{offsetTimeTag, offsetTimeOriginalTag} {
+		bt := goexif.NewBuilderTag(
+			exifIb.IfdIdentity().UnindexedString(),
+			tag,
+			exifcommon.TypeAscii,
+			goexif.NewIfdBuilderTagValueFromBytes(offset),
+			exifcommon.EncodeDefaultByteOrder,
+		)
+		if err := exifIb.Set(bt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
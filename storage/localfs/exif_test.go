@@ -0,0 +1,106 @@
+package localfs
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Gasoid/photoDumper/sources"
+	exif "github.com/Gasoid/simpleGoExif"
+	goexif "github.com/dsoprea/go-exif/v2"
+	exifcommon "github.com/dsoprea/go-exif/v2/common"
+	"github.com/stretchr/testify/assert"
+)
+
+// readTags returns formatted values of tags of the IFD
+func readTags(t *testing.T, path, ifdPath string) map[uint16]string {
+	raw, err := goexif.SearchFileAndExtractExif(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tags, err := goexif.GetFlatExifData(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := map[uint16]string{}
+	for _, tag := range tags {
+		if tag.IfdPath == ifdPath {
+			values[tag.TagId] = tag.Formatted
+		}
+	}
+	return values
+}
+
+func Test_setGPS(t *testing.T) {
+	tests := []struct {
+		name    string
+		gps     *sources.GPS
+		want    map[uint16]string
+		wantErr bool
+	}{
+		{
+			name: "south west",
+			gps:  &sources.GPS{Latitude: -33.8568, Longitude: -70.6483},
+			want: map[uint16]string{
+				0x0001: "S",
+				0x0002: "[33/1 51/1 244800/10000]",
+				0x0003: "W",
+				0x0004: "[70/1 38/1 538800/10000]",
+			},
+		},
+		{
+			name: "altitude and accuracy",
+			gps:  (&sources.GPS{Latitude: 0, Longitude: 12.5}).WithAltitude(-12.5).WithAccuracy(30),
+			want: map[uint16]string{
+				0x0001: "N",
+				0x0003: "E",
+				0x0005: "01",
+				0x0006: "[1250/100]",
+				0x001f: "[3000/100]",
+			},
+		},
+		{
+			name:    "invalid",
+			gps:     &sources.GPS{Latitude: 91, Longitude: 0},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "gps.jpg")
+			writeJPEG(t, path)
+			image, err := exif.Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = setGPS(image.GetRootIb(), tt.gps, exifcommon.EncodeDefaultByteOrder)
+			assert.Equal(t, tt.wantErr, err != nil)
+			if err != nil {
+				return
+			}
+			assert.Nil(t, image.Close())
+			got := readTags(t, path, "IFD/GPSInfo")
+			for id, value := range tt.want {
+				assert.Equal(t, value, got[id], "tag 0x%04x", id)
+			}
+		})
+	}
+}
+
+func Test_setCaptureTime(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "time.jpg")
+	writeJPEG(t, path)
+	image, err := exif.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	created := time.Date(2021, 7, 1, 8, 30, 0, 0, time.FixedZone("EDT", -4*3600))
+	assert.Nil(t, setCaptureTime(image.GetRootIb(), created))
+	assert.Nil(t, image.Close())
+
+	assert.Equal(t, "2021:07:01 08:30:00", readTags(t, path, "IFD")[0x0132])
+	exifTags := readTags(t, path, "IFD/Exif")
+	assert.Equal(t, "2021:07:01 08:30:00", exifTags[0x9003])
+	assert.Equal(t, "-04:00", exifTags[offsetTimeTag])
+	assert.Equal(t, "-04:00", exifTags[offsetTimeOriginalTag])
+}
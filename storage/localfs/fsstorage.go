@@ -1,14 +1,18 @@
 package localfs
 
 import (
+	"bufio"
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"log"
+	"mime"
 	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/Gasoid/photoDumper/sources"
 	exif "github.com/Gasoid/simpleGoExif"
@@ -17,6 +21,42 @@ import (
 type SimpleStorage struct {
 }
 
+// client downloads files of web sources, files of local sources are copied by SavePhoto
+var client = NewClient()
+
+// NewClient returns a client downloading http(s) urls only, redirects to other schemes (e.g. file://) are refused,
+// other storages download files with it too
+func NewClient() *http.Client {
+	return &http.Client{CheckRedirect: checkRedirect}
+}
+
+func checkRedirect(req *http.Request, via []*http.Request) error {
+	if !remoteUrl(req.URL) {
+		return fmt.Errorf("redirect to %q is not allowed", req.URL.Scheme)
+	}
+	if len(via) >= 10 {
+		return errors.New("stopped after 10 redirects")
+	}
+	return nil
+}
+
+// remoteUrl reports whether the url may be downloaded
+func remoteUrl(u *url.URL) bool {
+	return u.Scheme == "http" || u.Scheme == "https"
+}
+
+// Get checks the scheme of the url before it's requested, sources can't make storages read local files
+func Get(c *http.Client, rawUrl string) (*http.Response, error) {
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return nil, err
+	}
+	if !remoteUrl(u) {
+		return nil, fmt.Errorf("%q can't be downloaded, scheme %q is not allowed", rawUrl, u.Scheme)
+	}
+	return c.Get(rawUrl)
+}
+
 // It's a method of Social struct. It's checking if the path is absolute or relative.
 func (s *SimpleStorage) dirPath(dir string) (string, error) {
 	if len(dir) < 1 {
@@ -47,20 +87,60 @@ func (s *SimpleStorage) FilePath(dir, filename string) string {
 	return filepath.Join(dir, filename)
 }
 
-func filename(path string) (string, error) {
-	u, err := url.Parse(path)
+// knownExtensions has preferred extensions, mime.ExtensionsByType returns them in a system dependent order
+var knownExtensions = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"image/gif":       ".gif",
+	"image/webp":      ".webp",
+	"image/heic":      ".heic",
+	"video/mp4":       ".mp4",
+	"video/quicktime": ".mov",
+	"video/webm":      ".webm",
+}
+
+// extension returns an extension of the content type
+func extension(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
 	if err != nil {
-		return "", err
+		return ""
+	}
+	if ext, ok := knownExtensions[mediaType]; ok {
+		return ext
+	}
+	exts, err := mime.ExtensionsByType(mediaType)
+	if err != nil || len(exts) == 0 {
+		return ""
+	}
+	return exts[0]
+}
+
+// FileName returns name if it's set or the basename of the url,
+// an extension is taken from the content type if the name has none
+func FileName(rawUrl, name, contentType string) (string, error) {
+	if name == "" {
+		u, err := url.Parse(rawUrl)
+		if err != nil {
+			return "", err
+		}
+		name = filepath.Base(u.Path)
+	}
+	if name == "" || name == "." || name == "/" {
+		return "", errors.New("file name is empty")
 	}
-	name := filepath.Base(u.Path)
 	if filepath.Ext(name) == "" {
-		return "", errors.New("no ext")
+		ext := extension(contentType)
+		if ext == "" {
+			return "", errors.New("no ext")
+		}
+		name += ext
 	}
 	return name, nil
 }
 
+// CreateAlbumDir creates the directory of the album, titles of albums can't point outside of rootDir
 func (s *SimpleStorage) CreateAlbumDir(rootDir, albumName string) (string, error) {
-	albumDir := filepath.Join(rootDir, albumName)
+	albumDir := sources.AlbumDir(rootDir, albumName)
 	err := os.MkdirAll(albumDir, 0750)
 	if err != nil {
 		return "", fmt.Errorf("createAlbumDir: %w", err)
@@ -68,21 +148,74 @@ func (s *SimpleStorage) CreateAlbumDir(rootDir, albumName string) (string, error
 	return albumDir, nil
 }
 
-// It downloads the file from the url, creates a file with the name of the file, and writes the body of
-// the response to the file
-func (s *SimpleStorage) DownloadPhoto(url, dir string) (string, error) {
-	resp, err := http.Get(url)
+// It downloads the file from the url, creates a file with the given name (or the name of the file),
+// and writes the body of the response to the file
+func (s *SimpleStorage) DownloadPhoto(url, dir, name string, _ sources.FileInfo) (string, error) {
+	resp, err := Get(client, url)
 	if err != nil {
 		log.Println(err)
 		return "", err
 	}
+	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
 		log.Printf("%q is unavailable. code is %d", url, resp.StatusCode)
+		return "", fmt.Errorf("%q is unavailable, code is %d", url, resp.StatusCode)
+	}
+	name, err = FileName(url, name, resp.Header.Get("Content-Type"))
+	if err != nil {
 		return "", err
 	}
-	defer resp.Body.Close()
-	name, _ := filename(url)
-	filepath := s.FilePath(dir, name)
+	return s.writeFile(s.FilePath(dir, name), resp.Body)
+}
+
+// SavePhoto copies a file of a local source, the extension is detected by the content if name has none
+func (s *SimpleStorage) SavePhoto(r io.Reader, dir, name string, _ sources.FileInfo) (string, error) {
+	name, content, err := SniffName(r, name)
+	if err != nil {
+		return "", err
+	}
+	path := s.FilePath(dir, name)
+	// the file would be truncated by os.Create before it's read
+	if f, ok := r.(interface{ Stat() (fs.FileInfo, error) }); ok {
+		if sameFile(f, path) {
+			return "", fmt.Errorf("%q can't be copied over itself", path)
+		}
+	}
+	return s.writeFile(path, content)
+}
+
+// sameFile reports whether the opened file is stored at path
+func sameFile(f interface{ Stat() (fs.FileInfo, error) }, path string) bool {
+	src, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	dst, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(src, dst)
+}
+
+// SniffName adds an extension detected by the content to name if it has none,
+// the returned reader has to be used instead of r
+func SniffName(r io.Reader, name string) (string, io.Reader, error) {
+	if name == "" {
+		return "", nil, errors.New("file name is empty")
+	}
+	if filepath.Ext(name) != "" {
+		return name, r, nil
+	}
+	buffered := bufio.NewReader(r)
+	head, err := buffered.Peek(512)
+	if err != nil && err != io.EOF {
+		return "", nil, err
+	}
+	name, err = FileName("", name, http.DetectContentType(head))
+	return name, buffered, err
+}
+
+func (s *SimpleStorage) writeFile(filepath string, r io.Reader) (string, error) {
 	// Create the file
 	out, err := os.Create(filepath)
 	if err != nil {
@@ -91,43 +224,79 @@ func (s *SimpleStorage) DownloadPhoto(url, dir string) (string, error) {
 	}
 
 	// Write the body to file
-	_, err = io.Copy(out, resp.Body)
+	_, err = io.Copy(out, r)
 	if err != nil {
+		out.Close()
 		return "", err
 	}
-	out.Close()
-	return filepath, nil
+	return filepath, out.Close()
 }
 
 // It's setting EXIF data for the downloaded file.
-func (s *SimpleStorage) SetExif(filepath string, photoExif sources.ExifInfo) error {
+// Existing metadata is preserved unless policy allows to override it.
+func (s *SimpleStorage) SetExif(filepath string, photoExif sources.ExifInfo, policy sources.MergePolicy) ([]string, error) {
+	if mp4Supported(filepath) {
+		if photoExif == nil {
+			return nil, errors.New("exif is empty")
+		}
+		return setMP4Metadata(filepath, photoExif, policy)
+	}
+	if !exifSupported(filepath) {
+		return nil, sources.ErrExifUnsupported
+	}
 	image, err := exif.Open(filepath)
 	if err != nil {
 		log.Println("exif.Open", err)
-		return err
+		return nil, err
 	}
-	defer image.Close()
 	if photoExif == nil {
-		return errors.New("exif is empty")
+		return nil, errors.New("exif is empty")
 	}
-	err = image.SetDescription(photoExif.Description())
-	if err != nil {
-		return err
+	rootIb := image.GetRootIb()
+	changed := []string{}
+	description := photoExif.Description()
+	if description != "" && policy.Write(sources.FieldDescription, hasTag(rootIb, 0, "ImageDescription")) {
+		err = image.SetDescription(description)
+		if err != nil {
+			return nil, err
+		}
+		changed = append(changed, sources.FieldDescription)
 	}
-	err = image.SetTime(photoExif.Created())
-	if err != nil {
-		return err
+	created := photoExif.Created()
+	hasCreated := hasTag(rootIb, exifIfdTag, "DateTimeOriginal") || hasTag(rootIb, 0, "DateTime")
+	if !created.IsZero() && policy.Write(sources.FieldCreated, hasCreated) {
+		err = setCaptureTime(rootIb, created)
+		if err != nil {
+			return nil, err
+		}
+		changed = append(changed, sources.FieldCreated)
 	}
 	gps := photoExif.GPS()
-	if gps == nil {
-		return errors.New("gps is empty")
+	if gps != nil && policy.Write(sources.FieldGPS, hasTag(rootIb, gpsIfdTag, "GPSLatitude")) {
+		err = setGPS(rootIb, gps, exifByteOrder(filepath))
+		if err != nil {
+			return nil, err
+		}
+		changed = append(changed, sources.FieldGPS)
 	}
-	err = image.SetGPS(gps[0], gps[1])
-	if err != nil {
-		return err
+	if len(changed) == 0 {
+		return changed, nil
 	}
+	return changed, image.Close()
+}
 
-	return nil
+// WriteSidecar creates a file next to the downloaded one, e.g. photo.jpg.xmp
+func (s *SimpleStorage) WriteSidecar(filepath string, data []byte) error {
+	return os.WriteFile(filepath, data, 0640)
+}
+
+// exifSupported reports whether simpleGoExif is able to edit the file in place, it handles JPEG only
+func exifSupported(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jpg", ".jpeg":
+		return true
+	}
+	return false
 }
 
 func New() sources.Storage {
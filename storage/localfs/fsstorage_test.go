@@ -1,6 +1,14 @@
 package localfs
 
 import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -11,7 +19,7 @@ import (
 type ExifInfo struct {
 	description string
 	created     time.Time
-	gps         []float64
+	gps         *sources.GPS
 }
 
 func (e *ExifInfo) Description() string {
@@ -21,7 +29,7 @@ func (e *ExifInfo) Created() time.Time {
 	return e.created
 }
 
-func (e *ExifInfo) GPS() []float64 {
+func (e *ExifInfo) GPS() *sources.GPS {
 	return e.gps
 }
 
@@ -170,6 +178,22 @@ func TestSimpleStorage_createAlbumDir(t *testing.T) {
 	}
 }
 
+func TestSimpleStorage_CreateAlbumDirTraversal(t *testing.T) {
+	parent := t.TempDir()
+	root := filepath.Join(parent, "dump")
+	s := &SimpleStorage{}
+	for _, albumName := range []string{"../../.ssh", "..", "a/../../b", `..\..\x`, "/etc"} {
+		dir, err := s.CreateAlbumDir(root, albumName)
+		assert.NoError(t, err, albumName)
+		rel, err := filepath.Rel(root, dir)
+		assert.NoError(t, err)
+		assert.False(t, rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)), dir)
+	}
+	entries, err := os.ReadDir(parent)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
 func TestSimpleStorage_DownloadPhoto(t *testing.T) {
 	type args struct {
 		url,
@@ -217,12 +241,28 @@ func TestSimpleStorage_DownloadPhoto(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			s := &SimpleStorage{}
-			s.DownloadPhoto(tt.args.url, tt.args.albumName)
+			s.DownloadPhoto(tt.args.url, tt.args.albumName, "", sources.FileInfo{})
 		})
 	}
 }
 
+// writeJPEG creates a small JPEG without any EXIF data
+func writeJPEG(t *testing.T, path string) {
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	img := image.NewRGBA(image.Rect(0, 0, 16, 16))
+	if err := jpeg.Encode(f, img, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestSimpleStorage_SetExif(t *testing.T) {
+	dir := t.TempDir()
+	writeJPEG(t, filepath.Join(dir, "300.jpg"))
+	os.WriteFile(filepath.Join(dir, "300.webp"), []byte("not a jpeg"), 0640)
 	type args struct {
 		filepath  string
 		photoExif sources.ExifInfo
@@ -231,41 +271,95 @@ func TestSimpleStorage_SetExif(t *testing.T) {
 		name    string
 		args    args
 		wantErr bool
+		target  error
 	}{
 		{
 			name:    "gps is nil",
-			args:    args{filepath: "/tmp/photoD/300.jpg", photoExif: &ExifInfo{}},
+			args:    args{filepath: filepath.Join(dir, "300.jpg"), photoExif: &ExifInfo{}},
+			wantErr: false,
+		},
+		{
+			name:    "gps is out of range",
+			args:    args{filepath: filepath.Join(dir, "300.jpg"), photoExif: &ExifInfo{gps: &sources.GPS{Latitude: 145.4545, Longitude: 45.4545}}},
 			wantErr: true,
 		},
 		{
 			name:    "gps exists",
-			args:    args{filepath: "/tmp/photoD/300.jpg", photoExif: &ExifInfo{gps: []float64{45.4545, 45.4545}}},
+			args:    args{filepath: filepath.Join(dir, "300.jpg"), photoExif: &ExifInfo{gps: &sources.GPS{Latitude: 45.4545, Longitude: 45.4545}}},
 			wantErr: false,
 		},
 		{
 			name:    "wrong path",
-			args:    args{filepath: "/tmp/photoD/301.jpg"},
+			args:    args{filepath: filepath.Join(dir, "301.jpg")},
 			wantErr: true,
 		},
 		{
 			name:    "photoExif is nil",
-			args:    args{filepath: "/tmp/photoD/300.jpg", photoExif: nil},
+			args:    args{filepath: filepath.Join(dir, "300.jpg"), photoExif: nil},
+			wantErr: true,
+		},
+		{
+			name:    "unsupported format",
+			args:    args{filepath: filepath.Join(dir, "300.webp"), photoExif: &ExifInfo{}},
 			wantErr: true,
+			target:  sources.ErrExifUnsupported,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			s := &SimpleStorage{}
-			s.DownloadPhoto("https://picsum.photos/200/300.jpg", "/tmp/photoD/")
-			err := s.SetExif(tt.args.filepath, tt.args.photoExif)
+			_, err := s.SetExif(tt.args.filepath, tt.args.photoExif, sources.MergePolicy{})
 			assert.Equal(t, tt.wantErr, err != nil)
+			if tt.target != nil {
+				assert.ErrorIs(t, err, tt.target)
+			}
 		})
 	}
 }
 
-func Test_filename(t *testing.T) {
+func TestSimpleStorage_SetExifMerge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "300.jpg")
+	writeJPEG(t, path)
+	s := &SimpleStorage{}
+	override, _ := sources.ParseMergePolicy("description")
+	info := &ExifInfo{
+		description: "camera description",
+		created:     time.Date(2019, 1, 2, 3, 4, 5, 0, time.UTC),
+		gps:         &sources.GPS{Latitude: 10, Longitude: 20},
+	}
+
+	changed, err := s.SetExif(path, info, sources.MergePolicy{})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{sources.FieldDescription, sources.FieldCreated, sources.FieldGPS}, changed)
+
+	info.description = "vk description"
+	changed, err = s.SetExif(path, info, sources.MergePolicy{})
+	assert.Nil(t, err)
+	assert.Empty(t, changed)
+
+	changed, err = s.SetExif(path, info, override)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{sources.FieldDescription}, changed)
+}
+
+func TestSimpleStorage_WriteSidecar(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "300.mp4.xmp")
+	s := &SimpleStorage{}
+	err := s.WriteSidecar(path, []byte("<x:xmpmeta/>"))
+	assert.Nil(t, err)
+	data, err := os.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Equal(t, "<x:xmpmeta/>", string(data))
+
+	err = s.WriteSidecar(filepath.Join(path, "nonexistent", "300.jpg.xmp"), nil)
+	assert.NotNil(t, err)
+}
+
+func Test_fileName(t *testing.T) {
 	type args struct {
-		path string
+		url,
+		name,
+		contentType string
 	}
 	tests := []struct {
 		name    string
@@ -275,32 +369,76 @@ func Test_filename(t *testing.T) {
 	}{
 		{
 			name:    "no error",
-			args:    args{path: "https://example.com/asd.jpg"},
+			args:    args{url: "https://example.com/asd.jpg"},
 			want:    "asd.jpg",
 			wantErr: false,
 		},
 		{
 			name:    "error",
-			args:    args{path: "https://example.com/asd"},
+			args:    args{url: "https://example.com/asd"},
 			want:    "",
 			wantErr: true,
 		},
 		{
 			name:    "empty",
-			args:    args{path: ":/sdfsdf/sdfsdf"},
+			args:    args{url: ":/sdfsdf/sdfsdf"},
 			want:    "",
 			wantErr: true,
 		},
+		{
+			name: "ext from content type",
+			args: args{url: "https://example.com/asd", contentType: "video/mp4"},
+			want: "asd.mp4",
+		},
+		{
+			name: "name is set",
+			args: args{url: "https://cdn.example.com/123_n.mp4?efg=1", name: "17895695668004550", contentType: "video/mp4"},
+			want: "17895695668004550.mp4",
+		},
+		{
+			name: "name has ext",
+			args: args{url: "https://example.com/asd", name: "photo.png", contentType: "image/jpeg"},
+			want: "photo.png",
+		},
+		{
+			name: "content type with params",
+			args: args{url: "https://example.com/asd", contentType: "image/jpeg; charset=binary"},
+			want: "asd.jpg",
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := filename(tt.args.path)
+			got, err := FileName(tt.args.url, tt.args.name, tt.args.contentType)
 			assert.Equal(t, tt.wantErr, err != nil)
 			assert.Equal(t, tt.want, got)
 		})
 	}
 }
 
+func TestSimpleStorage_DownloadPhotoName(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "video/mp4")
+		w.Write([]byte("video"))
+	}))
+	defer server.Close()
+	dir := t.TempDir()
+	s := &SimpleStorage{}
+
+	got, err := s.DownloadPhoto(server.URL+"/v/t50.2886-16/123_n", dir, "17895695668004550", sources.FileInfo{})
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "17895695668004550.mp4"), got)
+	data, err := os.ReadFile(got)
+	assert.NoError(t, err)
+	assert.Equal(t, "video", string(data))
+
+	_, err = s.DownloadPhoto(server.URL+"/missing", dir, "", sources.FileInfo{})
+	assert.Error(t, err)
+}
+
 func TestNew(t *testing.T) {
 	tests := []struct {
 		name string
@@ -376,3 +514,47 @@ func TestNewService(t *testing.T) {
 		})
 	}
 }
+
+func TestSimpleStorage_DownloadPhotoLocal(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "photo.jpg")
+	writeJPEG(t, src)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "file://"+filepath.ToSlash(src), http.StatusFound)
+	}))
+	defer server.Close()
+	dir := t.TempDir()
+	s := &SimpleStorage{}
+
+	_, err := s.DownloadPhoto("file://"+filepath.ToSlash(src), dir, "", sources.FileInfo{})
+	assert.Error(t, err)
+	_, err = s.DownloadPhoto(server.URL+"/photo.jpg", dir, "", sources.FileInfo{})
+	assert.Error(t, err)
+	entries, _ := os.ReadDir(dir)
+	assert.Empty(t, entries)
+}
+
+func TestSimpleStorage_SavePhoto(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "photo.jpg")
+	writeJPEG(t, src)
+	want, _ := os.ReadFile(src)
+	dir := t.TempDir()
+	s := &SimpleStorage{}
+
+	got, err := s.SavePhoto(bytes.NewReader(want), dir, "IMG_1.jpg", sources.FileInfo{})
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "IMG_1.jpg"), got)
+	data, err := os.ReadFile(got)
+	assert.NoError(t, err)
+	assert.Equal(t, want, data)
+
+	// the extension is detected by the content
+	got, err = s.SavePhoto(bytes.NewReader(want), dir, "IMG_2", sources.FileInfo{})
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "IMG_2.jpg"), got)
+	data, err = os.ReadFile(got)
+	assert.NoError(t, err)
+	assert.Equal(t, want, data)
+
+	_, err = s.SavePhoto(bytes.NewReader(want), dir, "", sources.FileInfo{})
+	assert.Error(t, err)
+}
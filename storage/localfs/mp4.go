@@ -0,0 +1,245 @@
+package localfs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Gasoid/photoDumper/sources"
+)
+
+// udtaLanguage is "eng" packed as ISO 639-2/T code, QuickTime players expect it in text atoms
+const udtaLanguage = 0x15c7
+
+var errMalformedMP4 = errors.New("mp4: malformed box")
+
+// QuickTime user data atoms written into moov/udta
+var (
+	atomDescription = "\xa9des"
+	atomDay         = "\xa9day"
+	atomLocation    = "\xa9xyz"
+)
+
+type box struct {
+	typ    string
+	offset int64
+	header int64
+	size   int64
+}
+
+// mp4Supported reports whether metadata can be written into udta of the file
+func mp4Supported(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".mp4", ".m4v", ".mov":
+		return true
+	}
+	return false
+}
+
+// readBoxes lists boxes stored in r between start and end,
+// trailing bytes shorter than a box header (e.g. QuickTime udta terminator) are ignored
+func readBoxes(r io.ReaderAt, start, end int64) ([]box, error) {
+	boxes := []box{}
+	header := make([]byte, 16)
+	for offset := start; end-offset >= 8; {
+		if _, err := r.ReadAt(header[:8], offset); err != nil {
+			return nil, err
+		}
+		b := box{typ: string(header[4:8]), offset: offset, header: 8}
+		b.size = int64(binary.BigEndian.Uint32(header[:4]))
+		switch b.size {
+		case 0:
+			b.size = end - offset
+		case 1:
+			if _, err := r.ReadAt(header[8:16], offset+8); err != nil {
+				return nil, err
+			}
+			b.size = int64(binary.BigEndian.Uint64(header[8:16]))
+			b.header = 16
+		}
+		if b.size < b.header || offset+b.size > end {
+			return nil, errMalformedMP4
+		}
+		boxes = append(boxes, b)
+		offset += b.size
+	}
+	return boxes, nil
+}
+
+// makeBox returns a box with 32-bit size
+func makeBox(typ string, payload ...[]byte) ([]byte, error) {
+	size := 8
+	for _, p := range payload {
+		size += len(p)
+	}
+	if size > math.MaxUint32 {
+		return nil, fmt.Errorf("mp4: %s box is too big", typ)
+	}
+	b := make([]byte, 8, size)
+	binary.BigEndian.PutUint32(b, uint32(size))
+	copy(b[4:], typ)
+	for _, p := range payload {
+		b = append(b, p...)
+	}
+	return b, nil
+}
+
+// textAtom encodes a QuickTime user data text: length, language and the text itself,
+// the length is 16 bits, so longer texts (e.g. descriptions with comments) are truncated
+func textAtom(typ, text string) ([]byte, error) {
+	text = truncateText(text, math.MaxUint16)
+	payload := make([]byte, 4, 4+len(text))
+	binary.BigEndian.PutUint16(payload, uint16(len(text)))
+	binary.BigEndian.PutUint16(payload[2:], udtaLanguage)
+	return makeBox(typ, append(payload, text...))
+}
+
+// truncateText cuts text to n bytes without splitting a UTF-8 character
+func truncateText(text string, n int) string {
+	if len(text) <= n {
+		return text
+	}
+	for n > 0 && !utf8.RuneStart(text[n]) {
+		n--
+	}
+	return text[:n]
+}
+
+// iso6709 formats a location like Apple devices do, e.g. +37.7858-122.4064+010.000/
+func iso6709(gps *sources.GPS) string {
+	location := fmt.Sprintf("%+08.4f%+09.4f", gps.Latitude, gps.Longitude)
+	if gps.Altitude != nil {
+		location += fmt.Sprintf("%+.3f", *gps.Altitude)
+	}
+	return location + "/"
+}
+
+// setMP4Metadata writes description, capture time and location into moov/udta.
+// The file is not rewritten: if moov is the last box it's replaced in place,
+// otherwise the old moov becomes a free box and the new one is appended, so chunk offsets stay valid.
+func setMP4Metadata(path string, info sources.ExifInfo, policy sources.MergePolicy) ([]string, error) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	top, err := readBoxes(f, 0, stat.Size())
+	if err != nil {
+		return nil, err
+	}
+	var moov *box
+	for i := range top {
+		if top[i].typ == "moov" {
+			moov = &top[i]
+			break
+		}
+	}
+	if moov == nil {
+		return nil, errors.New("mp4: moov box not found")
+	}
+	moovData := make([]byte, moov.size)
+	if _, err := f.ReadAt(moovData, moov.offset); err != nil {
+		return nil, err
+	}
+	moovReader := bytes.NewReader(moovData)
+	children, err := readBoxes(moovReader, moov.header, moov.size)
+	if err != nil {
+		return nil, err
+	}
+	udta := []box{}
+	var udtaData []byte
+	for _, child := range children {
+		if child.typ == "udta" {
+			udtaData = moovData[child.offset : child.offset+child.size]
+			udta, err = readBoxes(bytes.NewReader(udtaData), child.header, child.size)
+			if err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+	existing := map[string]bool{}
+	for _, atom := range udta {
+		existing[atom.typ] = true
+	}
+
+	type field struct {
+		name, atom, value string
+	}
+	fields := []field{{sources.FieldDescription, atomDescription, info.Description()}}
+	if created := info.Created(); !created.IsZero() {
+		fields = append(fields, field{sources.FieldCreated, atomDay, created.Format("2006-01-02T15:04:05-0700")})
+	}
+	if gps := info.GPS(); gps != nil {
+		if err := gps.Validate(); err != nil {
+			return nil, err
+		}
+		fields = append(fields, field{sources.FieldGPS, atomLocation, iso6709(gps)})
+	}
+	changed := []string{}
+	atoms := map[string][]byte{}
+	for _, fl := range fields {
+		if fl.value == "" || !policy.Write(fl.name, existing[fl.atom]) {
+			continue
+		}
+		atoms[fl.atom], err = textAtom(fl.atom, fl.value)
+		if err != nil {
+			return nil, err
+		}
+		changed = append(changed, fl.name)
+	}
+	if len(changed) == 0 {
+		return changed, nil
+	}
+
+	udtaPayload := [][]byte{}
+	for _, atom := range udta {
+		if _, ok := atoms[atom.typ]; !ok {
+			udtaPayload = append(udtaPayload, udtaData[atom.offset:atom.offset+atom.size])
+		}
+	}
+	for _, fl := range fields {
+		if atom, ok := atoms[fl.atom]; ok {
+			udtaPayload = append(udtaPayload, atom)
+		}
+	}
+	newUdta, err := makeBox("udta", udtaPayload...)
+	if err != nil {
+		return nil, err
+	}
+	moovPayload := [][]byte{}
+	for _, child := range children {
+		if child.typ != "udta" {
+			moovPayload = append(moovPayload, moovData[child.offset:child.offset+child.size])
+		}
+	}
+	newMoov, err := makeBox("moov", append(moovPayload, newUdta)...)
+	if err != nil {
+		return nil, err
+	}
+
+	if moov.offset+moov.size == stat.Size() {
+		if _, err := f.WriteAt(newMoov, moov.offset); err != nil {
+			return nil, err
+		}
+		return changed, f.Truncate(moov.offset + int64(len(newMoov)))
+	}
+	if _, err := f.WriteAt(newMoov, stat.Size()); err != nil {
+		return nil, err
+	}
+	if _, err := f.WriteAt([]byte("free"), moov.offset+4); err != nil {
+		return nil, err
+	}
+	return changed, nil
+}
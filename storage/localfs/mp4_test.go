@@ -0,0 +1,180 @@
+package localfs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Gasoid/photoDumper/sources"
+	"github.com/stretchr/testify/assert"
+)
+
+func mustBox(t *testing.T, typ string, payload ...[]byte) []byte {
+	b, err := makeBox(typ, payload...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// udtaAtoms returns text of atoms stored in moov/udta of the last moov box
+func udtaAtoms(t *testing.T, path string) map[string]string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := bytes.NewReader(data)
+	top, err := readBoxes(r, 0, int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	atoms := map[string]string{}
+	for _, moov := range top {
+		if moov.typ != "moov" {
+			continue
+		}
+		children, err := readBoxes(r, moov.offset+moov.header, moov.offset+moov.size)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, udta := range children {
+			if udta.typ != "udta" {
+				continue
+			}
+			items, err := readBoxes(r, udta.offset+udta.header, udta.offset+udta.size)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, item := range items {
+				// skip length and language
+				atoms[item.typ] = string(data[item.offset+item.header+4 : item.offset+item.size])
+			}
+		}
+	}
+	return atoms
+}
+
+func Test_setMP4Metadata(t *testing.T) {
+	ftyp := mustBox(t, "ftyp", []byte("isom\x00\x00\x02\x00isomiso2mp41"))
+	mvhd := mustBox(t, "mvhd", make([]byte, 100))
+	mdat := mustBox(t, "mdat", []byte("frames"))
+	title, err := textAtom(atomDescription, "old")
+	if err != nil {
+		t.Fatal(err)
+	}
+	udta := mustBox(t, "udta", title)
+	altitude := 10.0
+	gps := &sources.GPS{Latitude: 37.7858, Longitude: -122.4064, Altitude: &altitude}
+	created := time.Date(2021, 7, 1, 10, 30, 0, 0, time.FixedZone("", 3*3600))
+	info := &ExifInfo{description: "new", created: created, gps: gps}
+
+	tests := []struct {
+		name    string
+		file    [][]byte
+		policy  sources.MergePolicy
+		want    map[string]string
+		changed []string
+		wantErr bool
+	}{
+		{
+			name:    "moov at the end",
+			file:    [][]byte{ftyp, mdat, mustBox(t, "moov", mvhd)},
+			want:    map[string]string{atomDescription: "new", atomDay: "2021-07-01T10:30:00+0300", atomLocation: "+37.7858-122.4064+10.000/"},
+			changed: []string{sources.FieldDescription, sources.FieldCreated, sources.FieldGPS},
+		},
+		{
+			name:    "moov before mdat",
+			file:    [][]byte{ftyp, mustBox(t, "moov", mvhd), mdat},
+			want:    map[string]string{atomDescription: "new", atomDay: "2021-07-01T10:30:00+0300", atomLocation: "+37.7858-122.4064+10.000/"},
+			changed: []string{sources.FieldDescription, sources.FieldCreated, sources.FieldGPS},
+		},
+		{
+			name:    "description exists",
+			file:    [][]byte{ftyp, mdat, mustBox(t, "moov", mvhd, udta)},
+			want:    map[string]string{atomDescription: "old", atomDay: "2021-07-01T10:30:00+0300", atomLocation: "+37.7858-122.4064+10.000/"},
+			changed: []string{sources.FieldCreated, sources.FieldGPS},
+		},
+		{
+			name:    "override description",
+			file:    [][]byte{ftyp, mdat, mustBox(t, "moov", mvhd, udta)},
+			policy:  func() sources.MergePolicy { p, _ := sources.ParseMergePolicy("description"); return p }(),
+			want:    map[string]string{atomDescription: "new", atomDay: "2021-07-01T10:30:00+0300", atomLocation: "+37.7858-122.4064+10.000/"},
+			changed: []string{sources.FieldDescription, sources.FieldCreated, sources.FieldGPS},
+		},
+		{
+			name:    "no moov",
+			file:    [][]byte{ftyp, mdat},
+			wantErr: true,
+		},
+		{
+			name:    "malformed",
+			file:    [][]byte{ftyp, []byte("\x00\x00\x01\x00moov")},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "video.mp4")
+			if err := os.WriteFile(path, bytes.Join(tt.file, nil), 0640); err != nil {
+				t.Fatal(err)
+			}
+			changed, err := setMP4Metadata(path, info, tt.policy)
+			assert.Equal(t, tt.wantErr, err != nil)
+			if tt.wantErr {
+				return
+			}
+			assert.Equal(t, tt.changed, changed)
+			assert.Equal(t, tt.want, udtaAtoms(t, path))
+			data, err := os.ReadFile(path)
+			assert.NoError(t, err)
+			// mdat must not be moved, otherwise chunk offsets are broken
+			assert.Equal(t, bytes.Index(bytes.Join(tt.file, nil), mdat), bytes.Index(data, mdat))
+		})
+	}
+}
+
+func TestSimpleStorage_SetExifMP4(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "video.mp4")
+	file := bytes.Join([][]byte{mustBox(t, "ftyp", []byte("isom")), mustBox(t, "moov", mustBox(t, "mvhd", make([]byte, 100)))}, nil)
+	if err := os.WriteFile(path, file, 0640); err != nil {
+		t.Fatal(err)
+	}
+	s := &SimpleStorage{}
+	changed, err := s.SetExif(path, &ExifInfo{description: "video"}, sources.MergePolicy{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{sources.FieldDescription}, changed)
+	assert.Equal(t, map[string]string{atomDescription: "video"}, udtaAtoms(t, path))
+}
+
+func TestSimpleStorage_SetExifMP4LongDescription(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "video.mp4")
+	file := bytes.Join([][]byte{mustBox(t, "ftyp", []byte("isom")), mustBox(t, "moov", mustBox(t, "mvhd", make([]byte, 100)))}, nil)
+	if err := os.WriteFile(path, file, 0640); err != nil {
+		t.Fatal(err)
+	}
+	// a caption with a long thread of comments, 2 bytes per letter
+	description := strings.Repeat("й", 40000)
+	_, err := (&SimpleStorage{}).SetExif(path, &ExifInfo{description: description}, sources.MergePolicy{})
+	assert.NoError(t, err)
+	got := udtaAtoms(t, path)[atomDescription]
+	assert.Equal(t, 65534, len(got))
+	assert.True(t, utf8.ValidString(got))
+	assert.True(t, strings.HasPrefix(description, got))
+
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	i := bytes.Index(data, []byte(atomDescription))
+	assert.Equal(t, uint16(len(got)), binary.BigEndian.Uint16(data[i+4:]))
+}
+
+func Test_truncateText(t *testing.T) {
+	assert.Equal(t, "abc", truncateText("abc", 5))
+	assert.Equal(t, "ab", truncateText("abc", 2))
+	assert.Equal(t, "a", truncateText("aй", 2))
+	assert.Equal(t, "", truncateText("й", 1))
+}